@@ -0,0 +1,402 @@
+// Package allergens finds the EU 14 allergens, "may contain" traces and diet
+// compatibility in a product's ingredient list. Keywords cover English,
+// French, German, Spanish, Italian and Dutch labels.
+package allergens
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"backend/models"
+	"backend/scoring"
+)
+
+// Found is an allergen present in the ingredients and the words that gave it away
+type Found struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Matches []string `json:"matches"`
+}
+
+// Diet statuses; "maybe" means only traces or an ambiguous ingredient were found
+const (
+	StatusYes     = "yes"
+	StatusNo      = "no"
+	StatusMaybe   = "maybe"
+	StatusUnknown = "unknown" // no ingredient list and no label to go on
+)
+
+// DietResult is whether a product fits one diet and why
+type DietResult struct {
+	Diet    string   `json:"diet"`
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+}
+
+// Report is the allergen and diet analysis of one product
+type Report struct {
+	Analyzed  bool         `json:"analyzed"` // false when the product has no ingredient list
+	Allergens []Found      `json:"allergens"`
+	Traces    []Found      `json:"traces"`
+	Diets     []DietResult `json:"diets"`
+}
+
+// Diets that products are checked against
+var Diets = []string{"vegan", "vegetarian", "halal", "gluten-free", "lactose-free"}
+
+// dietRule decides a diet from allergen ids and keyword groups. Labels on the
+// product settle it as compatible; no and maybe list what rules it out or in doubt.
+type dietRule struct {
+	labels      []string
+	no          []string
+	maybe       []string
+	maybeTraces []string
+}
+
+var dietRules = map[string]dietRule{
+	"vegan": {
+		labels: []string{"vegan"},
+		no:     []string{"milk", "eggs", "fish", "crustaceans", "molluscs", "meat", "pork", "gelatin", "carmine", "honey", "bee_products", "rennet"},
+	},
+	"vegetarian": {
+		labels: []string{"vegetarian", "vegan"},
+		no:     []string{"fish", "crustaceans", "molluscs", "meat", "pork", "gelatin", "carmine"},
+		maybe:  []string{"rennet"},
+	},
+	"halal": {
+		labels: []string{"halal"},
+		no:     []string{"pork", "alcohol"},
+		maybe:  []string{"meat", "gelatin"},
+	},
+	"gluten-free": {
+		labels:      []string{"gluten-free", "no-gluten"},
+		no:          []string{"gluten"},
+		maybeTraces: []string{"gluten"},
+	},
+	"lactose-free": {
+		labels:      []string{"lactose-free", "no-lactose"},
+		no:          []string{"milk"},
+		maybeTraces: []string{"milk"},
+	},
+}
+
+// ValidDiet reports whether d is one of Diets
+func ValidDiet(d string) bool { return contains(Diets, d) }
+
+// ValidAllergen reports whether id is one of the EU14 ids
+func ValidAllergen(id string) bool {
+	for _, a := range EU14 {
+		if a.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze parses the product's ingredients (IngredientsText, or the Ingredients
+// list when there is no text) and its labels
+func Analyze(p models.Product) Report {
+	text := p.IngredientsText
+	if text == "" {
+		text = strings.Join(p.Ingredients, ", ")
+	}
+	r := Report{Analyzed: strings.TrimSpace(text) != "", Allergens: []Found{}, Traces: []Found{}, Diets: []DietResult{}}
+
+	body, traces := splitTraces(text)
+	words, traceWords := tokenize(body), tokenize(traces)
+
+	hits := map[string][]string{} // allergen id or group -> matched words
+	for _, a := range EU14 {
+		if m := compiled.allergens[a.ID].find(words); len(m) > 0 {
+			hits[a.ID] = m
+			r.Allergens = append(r.Allergens, Found{ID: a.ID, Name: a.Name, Matches: m})
+		}
+	}
+	traced := map[string]bool{}
+	for _, a := range EU14 {
+		if _, ok := hits[a.ID]; ok {
+			continue // already an ingredient, not just a trace
+		}
+		if m := compiled.allergens[a.ID].find(traceWords); len(m) > 0 {
+			traced[a.ID] = true
+			r.Traces = append(r.Traces, Found{ID: a.ID, Name: a.Name, Matches: m})
+		}
+	}
+	for group, set := range compiled.groups {
+		if m := set.find(words); len(m) > 0 {
+			hits[group] = m
+		}
+	}
+
+	labels := map[string]bool{}
+	for _, l := range p.Labels {
+		labels[scoring.NormalizeCategory(l)] = true
+	}
+	for _, diet := range Diets {
+		r.Diets = append(r.Diets, dietRules[diet].decide(diet, hits, traced, labels, r.Analyzed))
+	}
+	return r
+}
+
+func (rule dietRule) decide(diet string, hits map[string][]string, traced, labels map[string]bool, analyzed bool) DietResult {
+	res := DietResult{Diet: diet, Reasons: []string{}}
+	for _, l := range rule.labels {
+		if labels[l] {
+			res.Status = StatusYes
+			res.Reasons = append(res.Reasons, "labelled "+l)
+			return res
+		}
+	}
+	if !analyzed {
+		res.Status = StatusUnknown
+		res.Reasons = append(res.Reasons, "no ingredient list")
+		return res
+	}
+	for _, key := range rule.no {
+		if m, ok := hits[key]; ok {
+			res.Reasons = append(res.Reasons, "contains "+strings.Join(m, ", "))
+		}
+	}
+	if len(res.Reasons) > 0 {
+		res.Status = StatusNo
+		return res
+	}
+	for _, key := range rule.maybe {
+		if m, ok := hits[key]; ok {
+			res.Reasons = append(res.Reasons, "contains "+strings.Join(m, ", ")+", which may not qualify")
+		}
+	}
+	for _, key := range rule.maybeTraces {
+		if traced[key] {
+			res.Reasons = append(res.Reasons, "may contain traces of "+key)
+		}
+	}
+	if len(res.Reasons) > 0 {
+		res.Status = StatusMaybe
+		return res
+	}
+	res.Status = StatusYes
+	return res
+}
+
+// ---- matching
+
+type keyword struct {
+	words  []string
+	exact  bool // last word must equal the token (or its plural)
+	infix  bool // last word may appear anywhere inside the token
+	prefix bool // last word may start the token
+}
+
+func parseKeyword(s string) keyword {
+	k := keyword{}
+	switch {
+	case strings.HasPrefix(s, "="):
+		k.exact, s = true, s[1:]
+	case strings.HasPrefix(s, "*"):
+		k.infix, s = true, s[1:]
+	}
+	k.words = tokenize(s)
+	if !k.exact && !k.infix {
+		last := k.words[len(k.words)-1]
+		k.prefix = len(last) >= 4
+		k.exact = !k.prefix
+	}
+	return k
+}
+
+// matchAt returns how many tokens the keyword covers starting at i, 0 if it does not match
+func (k keyword) matchAt(tokens []string, i int) int {
+	n := len(k.words)
+	if i+n > len(tokens) {
+		return 0
+	}
+	for j, w := range k.words[:n-1] {
+		if !plural(tokens[i+j], w) {
+			return 0
+		}
+	}
+	last, tok := k.words[n-1], tokens[i+n-1]
+	switch {
+	case plural(tok, last):
+	case k.prefix && strings.HasPrefix(tok, last):
+	case k.infix && strings.Contains(tok, last):
+	default:
+		return 0
+	}
+	return n
+}
+
+func plural(tok, w string) bool {
+	return tok == w || tok == w+"s" || tok == w+"es"
+}
+
+type keywordSet struct {
+	keywords   []keyword
+	exclusions []keyword
+}
+
+func compileSet(keywords, exclusions []string) keywordSet {
+	s := keywordSet{}
+	for _, k := range keywords {
+		s.keywords = append(s.keywords, parseKeyword(k))
+	}
+	for _, k := range exclusions {
+		s.exclusions = append(s.exclusions, parseKeyword(k))
+	}
+	return s
+}
+
+// find returns the distinct matched words, ignoring look-alikes and negated mentions
+func (s keywordSet) find(tokens []string) []string {
+	skip := make([]bool, len(tokens))
+	for _, ex := range s.exclusions {
+		for i := range tokens {
+			for j := 0; j < ex.matchAt(tokens, i); j++ {
+				skip[i+j] = true
+			}
+		}
+	}
+
+	var found []string
+	for i := range tokens {
+		if skip[i] {
+			continue
+		}
+		for _, k := range s.keywords {
+			n := k.matchAt(tokens, i)
+			if n == 0 || negated(tokens, i, n) {
+				continue
+			}
+			if m := strings.Join(tokens[i:i+n], " "); !contains(found, m) {
+				found = append(found, m)
+			}
+			break
+		}
+	}
+	return found
+}
+
+func negated(tokens []string, i, n int) bool {
+	if i > 0 && negators[tokens[i-1]] {
+		return true
+	}
+	if i+n < len(tokens) && freeWords[tokens[i+n]] {
+		return true
+	}
+	last := tokens[i+n-1]
+	return strings.HasSuffix(last, "frei") || strings.HasSuffix(last, "free") || strings.HasSuffix(last, "vrij")
+}
+
+var compiled = func() (c struct{ allergens, groups map[string]keywordSet }) {
+	c.allergens = map[string]keywordSet{}
+	for id, kw := range allergenKeywords {
+		c.allergens[id] = compileSet(kw, allergenExclusions[id])
+	}
+	c.groups = map[string]keywordSet{}
+	for group, kw := range groupKeywords {
+		c.groups[group] = compileSet(kw, groupExclusions[group])
+	}
+	return c
+}()
+
+// ---- text
+
+var (
+	traceMarker = regexp.MustCompile(`(?:` + strings.Join(traceMarkers, "|") + `)`)
+	sentenceEnd = regexp.MustCompile(`[.;](?:\s|$)`)
+)
+
+// splitTraces separates "may contain ..." statements from the rest of the ingredient text
+func splitTraces(text string) (body, traces string) {
+	s := fold(strings.ToLower(text))
+	var tr []string
+	for {
+		loc := traceMarker.FindStringIndex(s)
+		if loc == nil {
+			break
+		}
+		end := len(s)
+		if e := sentenceEnd.FindStringIndex(s[loc[1]:]); e != nil {
+			end = loc[1] + e[0]
+		}
+		tr = append(tr, s[loc[1]:end])
+		s = s[:loc[0]] + " " + s[end:]
+	}
+	return s, strings.Join(tr, " ")
+}
+
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "æ", "ae",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "œ", "oe",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ß", "ss", "’", "'",
+)
+
+// fold strips the accents of the languages the keywords cover
+func fold(s string) string { return accents.Replace(s) }
+
+// tokenize lower-cases and folds s and splits it into runs of letters and digits
+func tokenize(s string) []string {
+	return strings.FieldsFunc(fold(strings.ToLower(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Warning is a conflict between a product and a user's dietary profile
+type Warning struct {
+	Barcode     string `json:"barcode" bson:"barcode"`
+	ProductName string `json:"product_name" bson:"product_name"`
+	Kind        string `json:"kind" bson:"kind"` // "allergen", "trace", "diet" or "ingredients"
+	Code        string `json:"code" bson:"code"` // allergen id or diet
+	Status      string `json:"status,omitempty" bson:"status,omitempty"`
+	Message     string `json:"message" bson:"message"`
+}
+
+// Check lists what in the product conflicts with the profile. Diets the product
+// only may not fit are reported with status "maybe"; unknown ones are not reported.
+// A product without an ingredient list cannot be cleared of the user's allergens,
+// so it gets an "ingredients" warning with status "unknown".
+func Check(p models.Product, profile models.DietaryProfile) []Warning {
+	r := Analyze(p)
+	out := []Warning{}
+	warn := func(kind, code, status, msg string) {
+		out = append(out, Warning{Barcode: p.Barcode, ProductName: p.Name, Kind: kind, Code: code, Status: status, Message: msg})
+	}
+	if !r.Analyzed && len(profile.Allergens) > 0 {
+		warn("ingredients", "missing", StatusUnknown, "No ingredient list, so allergens could not be checked")
+	}
+	for _, f := range r.Allergens {
+		if contains(profile.Allergens, f.ID) {
+			warn("allergen", f.ID, "", "Contains "+strings.ToLower(f.Name)+" ("+strings.Join(f.Matches, ", ")+")")
+		}
+	}
+	for _, f := range r.Traces {
+		if contains(profile.Allergens, f.ID) {
+			warn("trace", f.ID, "", "May contain traces of "+strings.ToLower(f.Name))
+		}
+	}
+	for _, d := range r.Diets {
+		if !contains(profile.Diets, d.Diet) {
+			continue
+		}
+		switch d.Status {
+		case StatusNo:
+			warn("diet", d.Diet, d.Status, "Not "+d.Diet+": "+strings.Join(d.Reasons, "; "))
+		case StatusMaybe:
+			warn("diet", d.Diet, d.Status, "Possibly not "+d.Diet+": "+strings.Join(d.Reasons, "; "))
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package allergens
+
+import (
+	"testing"
+
+	"backend/models"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		text      string
+		allergens []string
+		traces    []string
+		vegan     string
+	}{
+		{"sugar, milk powder, hazelnuts", []string{"milk", "nuts"}, nil, StatusNo},
+		{"sugar, cocoa. May contain nuts.", nil, []string{"nuts"}, StatusYes},
+		{"wheat flour. May contain tree nuts and peanuts.", []string{"gluten"}, []string{"peanuts", "nuts"}, StatusYes},
+		{"Zucker. Kann Spuren von Schalenfrüchten enthalten.", nil, []string{"nuts"}, StatusYes},
+		{"sucre, fruits à coque", []string{"nuts"}, nil, StatusYes},
+		{"azúcar, frutos de cáscara", []string{"nuts"}, nil, StatusYes},
+		{"zucchero, frutta a guscio", []string{"nuts"}, nil, StatusYes},
+		{"suiker, noten", []string{"nuts"}, nil, StatusYes},
+		{"sugar, peanuts, coconut, nutmeg, tiger nuts", []string{"peanuts"}, nil, StatusYes},
+		{"sugar, lemon rind", nil, nil, StatusYes},
+		{"Rind, Salz", nil, nil, StatusNo},
+		{"Rinderbrühe, Salz", nil, nil, StatusNo},
+		{"sugar, cacao butter, cocoa butter", nil, nil, StatusYes},
+	}
+	for _, tt := range tests {
+		r := Analyze(models.Product{IngredientsText: tt.text})
+		if got := ids(r.Allergens); !equal(got, tt.allergens) {
+			t.Errorf("%q: allergens %v, want %v", tt.text, got, tt.allergens)
+		}
+		if got := ids(r.Traces); !equal(got, tt.traces) {
+			t.Errorf("%q: traces %v, want %v", tt.text, got, tt.traces)
+		}
+		for _, d := range r.Diets {
+			if d.Diet == "vegan" && d.Status != tt.vegan {
+				t.Errorf("%q: vegan %s %v, want %s", tt.text, d.Status, d.Reasons, tt.vegan)
+			}
+		}
+	}
+}
+
+func TestCheckWithoutIngredients(t *testing.T) {
+	p := models.Product{Barcode: "04006381333931", Name: "Unlabelled"}
+	if w := Check(p, models.DietaryProfile{Diets: []string{"vegan"}}); len(w) != 0 {
+		t.Errorf("diet-only profile warned %v", w)
+	}
+	w := Check(p, models.DietaryProfile{Allergens: []string{"milk"}})
+	if len(w) != 1 || w[0].Kind != "ingredients" || w[0].Status != StatusUnknown {
+		t.Errorf("Check = %+v, want one ingredients warning", w)
+	}
+}
+
+func ids(found []Found) []string {
+	var out []string
+	for _, f := range found {
+		out = append(out, f.ID)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
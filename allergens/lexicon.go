@@ -0,0 +1,200 @@
+package allergens
+
+// Keywords are written lower-case and without accents, as produced by fold.
+// A keyword of four or more letters also matches words it starts ("hazelnut"
+// matches "hazelnuts"), shorter ones match only themselves or their plural.
+// "=word" turns prefix matching off and "*word" matches inside compounds,
+// e.g. "*milch" in "vollmilchpulver". Multi-word keywords match a run of words.
+
+// Allergen is one of the 14 allergens EU Regulation 1169/2011 requires labels to emphasise
+type Allergen struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// EU14 lists the regulated allergens in the order of Annex II
+var EU14 = []Allergen{
+	{"gluten", "Cereals containing gluten"},
+	{"crustaceans", "Crustaceans"},
+	{"eggs", "Eggs"},
+	{"fish", "Fish"},
+	{"peanuts", "Peanuts"},
+	{"soybeans", "Soybeans"},
+	{"milk", "Milk"},
+	{"nuts", "Tree nuts"},
+	{"celery", "Celery"},
+	{"mustard", "Mustard"},
+	{"sesame", "Sesame seeds"},
+	{"sulphites", "Sulphur dioxide and sulphites"},
+	{"lupin", "Lupin"},
+	{"molluscs", "Molluscs"},
+}
+
+// allergenKeywords cover English, French, German, Spanish, Italian and Dutch
+var allergenKeywords = map[string][]string{
+	"gluten": {
+		"gluten", "wheat", "=rye", "barley", "=oat", "spelt", "kamut", "triticale", "semolina", "durum",
+		"couscous", "bulgur", "=malt", "malt extract", "=malted",
+		"=ble", "froment", "seigle", "=orge", "avoine", "epeautre", "semoule",
+		"*weizen", "roggen", "gerste", "hafer", "dinkel", "grunkern",
+		"trigo", "centeno", "cebada", "avena", "espelta", "semola",
+		"frumento", "grano", "segale", "=orzo", "farro",
+		"*tarwe", "rogge", "gerst", "haver",
+	},
+	"crustaceans": {
+		"crustacean", "shrimp", "prawn", "crab", "lobster", "crayfish", "langoustine", "krill",
+		"crustace", "crevette", "homard", "langouste", "ecrevisse",
+		"krebstier", "garnele", "krabbe", "hummer", "languste",
+		"crustaceo", "gamba", "camaron", "langostino", "cangrejo", "bogavante", "langosta",
+		"crostace", "gamber", "granchio", "aragosta", "astice",
+		"schaaldier", "garnaal", "=krab", "kreeft",
+	},
+	"eggs": {
+		"=egg", "albumen", "albumin", "ovalbumin", "lysozyme", "mayonnaise",
+		"oeuf", "ovoproduit",
+		"=ei", "=eier", "eigelb", "eiklar", "*eipulver", "vollei", "huhnerei",
+		"huevo", "=yema",
+		"=uovo", "=uova", "tuorlo",
+		"eieren", "eigeel", "eiwit",
+	},
+	"fish": {
+		"fish", "anchov", "=tuna", "salmon", "=cod", "haddock", "mackerel", "sardine", "herring", "trout", "pollock", "=hake",
+		"poisson", "anchois", "=thon", "saumon", "cabillaud", "maquereau", "hareng", "truite", "=colin",
+		"*fisch", "sardelle", "lachs", "kabeljau", "makrele", "hering", "forelle",
+		"pescado", "anchoa", "=atun", "bacalao", "caballa", "sardina", "arenque",
+		"pesce", "acciug", "tonno", "merluzzo", "sgombro", "aringa",
+		"=vis", "ansjovis", "tonijn", "=zalm", "makreel", "haring",
+	},
+	"peanuts": {
+		"peanut", "groundnut", "arachis",
+		"arachide", "cacahuete",
+		"erdnuss",
+		"=mani",
+		"arachidi",
+		"pinda", "aardnoot",
+	},
+	"soybeans": {
+		"=soy", "soya", "soybean", "soja", "edamame", "=tofu",
+	},
+	"milk": {
+		"milk", "lactose", "whey", "cream", "butter", "cheese", "casein", "=ghee", "yogurt", "yoghurt", "=curd",
+		"=lait", "laitier", "lactoserum", "=creme", "beurre", "fromage",
+		"*milch", "laktose", "molke", "sahne", "=rahm", "kase", "kasein", "quark", "joghurt",
+		"leche", "lacteo", "lactosa", "=nata", "mantequilla", "queso", "yogur",
+		"latte", "lattosio", "panna", "=burro", "formaggi", "siero di latte",
+		"=melk", "*melk", "=wei", "=room", "=boter", "=kaas",
+	},
+	"nuts": {
+		"=nut", "tree nut", "almond", "hazelnut", "walnut", "cashew", "pecan", "brazil nut", "pistachio", "macadamia", "queensland nut",
+		"fruit a coque", "amande", "noisette", "=noix", "pistache",
+		"schalenfrucht", "mandel", "*nuss", "pistazie",
+		"fruto de cascara", "fruto seco", "almendra", "avellana", "=nuez", "=nueces", "anacardo", "pistacho",
+		"frutta a guscio", "frutta secca", "mandorl", "nocciol", "=noce", "=noci", "anacardi", "pistacchi",
+		"schaalvrucht", "=noten", "amandel", "*noot",
+	},
+	"celery": {
+		"celery", "celeriac", "celeri", "sellerie", "=apio", "sedano", "*selderij",
+	},
+	"mustard": {
+		"mustard", "moutarde", "senf", "mostaza", "senape", "mosterd",
+	},
+	"sesame": {
+		"sesam", "tahin",
+	},
+	"sulphites": {
+		"*sulphit", "*sulfit", "sulphur dioxide", "sulfur dioxide", "sulfiet",
+		"=e220", "=e221", "=e222", "=e223", "=e224", "=e225", "=e226", "=e227", "=e228",
+		"anhydride sulfureux", "schwefeldioxid", "sulfito", "anhidrido sulfuroso", "solfit", "anidride solforosa", "zwaveldioxide",
+	},
+	"lupin": {
+		"lupin", "lupino",
+	},
+	"molluscs": {
+		"mollusc", "mollusk", "mussel", "oyster", "=clam", "scallop", "squid", "octopus", "snail", "cuttlefish", "whelk", "abalone",
+		"mollusque", "moule", "huitre", "palourde", "calamar", "encornet", "poulpe", "escargot", "seiche",
+		"weichtier", "muschel", "auster", "tintenfisch", "schnecke", "kalmar",
+		"molusco", "mejillon", "ostra", "almeja", "pulpo", "sepia",
+		"mollusch", "cozze", "ostrica", "vongole", "polpo", "seppia",
+		"weekdier", "mossel", "oester", "inktvis", "=slak",
+	},
+}
+
+// allergenExclusions are look-alikes that must not count as the allergen
+var allergenExclusions = map[string][]string{
+	"gluten":  {"buckwheat", "grano saraceno", "ble noir", "sarrasin", "buchweizen", "boekweit", "trigo sarraceno"},
+	"eggs":    {"eggplant"},
+	"fish":    {"tintenfisch", "inktvis", "cuttlefish", "shellfish"},
+	"peanuts": {"manioc", "manihot", "manioca"},
+	"milk": {
+		"coconut milk", "almond milk", "oat milk", "soy milk", "soya milk", "rice milk", "cocoa butter", "cacao butter", "shea butter",
+		"peanut butter", "nut butter", "butternut", "cream of tartar", "lait de coco", "lait d amande", "beurre de cacao",
+		"laitue", "kokosmilch", "mandelmilch", "hafermilch", "sojamilch", "manteca de cacao", "leche de coco",
+		"burro di cacao", "latte di cocco", "kokosmelk", "cacaoboter",
+	},
+	"nuts": {
+		"ground nut", "tiger nut",
+		"erdnuss", "kokosnuss", "muskatnuss", "aardnoot", "kokosnoot", "nootmuskaat", "pindanoot", "noix de coco",
+		"noix de muscade", "nuez moscada", "nuez de coco", "noce moscata", "noce di cocco",
+	},
+}
+
+// groupKeywords find animal and other ingredients that decide diet compatibility
+var groupKeywords = map[string][]string{
+	"meat": {
+		"meat", "beef", "chicken", "turkey", "=lamb", "mutton", "=veal", "tallow", "sausage", "salami", "=duck", "goose", "venison", "=rabbit",
+		"viande", "boeuf", "poulet", "dinde", "agneau", "=veau", "suif", "canard",
+		"*fleisch", "=rind", "rinder", "huhn", "hahnchen", "pute", "truthahn", "lamm", "kalb", "talg", "=ente", "enten",
+		"carne", "ternera", "pollo", "pavo", "cordero", "=pato",
+		"manzo", "tacchino", "agnello", "vitello", "anatra",
+		"vlees", "rund", "=kip", "kalkoen", "=lam", "kalf",
+	},
+	"pork": {
+		"pork", "=ham", "bacon", "lard", "=porc", "jambon", "saindoux",
+		"*schwein", "schinken", "speck", "schmalz",
+		"cerdo", "jamon", "tocino", "manteca de cerdo",
+		"maiale", "prosciutto", "pancetta", "strutto",
+		"varken", "=spek", "reuzel",
+	},
+	"gelatin": {"gelatin", "gelatina"},
+	"carmine": {"carmin", "cochineal", "cochenille", "=e120", "karmin"},
+	"honey":   {"honey", "=miel", "honig", "=miele", "honing"},
+	"bee_products": {
+		"beeswax", "shellac", "=e901", "=e904", "cire d abeille", "bienenwachs", "schellack", "cera de abeja", "cera d api", "bijenwas",
+	},
+	"rennet": {"rennet", "presure", "=lab", "cuajo", "caglio", "stremsel"},
+	"alcohol": {
+		"alcohol", "ethanol", "=wine", "=beer", "=rum", "brandy", "liqueur", "whisk", "vodka", "cognac",
+		"alcool", "=vin", "biere",
+		"alkohol", "=wein", "=bier", "likor", "weinbrand",
+		"=vino", "cerveza", "licor",
+		"birra", "liquore",
+		"=wijn", "likeur",
+	},
+}
+
+var groupExclusions = map[string][]string{
+	"meat": {
+		"huhnerei", "meat free", "hahnchenei",
+		// English "rind" is peel or crust, German "Rind" is beef
+		"lemon rind", "lime rind", "orange rind", "citrus rind", "grapefruit rind", "melon rind", "fruit rind", "cheese rind",
+	},
+	"pork":    {"hamamelis"},
+	"rennet":  {"microbial rennet", "vegetable rennet", "vegetarian rennet"},
+	"alcohol": {"wine vinegar", "vinaigre de vin", "weinessig", "aceto di vino", "vinagre de vino", "wijnazijn", "sugar alcohol"},
+}
+
+// traceMarkers introduce a "may contain" statement that runs to the end of the sentence
+var traceMarkers = []string{
+	`may (?:also )?contain`, `traces? of`, `traces?\s*:`,
+	`peut contenir`, `traces? (?:eventuelles? )?(?:de|d')`,
+	`kann spuren`, `spuren von`,
+	`puede contener`, `trazas de`,
+	`puo contenere`, `tracce di`,
+	`kan sporen`, `sporen van`, `bevat mogelijk`,
+}
+
+// negators before a keyword and freeWords after it cancel the match: "sans gluten", "gluten-free"
+var (
+	negators  = map[string]bool{"no": true, "without": true, "sans": true, "ohne": true, "sin": true, "senza": true, "zonder": true}
+	freeWords = map[string]bool{"free": true, "frei": true, "libre": true, "vrij": true}
+)
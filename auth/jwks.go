@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var (
+	keysMu  sync.RWMutex
+	rsaKeys = map[string]*rsa.PublicKey{}
+)
+
+// LoadJWKS reads RSA public keys from a local JWKS file ({"keys": [...]}) for RS256 verification
+func LoadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil {
+			return err
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks: no RS256 keys found in " + path)
+	}
+
+	keysMu.Lock()
+	rsaKeys = keys
+	keysMu.Unlock()
+	return nil
+}
+
+// rsaKey looks up a key by kid; a token without kid is accepted when exactly one key is loaded
+func rsaKey(kid string) *rsa.PublicKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	if k, ok := rsaKeys[kid]; ok {
+		return k
+	}
+	if kid == "" && len(rsaKeys) == 1 {
+		for _, k := range rsaKeys {
+			return k
+		}
+	}
+	return nil
+}
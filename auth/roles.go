@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"backend/utils"
+)
+
+type Role string
+
+const (
+	RoleViewer      Role = "viewer"
+	RoleContributor Role = "contributor"
+	RoleModerator   Role = "moderator"
+	RoleAdmin       Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:      1,
+	RoleContributor: 2,
+	RoleModerator:   3,
+	RoleAdmin:       4,
+}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r grants everything min grants
+func (r Role) AtLeast(min Role) bool {
+	return roleRank[r] >= roleRank[min]
+}
+
+// UserRole returns the role stored by the middleware; anonymous and unknown roles count as viewer
+func UserRole(ctx context.Context) Role {
+	role, _ := ctx.Value(roleKey).(Role)
+	if !role.Valid() {
+		return RoleViewer
+	}
+	return role
+}
+
+// RequireRole wraps a handler so only users with at least the given role reach it (403 otherwise).
+// It must run behind Protected so the user's current role is in the context.
+func RequireRole(min Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !UserRole(r.Context()).AtLeast(min) {
+			utils.Error(w, utils.Forbidden("Forbidden: requires role "+string(min)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"backend/config"
+)
+
+var (
+	ErrNoToken      = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Claims is the JWT payload issued at login. Subject holds the user's hex ObjectID.
+// Role tells clients the role at login; the middleware never trusts it, see RoleFunc.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// IssueToken returns a signed HS256 JWT for the given user id and role
+func IssueToken(userID string, role Role) (string, error) {
+	now := time.Now()
+	claims := Claims{Subject: userID, Role: string(role), IssuedAt: now.Unix(), ExpiresAt: now.Add(config.TokenTTL).Unix()}
+
+	h, _ := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+	return signingInput + "." + b64.EncodeToString(signHS256(signingInput)), nil
+}
+
+// ParseToken verifies the signature and expiry of a JWT.
+// HS256 tokens are checked against config.JWTSecret, RS256 tokens against the loaded JWKS.
+func ParseToken(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	raw, err := b64.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(raw, &h) != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	switch h.Alg {
+	case "HS256":
+		if !hmac.Equal(sig, signHS256(signingInput)) {
+			return nil, ErrInvalidToken
+		}
+	case "RS256":
+		key := rsaKey(h.Kid)
+		if key == nil {
+			return nil, ErrInvalidToken
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return nil, ErrInvalidToken
+		}
+	default:
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	raw, err = b64.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(raw, &claims) != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func signHS256(input string) []byte {
+	mac := hmac.New(sha256.New, []byte(config.JWTSecret))
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/config"
+)
+
+// sign builds a JWT from hdr and claims, signed by signer over the signing input
+func sign(t *testing.T, hdr header, claims interface{}, signer func(input string) []byte) string {
+	t.Helper()
+	h, err := json.Marshal(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	return input + "." + b64.EncodeToString(signer(input))
+}
+
+func hs256(secret []byte) func(string) []byte {
+	return func(input string) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(input))
+		return mac.Sum(nil)
+	}
+}
+
+func rs256(t *testing.T, key *rsa.PrivateKey) func(string) []byte {
+	return func(input string) []byte {
+		digest := sha256.Sum256([]byte(input))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+}
+
+// writeJWKS saves the public halves of keys, by kid, as a JWKS file
+func writeJWKS(t *testing.T, keys map[string]*rsa.PrivateKey) string {
+	t.Helper()
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, k := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA", Kid: kid, Alg: "RS256",
+			N: b64.EncodeToString(k.N.Bytes()),
+			E: b64.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func rsaKeyPair(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestParseToken(t *testing.T) {
+	secret := config.JWTSecret
+	config.JWTSecret = "test-secret-of-at-least-32-bytes!"
+	t.Cleanup(func() { config.JWTSecret = secret })
+
+	primary, other, stranger := rsaKeyPair(t), rsaKeyPair(t), rsaKeyPair(t)
+	if err := LoadJWKS(writeJWKS(t, map[string]*rsa.PrivateKey{"primary": primary, "other": other})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rsaKeys = map[string]*rsa.PublicKey{} })
+	publicDER, err := x509.MarshalPKIXPublicKey(&primary.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	valid := Claims{Subject: "64b000000000000000000001", Role: "viewer", IssuedAt: now, ExpiresAt: now + 60}
+	expired := Claims{Subject: valid.Subject, IssuedAt: now - 120, ExpiresAt: now - 60}
+	hsSecret := hs256([]byte(config.JWTSecret))
+
+	tests := []struct {
+		name  string
+		token string
+		want  error // nil when the token is accepted
+	}{
+		{"HS256", sign(t, header{Alg: "HS256", Typ: "JWT"}, valid, hsSecret), nil},
+		{"HS256 expired", sign(t, header{Alg: "HS256"}, expired, hsSecret), ErrExpiredToken},
+		{"HS256 wrong secret", sign(t, header{Alg: "HS256"}, valid, hs256([]byte("another-secret-of-32-bytes-or-more"))), ErrInvalidToken},
+		{"HS256 without subject", sign(t, header{Alg: "HS256"}, Claims{ExpiresAt: now + 60}, hsSecret), ErrInvalidToken},
+		{"RS256", sign(t, header{Alg: "RS256", Kid: "primary"}, valid, rs256(t, primary)), nil},
+		{"RS256 second key", sign(t, header{Alg: "RS256", Kid: "other"}, valid, rs256(t, other)), nil},
+		{"RS256 expired", sign(t, header{Alg: "RS256", Kid: "primary"}, expired, rs256(t, primary)), ErrExpiredToken},
+		{"RS256 unknown kid", sign(t, header{Alg: "RS256", Kid: "rotated-out"}, valid, rs256(t, primary)), ErrInvalidToken},
+		{"RS256 no kid with several keys", sign(t, header{Alg: "RS256"}, valid, rs256(t, primary)), ErrInvalidToken},
+		{"RS256 kid of another key", sign(t, header{Alg: "RS256", Kid: "other"}, valid, rs256(t, primary)), ErrInvalidToken},
+		{"RS256 unknown signer", sign(t, header{Alg: "RS256", Kid: "primary"}, valid, rs256(t, stranger)), ErrInvalidToken},
+		// the classic algorithm confusion: HMAC keyed with the RSA public key
+		{"HS256 with the RSA public key", sign(t, header{Alg: "HS256", Kid: "primary"}, valid, hs256(publicDER)), ErrInvalidToken},
+		{"none", sign(t, header{Alg: "none"}, valid, func(string) []byte { return nil }), ErrInvalidToken},
+		{"lower-case alg", sign(t, header{Alg: "hs256"}, valid, hsSecret), ErrInvalidToken},
+		{"two parts", "a.b", ErrInvalidToken},
+		{"garbage header", "!!.e30.c2ln", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token)
+			if tt.want == nil {
+				if err != nil || claims.Subject != valid.Subject {
+					t.Fatalf("ParseToken = %+v, %v; want the claims", claims, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ParseToken = %+v, %v; want %v", claims, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTokenSingleKeyWithoutKid(t *testing.T) {
+	key := rsaKeyPair(t)
+	if err := LoadJWKS(writeJWKS(t, map[string]*rsa.PrivateKey{"only": key})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rsaKeys = map[string]*rsa.PublicKey{} })
+
+	now := time.Now().Unix()
+	token := sign(t, header{Alg: "RS256"}, Claims{Subject: "user", ExpiresAt: now + 60}, rs256(t, key))
+	if _, err := ParseToken(token); err != nil {
+		t.Errorf("token without kid against the only key: %v", err)
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	token, err := IssueToken("64b000000000000000000001", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(token)
+	if err != nil || claims.Subject != "64b000000000000000000001" || claims.Role != string(RoleAdmin) {
+		t.Fatalf("ParseToken(IssueToken) = %+v, %v", claims, err)
+	}
+	if ttl := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second; ttl != config.TokenTTL.Truncate(time.Second) {
+		t.Errorf("token lives %v, want %v", ttl, config.TokenTTL)
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	for name, data := range map[string]string{
+		"empty.json":   `{"keys": []}`,
+		"ec-only.json": `{"keys": [{"kty": "EC", "kid": "ec"}]}`,
+		"rs512.json":   `{"keys": [{"kty": "RSA", "kid": "r", "alg": "RS512", "n": "AQAB", "e": "AQAB"}]}`,
+		"bad-n.json":   `{"keys": [{"kty": "RSA", "kid": "r", "n": "!!", "e": "AQAB"}]}`,
+		"broken.json":  `{"keys": `,
+	} {
+		if err := LoadJWKS(write(name, data)); err == nil {
+			t.Errorf("LoadJWKS(%s) accepted it", name)
+		}
+	}
+	if err := LoadJWKS(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadJWKS(missing file) accepted it")
+	}
+}
@@ -0,0 +1,146 @@
+// Package barcode validates retail barcodes and normalizes them to GTIN-14,
+// the form products are stored and looked up under.
+package barcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the symbology a code was recognized as
+type Kind string
+
+const (
+	EAN8   Kind = "EAN-8"
+	UPCE   Kind = "UPC-E"
+	UPCA   Kind = "UPC-A"
+	EAN13  Kind = "EAN-13"
+	GTIN14 Kind = "GTIN-14"
+)
+
+// Error describes why a code was rejected
+type Error struct {
+	Code   string `json:"barcode"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid barcode %q: %s", e.Code, e.Reason)
+}
+
+// Normalize validates a code and returns its GTIN-14 form (14 digits, zero padded).
+// Spaces and dashes are ignored. 8-digit codes are read as EAN-8 when the check digit
+// matches, otherwise as UPC-E with its number system and check digit. The 6 and 7
+// digit UPC-E forms carry no check digit, so they are rejected like any other length.
+func Normalize(code string) (string, Kind, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(code))
+
+	if digits == "" {
+		return "", "", &Error{Code: code, Reason: "empty"}
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", "", &Error{Code: code, Reason: "must contain only digits"}
+		}
+	}
+	if strings.Trim(digits, "0") == "" {
+		return "", "", &Error{Code: code, Reason: "all zeros"}
+	}
+
+	switch len(digits) {
+	case 8:
+		if validCheck(digits) {
+			return pad(digits), EAN8, nil
+		}
+		if digits[0] == '0' || digits[0] == '1' {
+			if upca, err := expandUPCE(digits); err == nil {
+				return pad(upca), UPCE, nil
+			}
+		}
+		return "", "", &Error{Code: code, Reason: "check digit mismatch"}
+	case 12, 13, 14:
+		if !validCheck(digits) {
+			return "", "", &Error{Code: code, Reason: "check digit mismatch"}
+		}
+		kind := map[int]Kind{12: UPCA, 13: EAN13, 14: GTIN14}[len(digits)]
+		return pad(digits), kind, nil
+	default:
+		return "", "", &Error{Code: code, Reason: fmt.Sprintf("unsupported length %d (want 8, 12, 13 or 14 digits)", len(digits))}
+	}
+}
+
+// GTIN returns just the normalized GTIN-14 form of code
+func GTIN(code string) (string, error) {
+	gtin, _, err := Normalize(code)
+	return gtin, err
+}
+
+// Compact returns the shortest common form of a GTIN-14: EAN-8 for padded 8-digit
+// codes, otherwise EAN-13 (UPC-A keeps its leading zero, as Open Food Facts stores it)
+func Compact(gtin string) string {
+	if len(gtin) != 14 {
+		return gtin
+	}
+	if strings.HasPrefix(gtin, "000000") {
+		return gtin[6:]
+	}
+	if gtin[0] == '0' {
+		return gtin[1:]
+	}
+	return gtin
+}
+
+// CheckDigit computes the GS1 mod-10 check digit for the digits preceding it
+func CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		d := int(body[len(body)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func validCheck(digits string) bool {
+	n := len(digits)
+	return CheckDigit(digits[:n-1]) == digits[n-1]
+}
+
+// expandUPCE converts an 8-digit UPC-E code (number system, 6 digits, check
+// digit) to its 12-digit UPC-A equivalent, verifying the check digit
+func expandUPCE(code string) (string, error) {
+	ns, body, check := code[0], code[1:7], code[7]
+	if ns != '0' && ns != '1' {
+		return "", fmt.Errorf("UPC-E number system must be 0 or 1")
+	}
+
+	var manufacturer, product string
+	switch last := body[5]; last {
+	case '0', '1', '2':
+		manufacturer, product = body[0:2]+string(last)+"00", "00"+body[2:5]
+	case '3':
+		manufacturer, product = body[0:3]+"00", "000"+body[3:5]
+	case '4':
+		manufacturer, product = body[0:4]+"0", "0000"+body[4:5]
+	default:
+		manufacturer, product = body[0:5], "0000"+string(last)
+	}
+
+	upca := string(ns) + manufacturer + product
+	digit := CheckDigit(upca)
+	if check != digit {
+		return "", fmt.Errorf("check digit mismatch")
+	}
+	return upca + string(digit), nil
+}
+
+func pad(digits string) string {
+	return strings.Repeat("0", 14-len(digits)) + digits
+}
@@ -0,0 +1,60 @@
+package barcode
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code string
+		gtin string
+		kind Kind // "" when the code is rejected
+	}{
+		{"96385074", "00000096385074", EAN8},
+		{"04252614", "00042100005264", UPCE},
+		{"042100005264", "00042100005264", UPCA},
+		{"4006381333931", "04006381333931", EAN13},
+		{" 400-6381-333931 ", "04006381333931", EAN13},
+		{"04006381333931", "04006381333931", GTIN14},
+
+		{"4006381333932", "", ""}, // check digit
+		{"04252615", "", ""},      // neither EAN-8 nor UPC-E check digit
+		{"24252613", "", ""},      // UPC-E number system
+		{"123456", "", ""},        // UPC-E without a check digit
+		{"425261", "", ""},
+		{"0425261", "", ""},
+		{"0000000", "", ""},
+		{"00000000", "", ""}, // passes the check, but names nothing
+		{"00000000000000", "", ""},
+		{"12345", "", ""},
+		{"40063813339310", "", ""},
+		{"4006381333931x", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		gtin, kind, err := Normalize(tt.code)
+		if tt.kind == "" {
+			if err == nil {
+				t.Errorf("Normalize(%q) = %s (%s), want an error", tt.code, gtin, kind)
+			} else if _, ok := err.(*Error); !ok {
+				t.Errorf("Normalize(%q) error %T, want *Error", tt.code, err)
+			}
+			continue
+		}
+		if err != nil || gtin != tt.gtin || kind != tt.kind {
+			t.Errorf("Normalize(%q) = %s, %s, %v; want %s, %s", tt.code, gtin, kind, err, tt.gtin, tt.kind)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	for gtin, want := range map[string]string{
+		"00000096385074": "96385074",
+		"00042100005264": "0042100005264",
+		"04006381333931": "4006381333931",
+		"14006381333938": "14006381333938",
+		"123":            "123",
+	} {
+		if got := Compact(gtin); got != want {
+			t.Errorf("Compact(%s) = %s, want %s", gtin, got, want)
+		}
+	}
+}
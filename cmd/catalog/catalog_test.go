@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"backend/models"
+	"backend/repository"
+)
+
+func TestReadCSV(t *testing.T) {
+	in := "barcode,name,ecoScore,categories,price\n" +
+		"3017620422003,Nutella,30,spreads | sweet-spreads,3.5\n" +
+		"5449000000996,Coca-Cola,high,,\n" +
+		"\"unterminated,Broken\n"
+	rows, err := readCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("read %d rows, want 3", len(rows))
+	}
+	p := rows[0].product
+	if rows[0].err != nil || rows[0].line != 2 || p.Name != "Nutella" || p.EcoScore != 30 || p.Price != 3.5 ||
+		len(p.Categories) != 2 || p.Categories[1] != "sweet-spreads" {
+		t.Errorf("row 1 = %+v, %v", p, rows[0].err)
+	}
+	if rows[1].err == nil || !strings.Contains(rows[1].err.Error(), "ecoScore") {
+		t.Errorf("row 2 error = %v, want the bad ecoScore", rows[1].err)
+	}
+	if rows[2].err == nil {
+		t.Error("row 3 with a broken quote was accepted")
+	}
+
+	if _, err := readCSV(strings.NewReader("name,brand\nNutella,Ferrero\n")); err == nil {
+		t.Error("CSV without a barcode column was accepted")
+	}
+	if _, err := readRows("xml", strings.NewReader("")); err == nil {
+		t.Error("unknown format was accepted")
+	}
+}
+
+func TestImportRows(t *testing.T) {
+	ctx := context.Background()
+	products := repository.NewMemory().Products
+	if err := products.Upsert(ctx, models.Product{Barcode: "03017620422003", Name: "Old name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	in := strings.Join([]string{
+		`{"barcode": "3017620422003", "name": "Nutella"}`,
+		`{"barcode": "5449000000996", "name": "Coca-Cola", "raw_data": "{\"categories_tags\": [\"en:beverages\", \"en:sodas\"], \"ingredients_text\": \"water, sugar\"}"}`,
+		`{"barcode": "05449000000996", "name": "Coca-Cola again"}`,
+		`{"barcode": "12345", "name": "Short"}`,
+		`{"barcode": `,
+		`{"barcode": "3033490004743", "name": "Yaourt", "nutrients": {"proteins_100g": 4.2}}`,
+	}, "\n")
+	rows, err := readRows("jsonl", strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a dry run classifies the rows without writing them
+	s, err := importRows(ctx, products, rows, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.inserted != 2 || s.updated != 1 || s.duplicates != 1 || len(s.rejected) != 2 {
+		t.Errorf("dry run summary = %+v", s)
+	}
+	if p, _ := products.FindByBarcode(ctx, "03017620422003"); p.Name != "Old name" {
+		t.Errorf("dry run wrote %q", p.Name)
+	}
+	if _, err := products.FindByBarcode(ctx, "05449000000996"); err != repository.ErrNotFound {
+		t.Errorf("dry run inserted a product: %v", err)
+	}
+
+	s, err = importRows(ctx, products, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.inserted != 2 || s.updated != 1 || s.duplicates != 1 || len(s.rejected) != 2 {
+		t.Errorf("summary = %+v", s)
+	}
+	if s.rejected[0].line != 4 || s.rejected[1].line != 5 {
+		t.Errorf("rejected lines = %+v, want 4 and 5", s.rejected)
+	}
+	var out bytes.Buffer
+	s.print(&out, false)
+	if !strings.Contains(out.String(), "inserted: 2\nupdated: 1\nrejected: 2\nduplicate: 1\n") {
+		t.Errorf("summary printed as:\n%s", out.String())
+	}
+
+	if p, _ := products.FindByBarcode(ctx, "03017620422003"); p.Name != "Nutella" {
+		t.Errorf("update kept name %q", p.Name)
+	}
+	// rows are backfilled like products added through the API
+	coke, err := products.FindByBarcode(ctx, "05449000000996")
+	if err != nil || coke.Category != "sodas" || coke.IngredientsText != "water, sugar" || coke.CreatedAt.IsZero() {
+		t.Errorf("backfilled product = %+v, %v", coke, err)
+	}
+	yaourt, err := products.FindByBarcode(ctx, "03033490004743")
+	if err != nil || yaourt.Nutrition == nil || yaourt.Nutrition.Proteins == nil || *yaourt.Nutrition.Proteins != 4.2 {
+		t.Errorf("nutrition not built from nutrients: %+v, %v", yaourt.Nutrition, err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"backend/importer"
+	"backend/models"
+)
+
+// row is one parsed input record; err is set when the record was rejected
+type row struct {
+	line    int
+	product models.Product
+	err     error
+}
+
+// csvColumns is the CSV layout for import and export. List fields are "|" separated.
+var csvColumns = []string{
+	"barcode", "name", "brand", "ecoScore", "description", "image_url", "category",
+	"quantity", "net_weight_g", "categories", "labels", "packaging", "origins",
+	"ingredients_text", "ecoscore_grade", "price",
+}
+
+func readRows(format string, r io.Reader) ([]row, error) {
+	switch format {
+	case "csv":
+		return readCSV(r)
+	case "jsonl":
+		return readLines(r, func(raw []byte) (models.Product, error) {
+			var p models.Product
+			err := json.Unmarshal(raw, &p)
+			return p, err
+		})
+	case "off":
+		return readLines(r, importer.Normalize)
+	default:
+		return nil, fmt.Errorf("unknown format %q (want csv, jsonl or off)", format)
+	}
+}
+
+func readCSV(r io.Reader) ([]row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.TrimSpace(name)] = i
+	}
+	if _, ok := col["barcode"]; !ok {
+		return nil, fmt.Errorf("csv header has no barcode column")
+	}
+
+	var rows []row
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			rows = append(rows, row{line: line, err: err})
+			continue
+		}
+		get := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		list := func(name string) []string {
+			var out []string
+			for _, v := range strings.Split(get(name), "|") {
+				if v = strings.TrimSpace(v); v != "" {
+					out = append(out, v)
+				}
+			}
+			return out
+		}
+
+		p := models.Product{
+			Barcode:         get("barcode"),
+			Name:            get("name"),
+			Brand:           get("brand"),
+			Description:     get("description"),
+			ImageURL:        get("image_url"),
+			Category:        get("category"),
+			Quantity:        get("quantity"),
+			Categories:      list("categories"),
+			Labels:          list("labels"),
+			Packaging:       list("packaging"),
+			Origins:         list("origins"),
+			IngredientsText: get("ingredients_text"),
+			EcoscoreGrade:   get("ecoscore_grade"),
+		}
+		r := row{line: line, product: p}
+		if v := get("ecoScore"); v != "" {
+			if r.product.EcoScore, err = strconv.Atoi(v); err != nil {
+				r.err = fmt.Errorf("ecoScore: %v", err)
+			}
+		}
+		if v := get("net_weight_g"); v != "" && r.err == nil {
+			if r.product.NetWeightG, err = strconv.ParseFloat(v, 64); err != nil {
+				r.err = fmt.Errorf("net_weight_g: %v", err)
+			}
+		}
+		if v := get("price"); v != "" && r.err == nil {
+			if r.product.Price, err = strconv.ParseFloat(v, 64); err != nil {
+				r.err = fmt.Errorf("price: %v", err)
+			}
+		}
+		rows = append(rows, r)
+	}
+}
+
+func readLines(r io.Reader, parse func([]byte) (models.Product, error)) ([]row, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	var rows []row
+	for line := 1; ; line++ {
+		raw, err := br.ReadBytes('\n')
+		if len(strings.TrimSpace(string(raw))) > 0 {
+			p, perr := parse(raw)
+			rows = append(rows, row{line: line, product: p, err: perr})
+		}
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+	}
+}
+
+func writeProducts(format string, w io.Writer, products []models.Product) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(csvColumns)
+		for _, p := range products {
+			cw.Write([]string{
+				p.Barcode, p.Name, p.Brand, strconv.Itoa(p.EcoScore), p.Description, p.ImageURL, p.Category,
+				p.Quantity, strconv.FormatFloat(p.NetWeightG, 'f', -1, 64), strings.Join(p.Categories, "|"),
+				strings.Join(p.Labels, "|"), strings.Join(p.Packaging, "|"), strings.Join(p.Origins, "|"),
+				p.IngredientsText, p.EcoscoreGrade, strconv.FormatFloat(p.Price, 'f', -1, 64),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "jsonl", "off":
+		enc := json.NewEncoder(w)
+		for _, p := range products {
+			var v interface{} = p
+			if format == "off" {
+				v = importer.ToOFF(p)
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (want csv, jsonl or off)", format)
+	}
+}
@@ -0,0 +1,255 @@
+// Command catalog bulk imports and exports the `products` collection.
+//
+//	catalog import -format csv|jsonl|off -file products.csv [-dry-run]
+//	catalog export -format csv|jsonl|off [-file out.jsonl]
+//	catalog normalize-barcodes [-dry-run]
+//	catalog rescore [-dry-run]
+//
+// Imports upsert by GTIN-14 barcode, like POST /api/products/add, including its
+// backfill of structured fields from raw Open Food Facts data. Rows with an
+// invalid barcode are rejected and repeated barcodes within one file are skipped
+// as duplicates. normalize-barcodes rewrites existing products to GTIN-14 and
+// reports codes it cannot convert or that collide with an existing product.
+// rescore re-saves every product so its Nutri-Score and Eco-Score are
+// recomputed with the current rules and emission factors.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"backend/barcode"
+	"backend/config"
+	"backend/db"
+	"backend/importer"
+	"backend/repository"
+	"backend/scoring"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cmd := os.Args[1]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	format := fs.String("format", "jsonl", "file format: csv, jsonl or off (Open Food Facts JSONL dump)")
+	file := fs.String("file", "", "input/output file (default stdin/stdout)")
+	dryRun := fs.Bool("dry-run", false, "import: validate and report without writing")
+	fs.Parse(os.Args[2:])
+
+	config.LoadEnv()
+	db.ConnectMongo()
+	products := repository.NewMongo(db.DB).Products
+	ctx := context.Background()
+
+	switch cmd {
+	case "import":
+		in := io.Reader(os.Stdin)
+		if *file != "" {
+			f, err := os.Open(*file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			in = f
+		}
+		rows, err := readRows(*format, in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		summary, err := importRows(ctx, products, rows, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		summary.print(os.Stderr, *dryRun)
+
+	case "export":
+		out := io.Writer(os.Stdout)
+		if *file != "" {
+			f, err := os.Create(*file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			out = f
+		}
+		all, err := products.List(ctx, repository.ListQuery{})
+		if err != nil {
+			log.Fatal(err)
+		}
+		list := all.Items
+		if err := writeProducts(*format, out, list); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("exported %d products", len(list))
+
+	case "normalize-barcodes":
+		if err := normalizeBarcodes(ctx, products, *dryRun); err != nil {
+			log.Fatal(err)
+		}
+
+	case "rescore":
+		if err := rescore(ctx, products, *dryRun); err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		usage()
+	}
+}
+
+func normalizeBarcodes(ctx context.Context, products repository.ProductRepo, dryRun bool) error {
+	all, err := products.List(ctx, repository.ListQuery{})
+	if err != nil {
+		return err
+	}
+	list := all.Items
+
+	changed, invalid, conflicts := 0, 0, 0
+	for _, p := range list {
+		gtin, err := barcode.GTIN(p.Barcode)
+		if err != nil {
+			invalid++
+			log.Printf("  skip %q: %v", p.Barcode, err)
+			continue
+		}
+		if gtin == p.Barcode {
+			continue
+		}
+		if !dryRun {
+			err = products.ChangeBarcode(ctx, p.Barcode, gtin)
+			if err == repository.ErrDuplicate {
+				conflicts++
+				log.Printf("  conflict %q: %s already exists", p.Barcode, gtin)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		changed++
+	}
+	log.Printf("normalized: %d\ninvalid: %d\nconflicts: %d", changed, invalid, conflicts)
+	return nil
+}
+
+// rescore upserts every product, which recomputes its scores; with dryRun it only counts the changes
+func rescore(ctx context.Context, products repository.ProductRepo, dryRun bool) error {
+	all, err := products.List(ctx, repository.ListQuery{})
+	if err != nil {
+		return err
+	}
+
+	changed, unscored := 0, 0
+	for _, p := range all.Items {
+		importer.Backfill(&p)
+		before := p.EcoScore
+		scored := p
+		scoring.ScoreProduct(&scored)
+		if scored.EcoScoreBreakdown.Grade == "" {
+			unscored++
+		}
+		if scored.EcoScore != before {
+			changed++
+			log.Printf("  %s: ecoScore %d -> %d", p.Barcode, before, scored.EcoScore)
+		}
+		if !dryRun {
+			if err := products.Upsert(ctx, p); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("rescored: %d\necoScore changed: %d\nno emission factor: %d", len(all.Items), changed, unscored)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import|export|normalize-barcodes|rescore -format csv|jsonl|off [-file path] [-dry-run]")
+	os.Exit(2)
+}
+
+type rejection struct {
+	line   int
+	reason string
+}
+
+type summary struct {
+	inserted, updated, duplicates int
+	rejected                      []rejection
+}
+
+func (s summary) print(w io.Writer, dryRun bool) {
+	mode := ""
+	if dryRun {
+		mode = " (dry run, nothing written)"
+	}
+	fmt.Fprintf(w, "inserted: %d\nupdated: %d\nrejected: %d\nduplicate: %d%s\n",
+		s.inserted, s.updated, len(s.rejected), s.duplicates, mode)
+	for _, r := range s.rejected {
+		fmt.Fprintf(w, "  line %d: %s\n", r.line, r.reason)
+	}
+}
+
+const lookupBatch = 500
+
+// importRows validates rows, classifies them as insert/update against the catalog and upserts them
+func importRows(ctx context.Context, products repository.ProductRepo, rows []row, dryRun bool) (summary, error) {
+	var s summary
+	seen := map[string]bool{}
+	var valid []row
+	for _, r := range rows {
+		if r.err == nil {
+			r.product.Barcode, r.err = barcode.GTIN(r.product.Barcode)
+		}
+		if r.err != nil {
+			s.rejected = append(s.rejected, rejection{line: r.line, reason: r.err.Error()})
+			continue
+		}
+		if seen[r.product.Barcode] {
+			s.duplicates++
+			continue
+		}
+		seen[r.product.Barcode] = true
+		// fill structured fields from RawData and Nutrients, as POST /api/products/add does
+		importer.Backfill(&r.product)
+		valid = append(valid, r)
+	}
+
+	for start := 0; start < len(valid); start += lookupBatch {
+		batch := valid[start:min(start+lookupBatch, len(valid))]
+		codes := make([]string, len(batch))
+		for i, r := range batch {
+			codes[i] = r.product.Barcode
+		}
+		existing, err := products.FindByBarcodes(ctx, codes)
+		if err != nil {
+			return s, err
+		}
+
+		for _, r := range batch {
+			_, exists := existing[r.product.Barcode]
+			if !exists && r.product.CreatedAt.IsZero() {
+				r.product.CreatedAt = time.Now()
+			}
+			if !dryRun {
+				if err := products.Upsert(ctx, r.product); err != nil {
+					s.rejected = append(s.rejected, rejection{line: r.line, reason: err.Error()})
+					continue
+				}
+			}
+			if exists {
+				s.updated++
+			} else {
+				s.inserted++
+			}
+		}
+	}
+	return s, nil
+}
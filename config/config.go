@@ -1,10 +1,58 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DevJWTSecret is the publicly known secret used when JWT_SECRET is unset; only DevMode accepts it
+const DevJWTSecret = "dev-secret-change-me"
+
+// MinJWTSecretLen is the shortest JWT_SECRET accepted outside DevMode, in bytes
+const MinJWTSecretLen = 32
 
 var (
+	// DevMode (DEV_MODE=true) relaxes checks meant for deployments, such as the JWT secret's
+	DevMode = false
+
 	MongoURI     = "mongodb://127.0.0.1:27017"
 	DatabaseName = "greenlabelai"
+
+	// JWTSecret signs the HS256 access tokens issued at login. It must be set
+	// through JWT_SECRET unless DevMode is on, see CheckJWTSecret.
+	JWTSecret = DevJWTSecret
+	TokenTTL  = 7 * 24 * time.Hour
+	// JWKSFile optionally points at a local JWKS with RSA keys for RS256 tokens
+	JWKSFile = ""
+
+	// DefaultRole is given to new signups; emails in AdminEmails are made admins instead
+	DefaultRole = "viewer"
+	AdminEmails = []string{}
+
+	// EmissionFactorsFile overrides the built-in per-category emission factors (.json or .csv)
+	EmissionFactorsFile = ""
+
+	// RecipesFile overrides the built-in recipe corpus (a JSON array of recipes)
+	RecipesFile = ""
+
+	// BadgesFile overrides the built-in badge catalog (a JSON array of badge rules)
+	BadgesFile = ""
+
+	// MaxBodyBytes caps the size of a JSON request body
+	MaxBodyBytes int64 = 1 << 20
+
+	// ProductCacheSize enables an in-process LRU of products for basket analysis (0 disables it)
+	ProductCacheSize = 0
+	ProductCacheTTL  = 5 * time.Minute
+
+	// Unknown barcodes are imported from an Open Food Facts compatible API (OFFBaseURL,
+	// e.g. https://world.openfoodfacts.org) or a local JSONL dump (OFFDumpFile). Both empty disables import.
+	OFFBaseURL  = ""
+	OFFDumpFile = ""
 )
 
 func LoadEnv() {
@@ -14,4 +62,74 @@ func LoadEnv() {
 	if db := os.Getenv("DB_NAME"); db != "" {
 		DatabaseName = db
 	}
+	if dev, err := strconv.ParseBool(os.Getenv("DEV_MODE")); err == nil {
+		DevMode = dev
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		JWTSecret = secret
+	}
+	if jwks := os.Getenv("JWKS_FILE"); jwks != "" {
+		JWKSFile = jwks
+	}
+	if role := os.Getenv("DEFAULT_ROLE"); role != "" {
+		DefaultRole = role
+	}
+	if admins := os.Getenv("ADMIN_EMAILS"); admins != "" {
+		AdminEmails = nil
+		for _, email := range strings.Split(admins, ",") {
+			if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+				AdminEmails = append(AdminEmails, email)
+			}
+		}
+	}
+	if f := os.Getenv("EMISSION_FACTORS_FILE"); f != "" {
+		EmissionFactorsFile = f
+	}
+	if f := os.Getenv("RECIPES_FILE"); f != "" {
+		RecipesFile = f
+	}
+	if f := os.Getenv("BADGES_FILE"); f != "" {
+		BadgesFile = f
+	}
+	if size := os.Getenv("PRODUCT_CACHE_SIZE"); size != "" {
+		if n, err := strconv.Atoi(size); err == nil {
+			ProductCacheSize = n
+		}
+	}
+	if ttl := os.Getenv("PRODUCT_CACHE_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			ProductCacheTTL = d
+		}
+	}
+	if base := os.Getenv("OFF_BASE_URL"); base != "" {
+		OFFBaseURL = base
+	}
+	if dump := os.Getenv("OFF_DUMP_FILE"); dump != "" {
+		OFFDumpFile = dump
+	}
+	if size := os.Getenv("MAX_BODY_BYTES"); size != "" {
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil && n > 0 {
+			MaxBodyBytes = n
+		}
+	}
+	if ttl := os.Getenv("TOKEN_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			TokenTTL = d
+		}
+	}
+}
+
+// CheckJWTSecret rejects a JWTSecret anyone could forge tokens with: unset, the
+// public development default, or shorter than MinJWTSecretLen. DevMode allows all three.
+func CheckJWTSecret() error {
+	if DevMode {
+		return nil
+	}
+	switch {
+	case JWTSecret == "" || JWTSecret == DevJWTSecret:
+		return errors.New("JWT_SECRET is not set; set it, or DEV_MODE=true for local development")
+	case len(JWTSecret) < MinJWTSecretLen:
+		return fmt.Errorf("JWT_SECRET must be at least %d bytes long", MinJWTSecretLen)
+	}
+	return nil
 }
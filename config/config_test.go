@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckJWTSecret(t *testing.T) {
+	secret, dev := JWTSecret, DevMode
+	t.Cleanup(func() { JWTSecret, DevMode = secret, dev })
+
+	long := strings.Repeat("s", MinJWTSecretLen)
+	tests := []struct {
+		secret  string
+		devMode bool
+		ok      bool
+	}{
+		{long, false, true},
+		{"", false, false},
+		{DevJWTSecret, false, false},
+		{long[1:], false, false},
+		{"", true, true},
+		{DevJWTSecret, true, true},
+		{"short", true, true},
+	}
+	for _, tt := range tests {
+		JWTSecret, DevMode = tt.secret, tt.devMode
+		if err := CheckJWTSecret(); (err == nil) != tt.ok {
+			t.Errorf("CheckJWTSecret(%q, DevMode=%v) = %v, want ok=%v", tt.secret, tt.devMode, err, tt.ok)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"backend/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -30,5 +31,60 @@ func ConnectMongo() {
 	Client = client
 	DB = client.Database(config.DatabaseName)
 
+	EnsureIndexes(ctx, DB)
+
 	log.Println("MongoDB connected")
 }
+
+// EnsureIndexes creates the indexes the handlers rely on in database (idempotent)
+func EnsureIndexes(ctx context.Context, database *mongo.Database) {
+	indexes := map[string][]mongo.IndexModel{
+		"products": {
+			{Keys: bson.D{{Key: "barcode", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{
+				Keys: bson.D{
+					{Key: "name", Value: "text"},
+					{Key: "brand", Value: "text"},
+					{Key: "description", Value: "text"},
+					{Key: "ingredients_text", Value: "text"},
+				},
+				// language "none" disables stemming so mixed-language catalogs match literally
+				Options: options.Index().
+					SetName("products_text").
+					SetDefaultLanguage("none").
+					SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "brand", Value: 5}, {Key: "ingredients_text", Value: 2}, {Key: "description", Value: 1}}),
+			},
+			{Keys: bson.D{{Key: "ecoScore", Value: -1}}},
+		},
+		"users": {
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"baskets": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			// one basket per Idempotency-Key and user; baskets saved without a key are not indexed
+			{
+				Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$exists": true}}),
+			},
+		},
+		// baskets nobody touched for 60 days are dropped; most belong to abandoned anonymous sessions
+		"active_baskets": {
+			{Keys: bson.D{{Key: "updated_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(60 * 24 * 3600)},
+		},
+		"history": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "time", Value: -1}}},
+		},
+		"goals": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+		"user_badges": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "badge_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+	}
+	for coll, models := range indexes {
+		if _, err := database.Collection(coll).Indexes().CreateMany(ctx, models); err != nil {
+			log.Println("Mongo index error on", coll+":", err)
+		}
+	}
+}
@@ -2,7 +2,10 @@ module backend
 
 go 1.24.5
 
-require go.mongodb.org/mongo-driver v1.17.7
+require (
+	go.mongodb.org/mongo-driver v1.17.7
+	golang.org/x/crypto v0.26.0
+)
 
 require (
 	github.com/golang/snappy v0.0.4 // indirect
@@ -12,7 +15,6 @@ require (
 	github.com/xdg-go/scram v1.1.2 // indirect
 	github.com/xdg-go/stringprep v1.0.4 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
-	golang.org/x/crypto v0.26.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
 )
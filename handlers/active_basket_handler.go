@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+
+	"backend/auth"
+	"backend/models"
+	"backend/repository"
+	basketsvc "backend/services/basket"
+	"backend/utils"
+)
+
+// SessionHeader names the anonymous session whose active basket a request works on
+const SessionHeader = "X-Session-ID"
+
+var sessionID = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// basketOwner identifies whose active basket a request works on: the signed-in
+// user, else the anonymous session in X-Session-ID. A request with neither
+// starts a new session, whose id is returned in X-Session-ID.
+func basketOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if userID := auth.UserID(r.Context()); userID != "" {
+		return "user:" + userID, true
+	}
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			utils.Error(w, utils.Internal("Failed to start session", err))
+			return "", false
+		}
+		id = hex.EncodeToString(buf)
+	} else if !sessionID.MatchString(id) {
+		utils.Error(w, utils.BadRequest(SessionHeader+" must be 16 to 128 letters, digits, '-' or '_'"))
+		return "", false
+	}
+	w.Header().Set(SessionHeader, id)
+	return "session:" + id, true
+}
+
+// mergeSessionBasket moves the items of the anonymous basket named in
+// X-Session-ID into the user's basket and empties it, so a basket filled
+// before signing in is kept. Requests without a valid session id merge nothing.
+func (h *Handler) mergeSessionBasket(ctx context.Context, r *http.Request, userID string) error {
+	id := r.Header.Get(SessionHeader)
+	if !sessionID.MatchString(id) {
+		return nil
+	}
+	_, err := h.Active.Merge(ctx, "session:"+id, "user:"+userID)
+	return err
+}
+
+// adoptSessionBasket merges the session basket on signup and login. Signing
+// in still succeeds when it fails; the basket stays with the session.
+func (h *Handler) adoptSessionBasket(w http.ResponseWriter, r *http.Request, userID string) {
+	if err := h.mergeSessionBasket(r.Context(), r, userID); err != nil {
+		log.Printf("request %s: failed to merge session basket: %v", w.Header().Get(utils.RequestIDHeader), err)
+	}
+}
+
+func writeActiveBasket(w http.ResponseWriter, b models.ActiveBasket, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.NotFound("Product not in basket"))
+		return
+	}
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to update basket", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "basket": b})
+}
+
+// GetActiveBasket handles GET /api/basket/active
+func (h *Handler) GetActiveBasket(w http.ResponseWriter, r *http.Request) {
+	owner, ok := basketOwner(w, r)
+	if !ok {
+		return
+	}
+	b, err := h.Active.Get(r.Context(), owner)
+	writeActiveBasket(w, b, err)
+}
+
+// addItem decodes { productId, quantity } and adds it to the active basket;
+// quantity defaults to 1
+func (h *Handler) addItem(w http.ResponseWriter, r *http.Request) (models.ActiveBasket, bool) {
+	owner, ok := basketOwner(w, r)
+	if !ok {
+		return models.ActiveBasket{}, false
+	}
+	var item models.BasketItem
+	if !decodeBody(w, r, &item) {
+		return models.ActiveBasket{}, false
+	}
+	if item.Quantity == 0 {
+		item.Quantity = 1
+	}
+	code, ok := parseBarcode(w, item.ProductID)
+	if !ok {
+		return models.ActiveBasket{}, false
+	}
+	b, err := h.Active.AddItem(r.Context(), owner, code, item.Quantity)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to update basket", err))
+		return models.ActiveBasket{}, false
+	}
+	return b, true
+}
+
+// AddActiveBasketItem handles POST /api/basket/active/items: { productId, quantity }
+func (h *Handler) AddActiveBasketItem(w http.ResponseWriter, r *http.Request) {
+	if b, ok := h.addItem(w, r); ok {
+		writeActiveBasket(w, b, nil)
+	}
+}
+
+// quantityRequest is the body of SetActiveBasketItem; removing a line is a DELETE
+type quantityRequest struct {
+	Quantity int `json:"quantity" validate:"min=1,max=999"`
+}
+
+// SetActiveBasketItem handles PUT /api/basket/active/items/{barcode}: { quantity }
+func (h *Handler) SetActiveBasketItem(w http.ResponseWriter, r *http.Request) {
+	owner, ok := basketOwner(w, r)
+	if !ok {
+		return
+	}
+	code, ok := parseBarcode(w, r.PathValue("barcode"))
+	if !ok {
+		return
+	}
+	var req quantityRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	b, err := h.Active.SetQuantity(r.Context(), owner, code, req.Quantity)
+	writeActiveBasket(w, b, err)
+}
+
+// RemoveActiveBasketItem handles DELETE /api/basket/active/items/{barcode}
+func (h *Handler) RemoveActiveBasketItem(w http.ResponseWriter, r *http.Request) {
+	owner, ok := basketOwner(w, r)
+	if !ok {
+		return
+	}
+	code, ok := parseBarcode(w, r.PathValue("barcode"))
+	if !ok {
+		return
+	}
+	b, err := h.Active.RemoveItem(r.Context(), owner, code)
+	writeActiveBasket(w, b, err)
+}
+
+// ClearActiveBasket handles DELETE /api/basket/active
+func (h *Handler) ClearActiveBasket(w http.ResponseWriter, r *http.Request) {
+	owner, ok := basketOwner(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Active.Clear(r.Context(), owner); err != nil {
+		utils.Error(w, utils.Internal("Failed to clear basket", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// CheckoutActiveBasket handles POST /api/basket/active/checkout: the signed-in
+// user's active basket, with any X-Session-ID basket merged in, is saved like
+// POST /api/basket/save, then emptied
+func (h *Handler) CheckoutActiveBasket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	owner := "user:" + userID
+	key, done := h.savedBefore(w, r, userID)
+	if done {
+		return
+	}
+
+	if err := h.mergeSessionBasket(r.Context(), r, userID); err != nil {
+		utils.Error(w, utils.Internal("Failed to merge session basket", err))
+		return
+	}
+	active, err := h.Active.Get(r.Context(), owner)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch basket", err))
+		return
+	}
+	if len(active.Items) == 0 {
+		utils.Error(w, utils.BadRequest("Basket is empty"))
+		return
+	}
+
+	// items were normalized when they were added. The response is written once
+	// the basket is saved, so a basket that cannot be emptied is only logged;
+	// checking it out again saves it again unless the Idempotency-Key is reused.
+	if h.saveBasket(w, r, userID, key, basketsvc.LinesFromItems(active.Items)) {
+		if err := h.Active.Clear(r.Context(), owner); err != nil {
+			log.Printf("request %s: failed to clear checked out basket: %v", w.Header().Get(utils.RequestIDHeader), err)
+		}
+	}
+}
+
+// GetBasket returns the active basket's items as a bare array, for older clients
+func (h *Handler) GetBasket(w http.ResponseWriter, r *http.Request) {
+	owner, ok := basketOwner(w, r)
+	if !ok {
+		return
+	}
+	b, err := h.Active.Get(r.Context(), owner)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch basket", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, b.Items)
+}
+
+// AddToBasket adds { productId, quantity } to the active basket and returns its
+// items as a bare array, for older clients
+func (h *Handler) AddToBasket(w http.ResponseWriter, r *http.Request) {
+	if b, ok := h.addItem(w, r); ok {
+		utils.JSON(w, http.StatusOK, b.Items)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"backend/allergens"
+	"backend/models"
+	"backend/repository"
+	"backend/utils"
+)
+
+// productAllergens handles GET /api/product/{barcode}/allergens
+func (h *Handler) productAllergens(w http.ResponseWriter, r *http.Request, product *models.Product) {
+	if product.Barcode == "" {
+		utils.Error(w, utils.NotFound("Product not found"))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "allergens": allergens.Analyze(*product)})
+}
+
+// GetDietaryProfile handles GET /api/auth/me/diet; a user without a profile gets empty lists
+func (h *Handler) GetDietaryProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.Users.FindByID(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, utils.NotFound("User not found"))
+		return
+	}
+	profile := models.DietaryProfile{Diets: []string{}, Allergens: []string{}}
+	if user.DietaryProfile != nil {
+		profile = *user.DietaryProfile
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "profile": profile})
+}
+
+// SetDietaryProfile handles PUT /api/auth/me/diet: { diets: ["vegan"], allergens: ["nuts"] }.
+// Basket analysis and saved baskets warn about products that conflict with it.
+func (h *Handler) SetDietaryProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.DietaryProfile
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	profile := models.DietaryProfile{Diets: []string{}, Allergens: []string{}}
+	for _, d := range req.Diets {
+		profile.Diets = appendUnique(profile.Diets, strings.ToLower(strings.TrimSpace(d)))
+	}
+	for _, a := range req.Allergens {
+		profile.Allergens = appendUnique(profile.Allergens, strings.ToLower(strings.TrimSpace(a)))
+	}
+
+	if err := h.Users.SetDietaryProfile(r.Context(), userID, profile); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.Error(w, utils.NotFound("User not found"))
+			return
+		}
+		utils.Error(w, utils.Internal("Failed to save dietary profile", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "profile": profile})
+}
+
+// dietaryProfile returns the stored profile of userID, nil for anonymous
+// callers or users who have not set one
+func (h *Handler) dietaryProfile(ctx context.Context, userID string) *models.DietaryProfile {
+	if userID == "" {
+		return nil
+	}
+	user, err := h.Users.FindByID(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	return user.DietaryProfile
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,555 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"backend/barcode"
+	"backend/config"
+	"backend/handlers"
+	"backend/models"
+	"backend/repository"
+	"backend/routes"
+)
+
+const adminEmail = "admin@example.com"
+
+func TestMain(m *testing.M) {
+	config.AdminEmails = []string{adminEmail}
+	os.Exit(m.Run())
+}
+
+// gtin returns a valid GTIN-13 built from n
+func gtin(n int) string {
+	body := strconv.Itoa(400000000000 + n)
+	return "0" + body + string(barcode.CheckDigit(body))
+}
+
+// newServer runs the full HTTP API against fresh in-memory repositories
+func newServer(t testing.TB, products ...models.Product) (*httptest.Server, *repository.Repos) {
+	t.Helper()
+	repos := repository.NewMemory()
+	for _, p := range products {
+		repos.Products.(*repository.MemoryProducts).Put(p)
+	}
+	srv := httptest.NewServer(routes.RegisterRoutes(handlers.New(repos)))
+	t.Cleanup(srv.Close)
+	return srv, repos
+}
+
+// client is one caller of the API; the zero token and session are anonymous
+type client struct {
+	srv     *httptest.Server
+	token   string
+	session string
+	header  http.Header // extra request headers
+}
+
+// response is a decoded API response
+type response struct {
+	Status int
+	Header http.Header
+	Body   map[string]interface{} // nil when the body is not a JSON object
+	Raw    []byte
+}
+
+func (c client) do(t testing.TB, method, path string, body interface{}) response {
+	t.Helper()
+	res, err := c.try(method, path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// try is do without testing.TB, for goroutines that report their own failures
+func (c client) try(method, path string, body interface{}) (response, error) {
+	var buf bytes.Buffer
+	switch b := body.(type) {
+	case nil:
+	case string:
+		buf.WriteString(b)
+	default:
+		if err := json.NewEncoder(&buf).Encode(b); err != nil {
+			return response{}, err
+		}
+	}
+	req, err := http.NewRequest(method, c.srv.URL+path, &buf)
+	if err != nil {
+		return response{}, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.session != "" {
+		req.Header.Set(handlers.SessionHeader, c.session)
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	resp, err := c.srv.Client().Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, err
+	}
+	res := response{Status: resp.StatusCode, Header: resp.Header, Raw: raw}
+	_ = json.Unmarshal(raw, &res.Body) // a few legacy endpoints answer with arrays
+	return res, nil
+}
+
+// want fails the test unless the response has the given status
+func (r response) want(t testing.TB, status int) response {
+	t.Helper()
+	if r.Status != status {
+		t.Fatalf("status %d, want %d: %s", r.Status, status, bytes.TrimSpace(r.Raw))
+	}
+	return r
+}
+
+// wantError fails the test unless r is an error envelope with the given status and code
+func (r response) wantError(t testing.TB, status int, code string) {
+	t.Helper()
+	r.want(t, status)
+	e, _ := r.Body["error"].(map[string]interface{})
+	if r.Body["success"] != false || e == nil || e["code"] != code {
+		t.Fatalf("body %s, want an error with code %q", bytes.TrimSpace(r.Raw), code)
+	}
+	if e["request_id"] == "" || e["request_id"] != r.Header.Get("X-Request-ID") {
+		t.Errorf("error request_id %v, X-Request-ID header %q", e["request_id"], r.Header.Get("X-Request-ID"))
+	}
+}
+
+func (r response) object(key string) map[string]interface{} {
+	m, _ := r.Body[key].(map[string]interface{})
+	return m
+}
+
+func (r response) list(key string) []interface{} {
+	l, _ := r.Body[key].([]interface{})
+	return l
+}
+
+// signup creates an account and returns a client signed in to it
+func signup(t testing.TB, srv *httptest.Server, email string) client {
+	t.Helper()
+	c := client{srv: srv}
+	res := c.do(t, "POST", "/api/auth/signup", map[string]string{"email": email, "password": "correct horse"}).want(t, http.StatusCreated)
+	c.token, _ = res.Body["token"].(string)
+	if c.token == "" {
+		t.Fatalf("signup returned no token: %s", res.Raw)
+	}
+	return c
+}
+
+func TestAuth(t *testing.T) {
+	srv, _ := newServer(t)
+	anon := client{srv: srv}
+
+	user := signup(t, srv, "Jane@Example.com")
+	me := user.do(t, "GET", "/api/auth/me", nil).want(t, http.StatusOK).object("user")
+	if me["email"] != "jane@example.com" || me["role"] != "viewer" {
+		t.Errorf("me = %v, want jane@example.com as viewer", me)
+	}
+
+	anon.do(t, "POST", "/api/auth/signup", map[string]string{"email": "jane@example.com", "password": "another one"}).
+		wantError(t, http.StatusConflict, "conflict")
+	anon.do(t, "POST", "/api/auth/login", map[string]string{"email": "jane@example.com", "password": "wrong password"}).
+		wantError(t, http.StatusUnauthorized, "unauthorized")
+	login := anon.do(t, "POST", "/api/auth/login", map[string]string{"email": "jane@example.com", "password": "correct horse"}).want(t, http.StatusOK)
+	if login.Body["token"] == "" {
+		t.Error("login returned no token")
+	}
+
+	anon.do(t, "GET", "/api/auth/me", nil).wantError(t, http.StatusUnauthorized, "unauthorized")
+	client{srv: srv, token: "not.a.token"}.do(t, "GET", "/api/auth/me", nil).wantError(t, http.StatusUnauthorized, "unauthorized")
+
+	admin := signup(t, srv, adminEmail)
+	if role := admin.do(t, "GET", "/api/auth/me", nil).object("user")["role"]; role != "admin" {
+		t.Errorf("admin role = %v", role)
+	}
+}
+
+func TestRoleChangesApplyToIssuedTokens(t *testing.T) {
+	srv, _ := newServer(t)
+	admin := signup(t, srv, adminEmail)
+	user := signup(t, srv, "contributor@example.com")
+	userID := user.do(t, "GET", "/api/auth/me", nil).object("user")["id"]
+	product := map[string]interface{}{"barcode": gtin(1), "name": "Oat drink"}
+
+	user.do(t, "POST", "/api/products/add", product).wantError(t, http.StatusForbidden, "forbidden")
+	admin.do(t, "POST", "/api/admin/users/role", map[string]interface{}{"user_id": userID, "role": "contributor"}).want(t, http.StatusOK)
+	user.do(t, "POST", "/api/products/add", product).want(t, http.StatusOK)
+	admin.do(t, "POST", "/api/admin/users/role", map[string]interface{}{"user_id": userID, "role": "viewer"}).want(t, http.StatusOK)
+	user.do(t, "POST", "/api/products/add", product).wantError(t, http.StatusForbidden, "forbidden")
+}
+
+func TestProducts(t *testing.T) {
+	srv, _ := newServer(t)
+	anon := client{srv: srv}
+	admin := signup(t, srv, adminEmail)
+	code := gtin(7)
+
+	admin.do(t, "POST", "/api/products/add", map[string]interface{}{
+		"barcode": code[1:], "name": "Greek yogurt", "brand": "Dairy Co", "category": "yogurts", "quantity": "500 g",
+		"ingredients_text": "milk, cultures",
+	}).want(t, http.StatusOK)
+
+	for _, path := range []string{"/api/product/" + code, "/api/product/" + code[1:]} {
+		p := anon.do(t, "GET", path, nil).want(t, http.StatusOK).object("product")
+		if p["barcode"] != code || p["name"] != "Greek yogurt" {
+			t.Errorf("GET %s = %v", path, p)
+		}
+	}
+	if got := anon.do(t, "GET", "/product/barcode?barcode="+code, nil).want(t, http.StatusOK).Body["name"]; got != "Greek yogurt" {
+		t.Errorf("GET /product/barcode name = %v", got)
+	}
+
+	list := anon.do(t, "GET", "/api/products?limit=10", nil).want(t, http.StatusOK)
+	if n := len(list.list("products")); n != 1 || list.Body["total"] != 1.0 {
+		t.Errorf("GET /api/products = %d products, total %v", n, list.Body["total"])
+	}
+	search := anon.do(t, "GET", "/api/products/search?q=yogurt", nil).want(t, http.StatusOK)
+	if n := len(search.list("products")); n != 1 {
+		t.Errorf("search found %d products, want 1", n)
+	}
+	allergens := anon.do(t, "GET", "/api/product/"+code+"/allergens", nil).want(t, http.StatusOK).object("allergens")
+	if found := allergens["allergens"].([]interface{}); len(found) != 1 {
+		t.Errorf("allergens = %v, want milk", found)
+	}
+
+	anon.do(t, "GET", "/api/product/"+gtin(8), nil).wantError(t, http.StatusNotFound, "not_found")
+	anon.do(t, "GET", "/api/product/4000000000016", nil).wantError(t, http.StatusBadRequest, "invalid_barcode")
+	anon.do(t, "POST", "/api/products/add", map[string]interface{}{"barcode": code}).wantError(t, http.StatusUnauthorized, "unauthorized")
+	admin.do(t, "POST", "/api/products/add", map[string]interface{}{"barcode": code, "unknown": 1}).
+		wantError(t, http.StatusUnprocessableEntity, "validation_failed")
+	admin.do(t, "POST", "/api/products/add", "{").wantError(t, http.StatusBadRequest, "invalid_body")
+}
+
+func TestBaskets(t *testing.T) {
+	yogurt, oats := gtin(1), gtin(2)
+	srv, _ := newServer(t,
+		models.Product{Barcode: yogurt, Name: "Yogurt", Category: "yogurts", Quantity: "500 g", EcoScore: 70},
+		models.Product{Barcode: oats, Name: "Oats", EcoScore: 90},
+	)
+	anon := client{srv: srv}
+	user := signup(t, srv, "shopper@example.com")
+
+	analyzed := anon.do(t, "POST", "/api/basket", map[string]interface{}{
+		"barcodes": []string{yogurt, gtin(3)},
+		"items":    []map[string]interface{}{{"productId": oats, "quantity": 2}},
+	}).want(t, http.StatusOK).object("basket")
+	if analyzed["total_items"] != 3.0 || len(analyzed["unknown_barcodes"].([]interface{})) != 1 {
+		t.Errorf("analyzed basket = %v", analyzed)
+	}
+	anon.do(t, "POST", "/api/basket", map[string]interface{}{"barcodes": []string{"123"}}).
+		wantError(t, http.StatusBadRequest, "invalid_barcode")
+	anon.do(t, "POST", "/api/basket/save", map[string]interface{}{"barcodes": []string{yogurt}}).
+		wantError(t, http.StatusUnauthorized, "unauthorized")
+
+	// an empty basket is never analyzed or saved
+	for _, body := range []interface{}{map[string]interface{}{}, map[string]interface{}{"barcodes": nil}} {
+		anon.do(t, "POST", "/api/basket", body).wantError(t, http.StatusBadRequest, "bad_request")
+		user.do(t, "POST", "/api/basket/save", body).wantError(t, http.StatusBadRequest, "bad_request")
+	}
+	for _, body := range []interface{}{
+		map[string]interface{}{"items": []interface{}{}},
+		map[string]interface{}{"barcodes": []string{}, "items": []map[string]interface{}{{"productId": oats, "quantity": 1}}},
+	} {
+		res := user.do(t, "POST", "/api/basket/save", body)
+		res.wantError(t, http.StatusUnprocessableEntity, "validation_failed")
+		if details := res.object("error")["details"].([]interface{}); details[0].(map[string]interface{})["rule"] != "minitems" {
+			t.Errorf("empty list reported as %v", details)
+		}
+	}
+	if n := len(user.do(t, "GET", "/api/baskets", nil).want(t, http.StatusOK).list("baskets")); n != 0 {
+		t.Fatalf("%d empty baskets were saved", n)
+	}
+
+	saved := user.do(t, "POST", "/api/basket/save", map[string]interface{}{"barcodes": []string{yogurt, oats}}).want(t, http.StatusOK)
+	basket := saved.object("basket")
+	carbon := 2.5*0.5 + (100-90)*0.05
+	if basket["total_items"] != 2.0 || basket["total_carbon"] != carbon {
+		t.Errorf("saved basket = %v", basket)
+	}
+	if impact := saved.object("impact"); impact["total_baskets"] != 1.0 {
+		t.Errorf("impact after save = %v", impact)
+	}
+	if badges := saved.list("new_badges"); len(badges) == 0 {
+		t.Error("first basket earned no badge")
+	}
+
+	// a retry with the same Idempotency-Key replays the first save
+	keyed := user
+	keyed.header = http.Header{handlers.IdempotencyKeyHeader: {"save-1"}}
+	first := keyed.do(t, "POST", "/api/basket/save", map[string]interface{}{"barcodes": []string{oats}}).want(t, http.StatusOK)
+	retry := keyed.do(t, "POST", "/api/basket/save", map[string]interface{}{"barcodes": []string{oats}}).want(t, http.StatusOK)
+	if retry.Header.Get(handlers.ReplayedHeader) != "true" || retry.object("basket")["id"] != first.object("basket")["id"] {
+		t.Errorf("retry was not replayed: %s", retry.Raw)
+	}
+
+	list := user.do(t, "GET", "/api/baskets", nil).want(t, http.StatusOK)
+	if n := len(list.list("baskets")); n != 2 {
+		t.Errorf("%d saved baskets, want 2", n)
+	}
+	stats := user.do(t, "GET", "/api/impact/stats", nil).want(t, http.StatusOK).object("stats")
+	if stats["total_baskets"] != 2.0 || stats["total_carbon_saved"] != carbon+(100-90)*0.05 {
+		t.Errorf("impact stats = %v", stats)
+	}
+	if badges := user.do(t, "GET", "/api/badges", nil).want(t, http.StatusOK).list("badges"); len(badges) == 0 {
+		t.Error("no badges listed")
+	}
+
+	// baskets belong to their owner
+	other := signup(t, srv, "other@example.com")
+	if n := len(other.do(t, "GET", "/api/baskets", nil).want(t, http.StatusOK).list("baskets")); n != 0 {
+		t.Errorf("another user sees %d baskets", n)
+	}
+	id := basket["id"].(string)
+	user.do(t, "GET", "/api/basket/"+id+"/recipes", nil).want(t, http.StatusOK)
+	other.do(t, "GET", "/api/basket/"+id+"/recipes", nil).wantError(t, http.StatusNotFound, "not_found")
+}
+
+func TestActiveBasket(t *testing.T) {
+	yogurt, oats := gtin(1), gtin(2)
+	srv, _ := newServer(t,
+		models.Product{Barcode: yogurt, Name: "Yogurt", EcoScore: 70},
+		models.Product{Barcode: oats, Name: "Oats", EcoScore: 90},
+	)
+
+	// an anonymous caller gets a session id to send back
+	anon := client{srv: srv}
+	res := anon.do(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": yogurt}).want(t, http.StatusOK)
+	anon.session = res.Header.Get(handlers.SessionHeader)
+	if anon.session == "" {
+		t.Fatal("no session id returned")
+	}
+	anon.do(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": yogurt, "quantity": 2}).want(t, http.StatusOK)
+	items := anon.do(t, "GET", "/api/basket/active", nil).want(t, http.StatusOK).object("basket")["items"].([]interface{})
+	if len(items) != 1 || items[0].(map[string]interface{})["quantity"] != 3.0 {
+		t.Errorf("session basket = %v, want 3 yogurts", items)
+	}
+
+	user := signup(t, srv, "shopper@example.com")
+	user.do(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": oats, "quantity": 4}).want(t, http.StatusOK)
+	user.do(t, "PUT", "/api/basket/active/items/"+oats, map[string]interface{}{"quantity": 2}).want(t, http.StatusOK)
+	user.do(t, "PUT", "/api/basket/active/items/"+yogurt, map[string]interface{}{"quantity": 2}).wantError(t, http.StatusNotFound, "not_found")
+	user.do(t, "PUT", "/api/basket/active/items/"+oats, map[string]interface{}{"quantity": 0}).
+		wantError(t, http.StatusUnprocessableEntity, "validation_failed")
+	if legacy := user.do(t, "GET", "/basket", nil).want(t, http.StatusOK).Raw; !bytes.Contains(legacy, []byte(oats)) {
+		t.Errorf("GET /basket = %s", legacy)
+	}
+
+	checkout := user.do(t, "POST", "/api/basket/active/checkout", nil).want(t, http.StatusOK).object("basket")
+	if checkout["total_items"] != 2.0 {
+		t.Errorf("checked out basket = %v", checkout)
+	}
+	if items := user.do(t, "GET", "/api/basket/active", nil).object("basket")["items"].([]interface{}); len(items) != 0 {
+		t.Errorf("active basket after checkout = %v", items)
+	}
+	user.do(t, "POST", "/api/basket/active/checkout", nil).wantError(t, http.StatusBadRequest, "bad_request")
+	anon.do(t, "POST", "/api/basket/active/checkout", nil).wantError(t, http.StatusUnauthorized, "unauthorized")
+
+	anon.do(t, "DELETE", "/api/basket/active/items/"+yogurt, nil).want(t, http.StatusOK)
+	anon.do(t, "DELETE", "/api/basket/active/items/"+yogurt, nil).wantError(t, http.StatusNotFound, "not_found")
+	anon.do(t, "DELETE", "/api/basket/active", nil).want(t, http.StatusOK)
+	client{srv: srv, session: "bad"}.do(t, "GET", "/api/basket/active", nil).wantError(t, http.StatusBadRequest, "bad_request")
+}
+
+func TestSessionBasketMerge(t *testing.T) {
+	yogurt, oats := gtin(1), gtin(2)
+	srv, _ := newServer(t,
+		models.Product{Barcode: yogurt, Name: "Yogurt", EcoScore: 70},
+		models.Product{Barcode: oats, Name: "Oats", EcoScore: 90},
+	)
+	basketItems := func(c client) map[string]float64 {
+		out := map[string]float64{}
+		for _, it := range c.do(t, "GET", "/api/basket/active", nil).want(t, http.StatusOK).object("basket")["items"].([]interface{}) {
+			item := it.(map[string]interface{})
+			out[item["productId"].(string)] = item["quantity"].(float64)
+		}
+		return out
+	}
+
+	// signing up adopts the basket filled anonymously
+	anon := client{srv: srv, session: "session-before-signup"}
+	anon.do(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": yogurt, "quantity": 2}).want(t, http.StatusOK)
+	res := anon.do(t, "POST", "/api/auth/signup", map[string]string{"email": "merge@example.com", "password": "correct horse"}).want(t, http.StatusCreated)
+	user := client{srv: srv, token: res.Body["token"].(string)}
+	if got := basketItems(user); got[yogurt] != 2 {
+		t.Errorf("basket after signup = %v, want 2 yogurts", got)
+	}
+	if got := basketItems(anon); len(got) != 0 {
+		t.Errorf("session basket after signup = %v, want it emptied", got)
+	}
+
+	// so does logging in, adding to what the user already has
+	anon.do(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": yogurt}).want(t, http.StatusOK)
+	anon.do(t, "POST", "/api/auth/login", map[string]string{"email": "merge@example.com", "password": "correct horse"}).want(t, http.StatusOK)
+	if got := basketItems(user); got[yogurt] != 3 {
+		t.Errorf("basket after login = %v, want 3 yogurts", got)
+	}
+
+	// and checking out with the session still set
+	anon.do(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": oats, "quantity": 4}).want(t, http.StatusOK)
+	user.session = anon.session
+	saved := user.do(t, "POST", "/api/basket/active/checkout", nil).want(t, http.StatusOK).object("basket")
+	if saved["total_items"] != 7.0 {
+		t.Errorf("checked out %v items, want 7", saved["total_items"])
+	}
+	if got := basketItems(anon); len(got) != 0 {
+		t.Errorf("session basket after checkout = %v", got)
+	}
+	user.session = ""
+	if got := basketItems(user); len(got) != 0 {
+		t.Errorf("user basket after checkout = %v", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	code := gtin(1)
+	srv, _ := newServer(t, models.Product{Barcode: code, Name: "Yogurt"})
+	user := signup(t, srv, "scanner@example.com")
+	admin := signup(t, srv, adminEmail)
+
+	for i := 0; i < 3; i++ {
+		user.do(t, "POST", "/history/add?barcode="+code, nil).want(t, http.StatusCreated)
+	}
+	user.do(t, "POST", "/history/add?barcode=12", nil).wantError(t, http.StatusBadRequest, "invalid_barcode")
+
+	page := user.do(t, "GET", "/history?limit=2", nil).want(t, http.StatusOK)
+	if len(page.list("history")) != 2 || page.Body["total"] != 3.0 || page.Body["next_cursor"] == "" {
+		t.Errorf("first history page = %s", page.Raw)
+	}
+	next := user.do(t, "GET", "/history?limit=2&cursor="+page.Body["next_cursor"].(string), nil).want(t, http.StatusOK)
+	if len(next.list("history")) != 1 {
+		t.Errorf("second history page = %s", next.Raw)
+	}
+	user.do(t, "GET", "/history?cursor=garbage", nil).wantError(t, http.StatusBadRequest, "bad_request")
+
+	userID := user.do(t, "GET", "/api/auth/me", nil).object("user")["id"].(string)
+	other := signup(t, srv, "other@example.com")
+	other.do(t, "DELETE", "/history?user_id="+userID, nil).wantError(t, http.StatusForbidden, "forbidden")
+	admin.do(t, "DELETE", "/history?user_id="+userID, nil).want(t, http.StatusOK)
+	if n := len(user.do(t, "GET", "/history", nil).list("history")); n != 0 {
+		t.Errorf("%d scans left after an admin cleared them", n)
+	}
+}
+
+// failingHistory is a history store whose writes fail
+type failingHistory struct{ repository.HistoryRepo }
+
+func (failingHistory) Add(context.Context, models.ScanHistory) error {
+	return errors.New("disk full")
+}
+
+func TestHistoryAddFailure(t *testing.T) {
+	code := gtin(1)
+	repos := repository.NewMemory()
+	repos.Products.(*repository.MemoryProducts).Put(models.Product{Barcode: code, Name: "Yogurt"})
+	repos.History = failingHistory{repos.History}
+	srv := httptest.NewServer(routes.RegisterRoutes(handlers.New(repos)))
+	t.Cleanup(srv.Close)
+
+	user := signup(t, srv, "scanner@example.com")
+	user.do(t, "POST", "/history/add?barcode="+code, nil).wantError(t, http.StatusInternalServerError, "internal_error")
+}
+
+func TestGoals(t *testing.T) {
+	code := gtin(1)
+	srv, _ := newServer(t, models.Product{Barcode: code, Name: "Yogurt", EcoScore: 80})
+	user := signup(t, srv, "goals@example.com")
+
+	goal := user.do(t, "POST", "/api/goals", map[string]interface{}{"type": "scans", "target_value": 2}).want(t, http.StatusOK).object("goal")
+	id := goal["id"].(string)
+	user.do(t, "POST", "/api/goals", map[string]interface{}{"type": "scans"}).wantError(t, http.StatusUnprocessableEntity, "validation_failed")
+	user.do(t, "POST", "/api/goals", map[string]interface{}{"type": "nonsense", "target_value": 1}).
+		wantError(t, http.StatusUnprocessableEntity, "validation_failed")
+
+	user.do(t, "POST", "/history/add?barcode="+code, nil).want(t, http.StatusCreated)
+	if got := user.do(t, "GET", "/api/goals/"+id, nil).want(t, http.StatusOK).object("goal"); got["progress"] != 1.0 || got["status"] != "active" {
+		t.Errorf("goal after one scan = %v", got)
+	}
+	user.do(t, "POST", "/history/add?barcode="+code, nil).want(t, http.StatusCreated)
+	if got := user.do(t, "GET", "/api/goals/"+id, nil).object("goal"); got["status"] != "completed" {
+		t.Errorf("goal after two scans = %v", got)
+	}
+
+	second := user.do(t, "POST", "/api/goals", map[string]interface{}{"type": "baskets", "target_value": 5}).object("goal")["id"].(string)
+	patched := user.do(t, "PATCH", "/api/goals/"+second, map[string]interface{}{"description": "Five baskets"}).want(t, http.StatusOK).object("goal")
+	if patched["description"] != "Five baskets" || patched["target_value"] != 5.0 {
+		t.Errorf("patched goal = %v", patched)
+	}
+	user.do(t, "POST", "/api/goals/"+second+"/complete", nil).want(t, http.StatusOK)
+	if n := len(user.do(t, "GET", "/api/goals", nil).want(t, http.StatusOK).list("goals")); n != 2 {
+		t.Errorf("%d goals listed, want 2", n)
+	}
+
+	other := signup(t, srv, "other@example.com")
+	other.do(t, "GET", "/api/goals/"+id, nil).wantError(t, http.StatusNotFound, "not_found")
+	other.do(t, "DELETE", "/api/goals/"+id, nil).wantError(t, http.StatusNotFound, "not_found")
+	user.do(t, "DELETE", "/api/goals/"+id, nil).want(t, http.StatusOK)
+	user.do(t, "GET", "/api/goals/"+id, nil).wantError(t, http.StatusNotFound, "not_found")
+}
+
+func TestDietaryProfile(t *testing.T) {
+	code := gtin(1)
+	srv, _ := newServer(t, models.Product{Barcode: code, Name: "Milk chocolate", IngredientsText: "sugar, cocoa butter, whole milk powder"})
+	user := signup(t, srv, "diet@example.com")
+
+	user.do(t, "PUT", "/api/auth/me/diet", map[string]interface{}{"allergens": []string{"milk"}, "diets": []string{"vegan"}}).want(t, http.StatusOK)
+	user.do(t, "PUT", "/api/auth/me/diet", map[string]interface{}{"allergens": []string{"kryptonite"}}).
+		wantError(t, http.StatusUnprocessableEntity, "validation_failed")
+	if profile := user.do(t, "GET", "/api/auth/me/diet", nil).want(t, http.StatusOK).object("profile"); len(profile["allergens"].([]interface{})) != 1 {
+		t.Errorf("profile = %v", profile)
+	}
+
+	warnings := user.do(t, "POST", "/api/basket", map[string]interface{}{"barcodes": []string{code}}).want(t, http.StatusOK).
+		object("basket")["warnings"].([]interface{})
+	var kinds []string
+	for _, w := range warnings {
+		w := w.(map[string]interface{})
+		kinds = append(kinds, fmt.Sprint(w["kind"], ":", w["code"]))
+	}
+	if fmt.Sprint(kinds) != "[allergen:milk diet:vegan]" {
+		t.Errorf("warnings = %v", kinds)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	srv, _ := newServer(t)
+	anon := client{srv: srv}
+
+	anon.do(t, "GET", "/api/nothing-here", nil).wantError(t, http.StatusNotFound, "not_found")
+	res := anon.do(t, "DELETE", "/api/products", nil)
+	res.wantError(t, http.StatusMethodNotAllowed, "method_not_allowed")
+	if allow := res.Header.Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("Allow = %q", allow)
+	}
+
+	traced := anon
+	traced.header = http.Header{"X-Request-Id": {"trace-123"}}
+	if id := traced.do(t, "GET", "/api/nothing-here", nil).Header.Get("X-Request-ID"); id != "trace-123" {
+		t.Errorf("X-Request-ID = %q, want the client's", id)
+	}
+
+	large := fmt.Sprintf(`{"barcodes": [%q]}`, bytes.Repeat([]byte("1"), int(config.MaxBodyBytes)))
+	anon.do(t, "POST", "/api/basket", large).wantError(t, http.StatusRequestEntityTooLarge, "body_too_large")
+
+	preflight := anon.do(t, "OPTIONS", "/api/basket", nil).want(t, http.StatusOK)
+	if preflight.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("preflight headers = %v", preflight.Header)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/auth"
+	"backend/config"
+	"backend/models"
+	"backend/repository"
+	"backend/utils"
+)
+
+// credentials is the body of signup; login only checks that both are present.
+// bcrypt reads at most 72 bytes of a password.
+type credentials struct {
+	Email    string `json:"email" validate:"required,maxlen=254"`
+	Password string `json:"password" validate:"required,minlen=8,maxlen=72"`
+	Name     string `json:"name" validate:"maxlen=100"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Signup creates a user account and returns an access token. The active basket
+// of the X-Session-ID session, if any, moves to the new account.
+func (h *Handler) Signup(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to create user", err))
+		return
+	}
+
+	role := config.DefaultRole
+	for _, admin := range config.AdminEmails {
+		if admin == req.Email {
+			role = string(auth.RoleAdmin)
+		}
+	}
+
+	user := models.User{
+		Email:        req.Email,
+		Name:         req.Name,
+		Role:         role,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	}
+	if err := h.Users.Create(r.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			utils.Error(w, utils.Conflict("Email already registered"))
+			return
+		}
+		utils.Error(w, utils.Internal("Failed to create user", err))
+		return
+	}
+
+	h.adoptSessionBasket(w, r, user.ID.Hex())
+	writeSession(w, http.StatusCreated, user)
+}
+
+// Login verifies email/password and returns an access token. As on signup,
+// the X-Session-ID basket is merged into the user's.
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// an unknown email and a wrong password get the same answer
+	user, err := h.Users.FindByEmail(r.Context(), strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.Internal("Failed to fetch user", err))
+		return
+	}
+	if err != nil || !auth.CheckPassword(user.PasswordHash, req.Password) {
+		utils.Error(w, utils.Unauthorized("Invalid email or password"))
+		return
+	}
+
+	h.adoptSessionBasket(w, r, user.ID.Hex())
+	writeSession(w, http.StatusOK, *user)
+}
+
+// GetMe returns the authenticated user's profile
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.Users.FindByID(r.Context(), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch user", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "user": user})
+}
+
+type roleRequest struct {
+	UserID string `json:"user_id" validate:"required,maxlen=64"`
+	Role   string `json:"role" validate:"required,oneof=viewer contributor moderator admin"`
+}
+
+// SetUserRole lets an admin change another user's role: { user_id, role }.
+// The new role applies at once, including to tokens already issued.
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	var req roleRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := h.Users.SetRole(r.Context(), req.UserID, req.Role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.Error(w, utils.NotFound("User not found"))
+			return
+		}
+		utils.Error(w, utils.Internal("Failed to update role", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// UserRole is the auth.RoleFunc behind the middleware: the role stored on the
+// user, or viewer for token subjects without an account here, e.g. from a JWKS issuer
+func (h *Handler) UserRole(ctx context.Context, userID string) (auth.Role, error) {
+	user, err := h.Users.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return auth.RoleViewer, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if role := auth.Role(user.Role); role.Valid() {
+		return role, nil
+	}
+	return auth.RoleViewer, nil
+}
+
+func writeSession(w http.ResponseWriter, status int, user models.User) {
+	role := auth.Role(user.Role)
+	if !role.Valid() {
+		role = auth.RoleViewer
+	}
+	user.Role = string(role)
+
+	token, err := auth.IssueToken(user.ID.Hex(), role)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to issue token", err))
+		return
+	}
+	utils.JSON(w, status, map[string]interface{}{"success": true, "token": token, "user": user})
+}
+
+// currentUser returns the user id set by the auth middleware, writing a 401 if there is none
+func currentUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := auth.UserID(r.Context())
+	if userID == "" {
+		utils.Error(w, utils.Unauthorized("Unauthorized"))
+		return "", false
+	}
+	return userID, true
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/barcode"
+	basketsvc "backend/services/basket"
+	"backend/utils"
+)
+
+// parseBarcode normalizes a single barcode to GTIN-14, writing a 400 if it is invalid
+func parseBarcode(w http.ResponseWriter, code string) (string, bool) {
+	gtin, err := barcode.GTIN(code)
+	if err != nil {
+		var be *barcode.Error
+		errors.As(err, &be)
+		writeBarcodeErrors(w, []*barcode.Error{be})
+		return "", false
+	}
+	return gtin, true
+}
+
+// normalizeLines rewrites every basket line to GTIN-14, writing one 400 listing all invalid codes
+func normalizeLines(w http.ResponseWriter, lines []basketsvc.BasketLine) bool {
+	var invalid []*barcode.Error
+	for i := range lines {
+		gtin, err := barcode.GTIN(lines[i].Barcode)
+		if err != nil {
+			var be *barcode.Error
+			errors.As(err, &be)
+			invalid = append(invalid, be)
+			continue
+		}
+		lines[i].Barcode = gtin
+	}
+	if len(invalid) > 0 {
+		writeBarcodeErrors(w, invalid)
+		return false
+	}
+	return true
+}
+
+// writeBarcodeErrors reports every invalid code under details.invalid_barcodes
+func writeBarcodeErrors(w http.ResponseWriter, invalid []*barcode.Error) {
+	utils.Error(w, barcodeError(invalid))
+}
+
+func barcodeError(invalid []*barcode.Error) *utils.APIError {
+	return utils.NewError(http.StatusBadRequest, utils.CodeInvalidBarcode, invalid[0].Error()).
+		WithDetails(map[string]interface{}{"invalid_barcodes": invalid})
+}
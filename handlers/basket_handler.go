@@ -1,256 +1,209 @@
 package handlers
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"regexp"
 	"time"
 
-	"backend/db"
+	"backend/auth"
+	"backend/models"
+	"backend/repository"
+	basketsvc "backend/services/basket"
 	"backend/utils"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var basket []interface{}
+// IdempotencyKeyHeader lets clients retry a basket save without saving it twice
+const IdempotencyKeyHeader = "Idempotency-Key"
 
-func AddToBasket(w http.ResponseWriter, r *http.Request) {
-	var item map[string]interface{}
-	err := json.NewDecoder(r.Body).Decode(&item)
-	if err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
-		return
-	}
+// ReplayedHeader marks a response that repeats an earlier save with the same Idempotency-Key
+const ReplayedHeader = "Idempotent-Replayed"
 
-	basket = append(basket, item)
-	utils.JSON(w, http.StatusOK, basket)
-}
+var idempotencyKey = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
 
-func GetBasket(w http.ResponseWriter, r *http.Request) {
-	utils.JSON(w, http.StatusOK, basket)
+// basketRequest accepts either a plain barcode list or items with quantities,
+// or both. Whichever is sent must not be empty.
+type basketRequest struct {
+	Barcodes *[]string            `json:"barcodes" validate:"minitems=1,maxitems=200,dive,required,maxlen=32"`
+	Items    *[]models.BasketItem `json:"items" validate:"minitems=1,maxitems=200"`
 }
 
-// AnalyzeBasketAPI accepts { barcodes: string[] } and returns simple aggregated stats
-func AnalyzeBasketAPI(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Barcodes []string `json:"barcodes"`
+// lines normalizes the requested lines, writing a 400 when there are none or a barcode is invalid
+func (req basketRequest) lines(w http.ResponseWriter) ([]basketsvc.BasketLine, bool) {
+	var lines []basketsvc.BasketLine
+	if req.Barcodes != nil {
+		lines = append(lines, basketsvc.LinesFromBarcodes(*req.Barcodes)...)
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
-		return
+	if req.Items != nil {
+		lines = append(lines, basketsvc.LinesFromItems(*req.Items)...)
 	}
-
-	type Item struct {
-		Barcode     string  `json:"barcode"`
-		ProductName string  `json:"product_name"`
-		Carbon      float64 `json:"carbon"`
-		HealthScore int     `json:"health_score"`
+	if len(lines) == 0 {
+		utils.Error(w, utils.BadRequest("Basket is empty"))
+		return nil, false
 	}
+	return lines, normalizeLines(w, lines)
+}
 
-	var items []Item
-	var totalCarbon float64
-	var totalHealth int
-
-	for _, code := range req.Barcodes {
-		var prod map[string]interface{}
-		err := db.DB.Collection("products").FindOne(context.Background(), bson.M{"barcode": code}).Decode(&prod)
-		eco := 50
-		name := ""
-		if err == nil {
-			if v, ok := prod["ecoScore"].(float64); ok {
-				eco = int(v)
-			} else if v, ok := prod["ecoScore"].(int); ok {
-				eco = v
-			} else if v, ok := prod["EcoScore"].(float64); ok {
-				eco = int(v)
-			}
-			if n, ok := prod["name"].(string); ok {
-				name = n
-			} else if n, ok := prod["Name"].(string); ok {
-				name = n
-			}
-		}
-
-		carbon := (100 - float64(eco)) * 0.05 // arbitrary formula
-		items = append(items, Item{Barcode: code, ProductName: name, Carbon: carbon, HealthScore: eco})
-		totalCarbon += carbon
-		totalHealth += eco
+// AnalyzeBasketAPI accepts { barcodes: string[] } or { items: [{productId, quantity}] } and returns aggregated stats
+func (h *Handler) AnalyzeBasketAPI(w http.ResponseWriter, r *http.Request) {
+	var req basketRequest
+	if !decodeBody(w, r, &req) {
+		return
 	}
 
-	avgHealth := 0
-	if len(items) > 0 {
-		avgHealth = totalHealth / len(items)
+	lines, ok := req.lines(w)
+	if !ok {
+		return
 	}
 
-	resp := map[string]interface{}{
-		"success": true,
-		"basket": map[string]interface{}{
-			"total_items":      len(items),
-			"total_carbon":     totalCarbon,
-			"avg_health_score": avgHealth,
-			"items":            items,
-		},
+	// signed-in callers also get warnings for their dietary profile
+	profile := h.dietaryProfile(r.Context(), auth.UserID(r.Context()))
+	report, err := h.basket.AnalyzeFor(r.Context(), lines, profile)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to analyze basket", err))
+		return
 	}
-	utils.JSON(w, http.StatusOK, resp)
+
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "basket": report})
 }
 
-// SaveBasketAPI saves the analyzed basket into the `baskets` collection
-func SaveBasketAPI(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Barcodes []string `json:"barcodes"`
+// SaveBasketAPI saves the analyzed basket into the `baskets` collection for the current user
+func (h *Handler) SaveBasketAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	key, done := h.savedBefore(w, r, userID)
+	if done {
 		return
 	}
 
-	type Item struct {
-		Barcode     string  `json:"barcode"`
-		ProductName string  `json:"product_name"`
-		Carbon      float64 `json:"carbon"`
-		HealthScore int     `json:"health_score"`
+	var req basketRequest
+	if !decodeBody(w, r, &req) {
+		return
 	}
 
-	var items []Item
-	var totalCarbon float64
-	var totalHealth int
+	lines, ok := req.lines(w)
+	if !ok {
+		return
+	}
 
-	for _, code := range req.Barcodes {
-		var prod map[string]interface{}
-		err := db.DB.Collection("products").FindOne(context.Background(), bson.M{"barcode": code}).Decode(&prod)
-		eco := 50
-		name := ""
-		if err == nil {
-			if v, ok := prod["ecoScore"].(float64); ok {
-				eco = int(v)
-			} else if v, ok := prod["ecoScore"].(int); ok {
-				eco = v
-			} else if v, ok := prod["EcoScore"].(float64); ok {
-				eco = int(v)
-			}
-			if n, ok := prod["name"].(string); ok {
-				name = n
-			} else if n, ok := prod["Name"].(string); ok {
-				name = n
-			}
-		}
+	h.saveBasket(w, r, userID, key, lines)
+}
 
-		carbon := (100 - float64(eco)) * 0.05
-		items = append(items, Item{Barcode: code, ProductName: name, Carbon: carbon, HealthScore: eco})
-		totalCarbon += carbon
-		totalHealth += eco
+// savedBefore reads the Idempotency-Key header and, when the user already saved
+// a basket under that key, answers with it instead of saving again. It returns
+// the key to save under and whether the request has been answered.
+func (h *Handler) savedBefore(w http.ResponseWriter, r *http.Request, userID string) (string, bool) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return "", false
 	}
-
-	avgHealth := 0
-	if len(items) > 0 {
-		avgHealth = totalHealth / len(items)
+	if !idempotencyKey.MatchString(key) {
+		utils.Error(w, utils.BadRequest(IdempotencyKeyHeader+" must be 1 to 255 printable ASCII characters"))
+		return "", true
 	}
-
-	record := bson.M{
-		"barcodes":         req.Barcodes,
-		"items":            items,
-		"total_items":      len(items),
-		"total_carbon":     totalCarbon,
-		"avg_health_score": avgHealth,
-		"created_at":       time.Now(),
+	saved, err := h.Baskets.FindByIdempotencyKey(r.Context(), userID, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return key, false
 	}
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch basket", err))
+		return "", true
+	}
+	h.replaySave(w, r, saved)
+	return "", true
+}
 
-	res, err := db.DB.Collection("baskets").InsertOne(context.Background(), record)
+// replaySave answers a repeated save with the basket saved the first time and
+// the current impact totals; badges were already reported by the first response
+func (h *Handler) replaySave(w http.ResponseWriter, r *http.Request, saved *models.Basket) {
+	impact, err := h.Impact.Get(r.Context(), saved.UserID)
 	if err != nil {
-		http.Error(w, "Failed to save basket", http.StatusInternalServerError)
+		utils.Error(w, utils.Internal("Failed to fetch impact", err))
 		return
 	}
+	w.Header().Set(ReplayedHeader, "true")
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "basket": saved, "impact": impact, "new_badges": []models.Badge{}})
+}
 
-	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
-		record["id"] = oid.Hex()
+// saveBasket analyzes normalized lines into a saved basket of userID, updates
+// the impact totals, goals and badges, and writes the response. The active
+// basket checkout goes through here too. A non-empty key is the request's
+// Idempotency-Key, checked with savedBefore.
+func (h *Handler) saveBasket(w http.ResponseWriter, r *http.Request, userID, key string, lines []basketsvc.BasketLine) bool {
+	report, err := h.basket.AnalyzeFor(r.Context(), lines, h.dietaryProfile(r.Context(), userID))
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to analyze basket", err))
+		return false
+	}
+
+	barcodes := make([]string, len(lines))
+	for i, l := range lines {
+		barcodes[i] = l.Barcode
+	}
+
+	record := models.Basket{
+		UserID:          userID,
+		Barcodes:        barcodes,
+		Items:           report.Items,
+		TotalItems:      report.TotalItems,
+		TotalCarbon:     report.TotalCarbon,
+		AvgHealthScore:  report.AvgHealthScore,
+		UnknownBarcodes: report.UnknownBarcodes,
+		CreatedAt:       time.Now(),
+		IdempotencyKey:  key,
+	}
+
+	// The basket and its impact totals (one document per user, including
+	// total_score for cumulative basket scores) are written together, so a
+	// failed save leaves nothing behind to double-count when it is retried.
+	impact, err := h.Saver.Save(r.Context(), &record)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// a concurrent request with the same key saved first
+		if saved, err := h.Baskets.FindByIdempotencyKey(r.Context(), userID, key); err == nil {
+			h.replaySave(w, r, saved)
+			return false
+		}
 	}
-
-	// Update impact totals (global single document)
-	// include total_score so the app can track cumulative basket scores
-	inc := bson.M{"$inc": bson.M{"total_carbon_saved": record["total_carbon"], "total_baskets": 1, "total_score": record["avg_health_score"]}}
-	setOnInsert := bson.M{"$setOnInsert": bson.M{"created_at": time.Now()}}
-	update := bson.M{"$setOnInsert": setOnInsert["$setOnInsert"], "$inc": inc["$inc"]}
-	// Using options to upsert
-	_, _ = db.DB.Collection("impact").UpdateOne(context.Background(), bson.M{"_id": "global"}, update, options.Update().SetUpsert(true))
-
-	// Award badges based on thresholds
-	// Simple badge rules:
-	// 1 - First Basket (total_baskets >= 1)
-	// 2 - Carbon Saver (total_carbon_saved >= 10)
-	// 3 - Super Saver (total_carbon_saved >= 100)
-
-	var impactDoc bson.M
-	_ = db.DB.Collection("impact").FindOne(context.Background(), bson.M{"_id": "global"}).Decode(&impactDoc)
-	totalCarbonSaved := 0.0
-	if v, ok := impactDoc["total_carbon_saved"].(float64); ok {
-		totalCarbonSaved = v
-	} else if v, ok := impactDoc["total_carbon_saved"].(int32); ok {
-		totalCarbonSaved = float64(v)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to save basket", err))
+		return false
 	}
 
-	// read cumulative total_score (sum of avg_health_score across saved baskets)
-	totalScore := 0.0
-	if v, ok := impactDoc["total_score"].(float64); ok {
-		totalScore = v
-	} else if v, ok := impactDoc["total_score"].(int32); ok {
-		totalScore = float64(v)
-	}
-	// read total_baskets for potential badge rules
-	totalBaskets := int64(0)
-	if v, ok := impactDoc["total_baskets"].(int32); ok {
-		totalBaskets = int64(v)
-	} else if v, ok := impactDoc["total_baskets"].(int64); ok {
-		totalBaskets = v
-	}
+	// goals and badges are derived from the saved baskets and re-evaluated on
+	// every event, so they stay outside the save
+	newBadges := h.recordActivity(r.Context(), userID)
 
-	// Helper to award badge if not already awarded
-	awardBadge := func(badgeID int, name, desc string) {
-		// check if exists
-		count, _ := db.DB.Collection("user_badges").CountDocuments(context.Background(), bson.M{"badge_id": badgeID})
-		if count == 0 {
-			db.DB.Collection("user_badges").InsertOne(context.Background(), bson.M{"badge_id": badgeID, "badge": bson.M{"id": badgeID, "name": name, "description": desc}, "earned_at": time.Now()})
-		}
-	}
-
-	// Always award First Basket if not present
-	awardBadge(1, "First Basket", "Saved your first basket")
-	if totalCarbonSaved >= 10 {
-		awardBadge(2, "Carbon Saver", "Saved 10kg CO2 or more")
-	}
-	if totalCarbonSaved >= 100 {
-		awardBadge(3, "Super Saver", "Saved 100kg CO2 or more")
+	resp := map[string]interface{}{"success": true, "basket": record, "impact": impact, "new_badges": newBadges}
+	if report.Warnings != nil {
+		resp["warnings"] = report.Warnings
 	}
+	utils.JSON(w, http.StatusOK, resp)
+	return true
+}
 
-	// Score / consistency based badges
-	// 4 - Consistent Shopper (saved >= 10 baskets)
-	if totalBaskets >= 10 {
-		awardBadge(4, "Consistent Shopper", "Saved 10 baskets")
-	}
-	// 5 - Healthy Shopper (cumulative score threshold)
-	if totalScore >= 500 {
-		awardBadge(5, "Healthy Shopper", "Accumulated 500+ health score")
+// GetBasketsAPI returns the current user's saved baskets (most recent first)
+func (h *Handler) GetBasketsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
 	}
 
-	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "basket": record})
-}
-
-// GetBasketsAPI returns saved baskets (most recent first)
-func GetBasketsAPI(w http.ResponseWriter, r *http.Request) {
-	cursor, err := db.DB.Collection("baskets").Find(context.Background(), bson.M{})
-	if err != nil {
-		http.Error(w, "Failed to fetch baskets", http.StatusInternalServerError)
+	req, ok := parseList(w, r)
+	if !ok {
 		return
 	}
-	var results []bson.M
-	cursor.All(context.Background(), &results)
 
-	for i := range results {
-		if oid, ok := results[i]["_id"].(primitive.ObjectID); ok {
-			results[i]["id"] = oid.Hex()
-		}
+	page, err := h.Baskets.ListByUser(r.Context(), userID, req.query)
+	if listFailed(w, err, "Failed to fetch baskets") {
+		return
 	}
 
-	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "baskets": results})
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"baskets":     listItems(w, page, req.fields),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+	})
 }
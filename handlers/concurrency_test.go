@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"backend/handlers"
+	"backend/models"
+)
+
+// call is do for concurrent clients: a failed request or an error status is
+// reported without stopping the test, and the body is nil
+func (c client) call(t *testing.T, method, path string, body interface{}) map[string]interface{} {
+	t.Helper()
+	res, err := c.try(method, path, body)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	if res.Status >= 300 {
+		t.Errorf("status %d: %s", res.Status, res.Raw)
+		return nil
+	}
+	return res.Body
+}
+
+// TestConcurrentClients drives the full HTTP stack with parallel clients, then
+// checks that no update was lost: impact totals match the saved baskets, no
+// badge was awarded twice, and a basket shared by every client holds exactly
+// the quantity they added. Some saves are sent twice at once with the same
+// Idempotency-Key and must count once. Run it with -race.
+func TestConcurrentClients(t *testing.T) {
+	users, ops := 8, 25
+	if testing.Short() {
+		users, ops = 4, 10
+	}
+
+	codes := make([]string, 50)
+	products := make([]models.Product, len(codes))
+	for i := range codes {
+		codes[i] = gtin(i)
+		products[i] = models.Product{
+			Barcode:  codes[i],
+			Name:     "Product " + strconv.Itoa(i),
+			Category: "yogurts",
+			Quantity: "500 g",
+		}
+	}
+	srv, _ := newServer(t, products...)
+
+	// every client also adds to one anonymous basket
+	shared := client{srv: srv, session: "concurrency-shared-session"}
+	var sharedAdds atomic.Int64
+
+	t.Run("clients", func(t *testing.T) {
+		for u := 0; u < users; u++ {
+			t.Run(strconv.Itoa(u), func(t *testing.T) {
+				t.Parallel()
+				c := signup(t, srv, "load"+strconv.Itoa(u)+"@example.com")
+				c.call(t, "POST", "/api/goals", map[string]interface{}{"type": "baskets", "target_value": 3})
+
+				saved, carbon := 0, 0.0
+				for i := 0; i < ops; i++ {
+					code := codes[(u*7+i)%len(codes)]
+					c.call(t, "POST", "/history/add?barcode="+code, nil)
+					c.call(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": code, "quantity": 1})
+					c.call(t, "GET", "/api/badges", nil)
+					c.call(t, "GET", "/api/impact/stats", nil)
+					if shared.call(t, "POST", "/api/basket/active/items", map[string]interface{}{"productId": codes[0], "quantity": 1}) != nil {
+						sharedAdds.Add(1)
+					}
+
+					path, body := "/api/basket/save", interface{}(map[string]interface{}{"barcodes": []string{code, codes[(i+1)%len(codes)]}})
+					if i%5 == 4 {
+						path, body = "/api/basket/active/checkout", nil
+					}
+					if i%5 == 2 {
+						// a retried save racing the original must be saved once
+						keyed := c
+						keyed.header = http.Header{handlers.IdempotencyKeyHeader: {"load-" + strconv.Itoa(u) + "-" + strconv.Itoa(i)}}
+						var retry sync.WaitGroup
+						baskets := make([]map[string]interface{}, 2)
+						for k := range baskets {
+							retry.Add(1)
+							go func() {
+								defer retry.Done()
+								res, err := keyed.try("POST", path, body)
+								if err != nil || res.Status != http.StatusOK {
+									t.Errorf("keyed save: %v %d %s", err, res.Status, res.Raw)
+									return
+								}
+								baskets[k] = res.object("basket")
+							}()
+						}
+						retry.Wait()
+						if baskets[0] != nil && baskets[1] != nil {
+							if baskets[0]["id"] != baskets[1]["id"] {
+								t.Errorf("one Idempotency-Key saved baskets %v and %v", baskets[0]["id"], baskets[1]["id"])
+							}
+							saved++
+							carbon += baskets[0]["total_carbon"].(float64)
+						}
+					}
+					out := c.call(t, "POST", path, body)
+					if out == nil {
+						continue
+					}
+					saved++
+					if b, isBasket := out["basket"].(map[string]interface{}); isBasket {
+						carbon += b["total_carbon"].(float64)
+					}
+				}
+
+				// the totals must account for every basket this client saved
+				stats := c.do(t, "GET", "/api/impact/stats", nil).want(t, http.StatusOK).object("stats")
+				if n := int(stats["total_baskets"].(float64)); n != saved {
+					t.Errorf("impact counts %d baskets, %d were saved", n, saved)
+				}
+				if got := stats["total_carbon_saved"].(float64); math.Abs(got-carbon) > 1e-6 {
+					t.Errorf("impact carbon %.3f, saved baskets total %.3f", got, carbon)
+				}
+
+				seen := map[float64]bool{}
+				for _, b := range c.do(t, "GET", "/api/badges", nil).want(t, http.StatusOK).list("badges") {
+					id := b.(map[string]interface{})["badge_id"].(float64)
+					if seen[id] {
+						t.Errorf("badge %v awarded twice", id)
+					}
+					seen[id] = true
+				}
+			})
+		}
+	})
+
+	items := shared.do(t, "GET", "/api/basket/active", nil).want(t, http.StatusOK).object("basket")["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("shared basket has %d lines, want 1", len(items))
+	}
+	if q := int64(items[0].(map[string]interface{})["quantity"].(float64)); q != sharedAdds.Load() {
+		t.Errorf("shared basket quantity %d, %d were added", q, sharedAdds.Load())
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/barcode"
+	"backend/repository"
+	"backend/utils"
+	"backend/validate"
+)
+
+// apiError gives the errors of the domain packages their API status and code.
+// An *utils.APIError and anything unknown pass through unchanged, so
+// utils.Error writes the former as is and hides the latter behind a 500.
+func apiError(err error) error {
+	var (
+		queryErr   *repository.QueryError
+		barcodeErr *barcode.Error
+		fieldErrs  validate.Errors
+		apiErr     *utils.APIError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &fieldErrs):
+		return utils.NewError(http.StatusUnprocessableEntity, utils.CodeValidation, "Invalid request: "+fieldErrs[0].Error()).
+			WithDetails(fieldErrs)
+	case errors.Is(err, repository.ErrNotFound):
+		return utils.NotFound("Not found")
+	case errors.Is(err, repository.ErrDuplicate):
+		return utils.Conflict("Already exists")
+	case errors.Is(err, repository.ErrBadCursor):
+		return utils.BadRequest("Invalid cursor")
+	case errors.As(err, &queryErr):
+		return utils.BadRequest("Invalid " + queryErr.Error())
+	case errors.As(err, &barcodeErr):
+		return barcodeError([]*barcode.Error{barcodeErr})
+	}
+	return err
+}
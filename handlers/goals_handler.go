@@ -1,72 +1,215 @@
 package handlers
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
-	"backend/db"
+	"backend/models"
+	"backend/repository"
+	goalsvc "backend/services/goals"
 	"backend/utils"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"backend/validate"
 )
 
-// GoalsHandler routes GET and POST for /api/goals
-func GoalsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		getGoals(w, r)
-	case http.MethodPost:
-		createGoal(w, r)
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+// goalRequest is the body of POST /api/goals and PATCH /api/goals/{id}; on
+// PATCH only the fields present are changed. Progress is always computed.
+type goalRequest struct {
+	Type        *string  `json:"type" validate:"enum=goal_type"`
+	Description *string  `json:"description" validate:"maxlen=500"`
+	TargetValue *float64 `json:"target_value" validate:"gt=0,max=1000000000"`
+	Window      *string  `json:"window" validate:"enum=goal_window"`
+}
+
+// apply copies the validated request onto g, reporting whether the goal's measure changed
+func (req goalRequest) apply(g *models.Goal) (changed bool) {
+	if req.Type != nil {
+		t, _ := goalsvc.NormalizeType(*req.Type)
+		changed = changed || t != g.Type
+		g.Type = t
+	}
+	if req.Window != nil {
+		win, _ := goalsvc.NormalizeWindow(*req.Window)
+		changed = changed || win != g.Window
+		g.Window = win
 	}
+	if req.TargetValue != nil {
+		changed = changed || *req.TargetValue != g.TargetValue
+		g.TargetValue = *req.TargetValue
+	}
+	if req.Description != nil {
+		g.Description = strings.TrimSpace(*req.Description)
+	}
+	return changed
 }
 
-func getGoals(w http.ResponseWriter, r *http.Request) {
-	cursor, err := db.DB.Collection("goals").Find(context.Background(), bson.M{})
-	if err != nil {
-		utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "goals": []interface{}{}})
+// GetGoals handles GET /api/goals for the current user
+func (h *Handler) GetGoals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
 		return
 	}
-	var results []bson.M
-	cursor.All(context.Background(), &results)
-	for i := range results {
-		if oid, ok := results[i]["_id"].(primitive.ObjectID); ok {
-			results[i]["id"] = oid.Hex()
-		}
+
+	req, ok := parseList(w, r)
+	if !ok {
+		return
+	}
+
+	// rolling windows move on even without new baskets or scans
+	if _, err := h.tracker.Refresh(r.Context(), userID); err != nil {
+		utils.Error(w, utils.Internal("Failed to update goals", err))
+		return
+	}
+	page, err := h.Goals.ListByUser(r.Context(), userID, req.query)
+	if listFailed(w, err, "Failed to fetch goals") {
+		return
 	}
-	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "goals": results})
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"goals":       listItems(w, page, req.fields),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+	})
 }
 
-func createGoal(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Type        string  `json:"type"`
-		Description string  `json:"description"`
-		TargetValue float64 `json:"target_value"`
-		Progress    float64 `json:"progress"`
+// CreateGoal handles POST /api/goals: { type, target_value, window, description }
+func (h *Handler) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req goalRequest
+	if !decodeBody(w, r, &req) {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	// required only here: PATCH changes just the fields it sends
+	var missing validate.Errors
+	if req.Type == nil {
+		missing = append(missing, validate.Missing("type"))
+	}
+	if req.TargetValue == nil {
+		missing = append(missing, validate.Missing("target_value"))
+	}
+	if missing != nil {
+		utils.Error(w, apiError(missing))
 		return
 	}
 
-	record := bson.M{
-		"type":         req.Type,
-		"description":  req.Description,
-		"target_value": req.TargetValue,
-		"progress":     req.Progress,
-		"created_at":   time.Now(),
+	now := time.Now()
+	goal := models.Goal{
+		UserID:    userID,
+		Window:    goalsvc.WindowSinceCreated,
+		Status:    models.GoalActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	req.apply(&goal)
+	if err := h.tracker.Track(r.Context(), &goal); err != nil {
+		utils.Error(w, utils.Internal("Failed to measure goal", err))
+		return
+	}
+	if err := h.Goals.Insert(r.Context(), &goal); err != nil {
+		utils.Error(w, utils.Internal("Failed to create goal", err))
+		return
+	}
+	if !goal.Active() {
+		h.recordActivity(r.Context(), userID)
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "goal": goal})
+}
+
+// goalFromPath loads the current user's goal named by the {id} path value, writing a 404 if there is none
+func (h *Handler) goalFromPath(w http.ResponseWriter, r *http.Request) (*models.Goal, bool) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	goal, err := h.Goals.FindByID(r.Context(), userID, r.PathValue("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.NotFound("Goal not found"))
+		return nil, false
 	}
-	res, err := db.DB.Collection("goals").InsertOne(context.Background(), record)
 	if err != nil {
-		http.Error(w, "Failed to create goal", http.StatusInternalServerError)
+		utils.Error(w, utils.Internal("Failed to fetch goal", err))
+		return nil, false
+	}
+	return goal, true
+}
+
+// GetGoal handles GET /api/goals/{id} with freshly measured progress
+func (h *Handler) GetGoal(w http.ResponseWriter, r *http.Request) {
+	goal, ok := h.goalFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := h.tracker.Track(r.Context(), goal); err != nil {
+		utils.Error(w, utils.Internal("Failed to measure goal", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "goal": goal})
+}
+
+// UpdateGoal handles PATCH /api/goals/{id}. Changing the type, target or
+// window reopens a completed goal and measures it again.
+func (h *Handler) UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	goal, ok := h.goalFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req goalRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.apply(goal) {
+		goal.Status = models.GoalActive
+		goal.CompletedAt = nil
+	}
+	if err := h.tracker.Track(r.Context(), goal); err != nil {
+		utils.Error(w, utils.Internal("Failed to measure goal", err))
+		return
+	}
+	h.saveGoal(w, r, goal)
+}
+
+// CompleteGoal handles POST /api/goals/{id}/complete, closing a goal before its target is reached
+func (h *Handler) CompleteGoal(w http.ResponseWriter, r *http.Request) {
+	goal, ok := h.goalFromPath(w, r)
+	if !ok {
+		return
+	}
+	goalsvc.Complete(goal, time.Now())
+	h.saveGoal(w, r, goal)
+}
+
+// DeleteGoal handles DELETE /api/goals/{id}
+func (h *Handler) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Goals.Delete(r.Context(), userID, r.PathValue("id")); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			utils.Error(w, utils.NotFound("Goal not found"))
+			return
+		}
+		utils.Error(w, utils.Internal("Failed to delete goal", err))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// saveGoal stores goal and writes it; completing a goal can earn a badge
+func (h *Handler) saveGoal(w http.ResponseWriter, r *http.Request, goal *models.Goal) {
+	goal.UpdatedAt = time.Now()
+	if err := h.Goals.Update(r.Context(), goal); err != nil {
+		utils.Error(w, utils.Internal("Failed to update goal", err))
 		return
 	}
-	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
-		record["id"] = oid.Hex()
+	if !goal.Active() {
+		h.recordActivity(r.Context(), goal.UserID)
 	}
-	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "goal": record})
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "goal": goal})
 }
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+
+	"backend/config"
+	"backend/importer"
+	"backend/models"
+	"backend/repository"
+	"backend/services/badges"
+	basketsvc "backend/services/basket"
+	goalsvc "backend/services/goals"
+	"backend/services/metrics"
+	"backend/services/recommend"
+)
+
+// Handler holds the dependencies shared by every HTTP handler
+type Handler struct {
+	Products repository.ProductRepo
+	Baskets  repository.BasketRepo
+	Saver    repository.BasketSaver
+	Active   repository.ActiveBasketRepo
+	History  repository.HistoryRepo
+	Goals    repository.GoalRepo
+	Badges   repository.BadgeRepo
+	Impact   repository.ImpactRepo
+	Users    repository.UserRepo
+
+	// Importer is nil when no external product source is configured
+	Importer *importer.Importer
+
+	basket       *basketsvc.Service
+	recommend    *recommend.Engine
+	tracker      *goalsvc.Tracker
+	awards       *badges.Evaluator
+	productCache *basketsvc.CachedProductStore
+}
+
+// New wires handlers to the given repositories. Unknown barcodes are imported
+// when an Open Food Facts source is configured, and basket analysis goes
+// through an LRU of products when config.ProductCacheSize is set.
+func New(repos *repository.Repos) *Handler {
+	h := &Handler{
+		Products: repos.Products,
+		Baskets:  repos.Baskets,
+		Saver:    repos.Saver,
+		Active:   repos.Active,
+		History:  repos.History,
+		Goals:    repos.Goals,
+		Badges:   repos.Badges,
+		Impact:   repos.Impact,
+		Users:    repos.Users,
+	}
+
+	switch {
+	case config.OFFBaseURL != "":
+		h.Importer = importer.New(importer.NewHTTPSource(config.OFFBaseURL), repos.Products)
+	case config.OFFDumpFile != "":
+		h.Importer = importer.New(importer.NewDumpSource(config.OFFDumpFile), repos.Products)
+	}
+
+	var store basketsvc.ProductStore = repos.Products
+	if h.Importer != nil {
+		store = h.Importer
+	}
+	if config.ProductCacheSize > 0 {
+		h.productCache = basketsvc.NewCachedProductStore(store, config.ProductCacheSize, config.ProductCacheTTL)
+		store = h.productCache
+	}
+	h.basket = basketsvc.New(store, nil)
+	h.recommend = recommend.New(repos.Products)
+	meter := metrics.New(repos.Baskets, repos.History, repos.Goals)
+	h.tracker = goalsvc.New(repos.Goals, meter)
+	h.awards = badges.NewEvaluator(meter, repos.Badges)
+	return h
+}
+
+// findProduct looks a barcode up in the catalog, importing it if a source is configured
+func (h *Handler) findProduct(ctx context.Context, barcode string) (*models.Product, error) {
+	if h.Importer != nil {
+		return h.Importer.Lookup(ctx, barcode)
+	}
+	return h.Products.FindByBarcode(ctx, barcode)
+}
+
+// recordActivity brings goal progress and badges up to date after an event
+// that moves a metric, returning the badges it earned. Both are evaluated
+// again on the next event or read, so failures are not reported.
+func (h *Handler) recordActivity(ctx context.Context, userID string) []models.Badge {
+	_, _ = h.tracker.Refresh(ctx, userID)
+	earned, err := h.awards.Evaluate(ctx, userID)
+	if err != nil {
+		return []models.Badge{}
+	}
+	return earned
+}
@@ -1,50 +1,82 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"time"
 
-	"backend/db"
+	"backend/auth"
 	"backend/models"
 	"backend/utils"
 )
 
-func AddHistory(w http.ResponseWriter, r *http.Request) {
-	barcode := r.URL.Query().Get("barcode")
+func (h *Handler) AddHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	barcode, ok := parseBarcode(w, r.URL.Query().Get("barcode"))
+	if !ok {
+		return
+	}
 
 	history := models.ScanHistory{
+		UserID:  userID,
 		Barcode: barcode,
 		Time:    time.Now(),
 	}
 
-	db.DB.Collection("history").InsertOne(context.Background(), history)
+	if err := h.History.Add(r.Context(), history); err != nil {
+		utils.Error(w, utils.Internal("Failed to record scan", err))
+		return
+	}
+	h.recordActivity(r.Context(), userID)
 	utils.JSON(w, http.StatusCreated, history)
 }
 
-func GetHistory(w http.ResponseWriter, r *http.Request) {
-	cursor, err := db.DB.Collection("history").Find(context.Background(), map[string]interface{}{})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (h *Handler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	var history []models.ScanHistory
-	cursor.All(context.Background(), &history)
+	req, ok := parseList(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.History.ListByUser(r.Context(), userID, req.query)
+	if listFailed(w, err, "Failed to fetch history") {
+		return
+	}
 
 	// Return wrapped response for frontend compatibility
-	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "history": history})
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"history":     listItems(w, page, req.fields),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+	})
 }
 
-func ClearHistory(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// ClearHistory deletes the caller's scan history. Admins may clear another
+// user's history with ?user_id=<id>.
+func (h *Handler) ClearHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	_, err := db.DB.Collection("history").DeleteMany(context.Background(), map[string]interface{}{})
-	if err != nil {
-		http.Error(w, "Failed to clear history", http.StatusInternalServerError)
+	target := userID
+	if owner := r.URL.Query().Get("user_id"); owner != "" && owner != userID {
+		if !auth.UserRole(r.Context()).AtLeast(auth.RoleAdmin) {
+			utils.Error(w, utils.Forbidden("Forbidden: only the owner or an admin can clear history"))
+			return
+		}
+		target = owner
+	}
+
+	if err := h.History.ClearByUser(r.Context(), target); err != nil {
+		utils.Error(w, utils.Internal("Failed to clear history", err))
 		return
 	}
 
@@ -1,86 +1,92 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 	"time"
 
-	"backend/db"
 	"backend/utils"
-
-	"go.mongodb.org/mongo-driver/bson"
 )
 
-// GetImpactStats reads aggregated impact totals and recent weekly numbers
-func GetImpactStats(w http.ResponseWriter, r *http.Request) {
-	var impactDoc bson.M
-	_ = db.DB.Collection("impact").FindOne(context.Background(), bson.M{"_id": "global"}).Decode(&impactDoc)
-
-	totalCarbon := 0.0
-	if v, ok := impactDoc["total_carbon_saved"].(float64); ok {
-		totalCarbon = v
-	}
-
-	// include total_baskets and total_score if present
-	totalBaskets := 0
-	if v, ok := impactDoc["total_baskets"].(int32); ok {
-		totalBaskets = int(v)
-	} else if v, ok := impactDoc["total_baskets"].(int64); ok {
-		totalBaskets = int(v)
+// GetImpactStats reads the current user's impact totals and recent weekly numbers
+func (h *Handler) GetImpactStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
 	}
 
-	totalScore := 0.0
-	if v, ok := impactDoc["total_score"].(float64); ok {
-		totalScore = v
-	} else if v, ok := impactDoc["total_score"].(int32); ok {
-		totalScore = float64(v)
-	} else if v, ok := impactDoc["total_score"].(int64); ok {
-		totalScore = float64(v)
+	impact, err := h.Impact.Get(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch impact", err))
+		return
 	}
 
 	// compute weekly report: sum baskets in last 7 days
 	weekAgo := time.Now().AddDate(0, 0, -7)
-	cursor, err := db.DB.Collection("baskets").Find(context.Background(), bson.M{"created_at": bson.M{"$gte": weekAgo}})
-	weeklySum := 0.0
-	if err == nil {
-		var docs []bson.M
-		cursor.All(context.Background(), &docs)
-		for _, d := range docs {
-			if v, ok := d["total_carbon"].(float64); ok {
-				weeklySum += v
-			}
-		}
+	weeklySum, err := h.Baskets.SumCarbonSince(r.Context(), userID, weekAgo)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch baskets", err))
+		return
+	}
+
+	goals, err := h.tracker.Refresh(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch goals", err))
+		return
 	}
 
 	avgScore := 0.0
-	if totalBaskets > 0 {
-		avgScore = totalScore / float64(totalBaskets)
+	if impact.TotalBaskets > 0 {
+		avgScore = impact.TotalScore / float64(impact.TotalBaskets)
 	}
 
 	stats := map[string]interface{}{
-		"total_carbon_saved": totalCarbon,
-		"total_baskets":      totalBaskets,
-		"total_score":        totalScore,
+		"total_carbon_saved": impact.TotalCarbonSaved,
+		"total_baskets":      impact.TotalBaskets,
+		"total_score":        impact.TotalScore,
 		"average_score":      fmtFloat(avgScore),
 		"weekly_report":      "You reduced your carbon footprint by " + fmtFloat(weeklySum) + " kg this week",
-		"active_goals":       []interface{}{},
+		"active_goals":       goals,
 	}
 
 	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "stats": stats})
 }
 
-// GetBadges returns user badges from DB
-func GetBadges(w http.ResponseWriter, r *http.Request) {
-	cursor, err := db.DB.Collection("user_badges").Find(context.Background(), bson.M{})
+// GetBadges returns the badges the current user has earned, paginated, and
+// under "locked" the rest of the catalog with progress towards each
+func (h *Handler) GetBadges(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := parseList(w, r)
+	if !ok {
+		return
+	}
+
+	// catch up on badges whose rule or catalog changed since the last event
+	if _, err := h.awards.Evaluate(r.Context(), userID); err != nil {
+		utils.Error(w, utils.Internal("Failed to evaluate badges", err))
+		return
+	}
+	page, err := h.Badges.ListByUser(r.Context(), userID, req.query)
+	if listFailed(w, err, "Failed to fetch badges") {
+		return
+	}
+	locked, err := h.awards.Locked(r.Context(), userID)
 	if err != nil {
-		utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "badges": []interface{}{}})
+		utils.Error(w, utils.Internal("Failed to fetch badges", err))
 		return
 	}
-	var badges []bson.M
-	cursor.All(context.Background(), &badges)
 
-	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "badges": badges})
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"badges":      listItems(w, page, req.fields),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"locked":      locked,
+	})
 }
 
 func fmtFloat(f float64) string {
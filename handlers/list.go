@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/repository"
+	"backend/utils"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// listRequest is the query grammar shared by every list endpoint:
+// ?limit=&cursor=&sort=&fields=&since=&until=
+type listRequest struct {
+	query  repository.ListQuery
+	fields []string // top-level JSON fields to keep; empty keeps all
+}
+
+// parseList reads the list parameters, writing a 400 and returning false when one is malformed
+func parseList(w http.ResponseWriter, r *http.Request) (listRequest, bool) {
+	params := r.URL.Query()
+	req := listRequest{query: repository.ListQuery{
+		Limit:  defaultListLimit,
+		Cursor: params.Get("cursor"),
+		Sort:   params.Get("sort"),
+	}}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			utils.Error(w, utils.BadRequest("limit must be between 1 and "+strconv.Itoa(maxListLimit)))
+			return req, false
+		}
+		req.query.Limit = n
+	}
+	for _, f := range strings.Split(params.Get("fields"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			req.fields = append(req.fields, f)
+		}
+	}
+
+	var err error
+	if req.query.Since, err = parseListTime(params.Get("since")); err != nil {
+		utils.Error(w, utils.BadRequest("since must be RFC 3339 or YYYY-MM-DD"))
+		return req, false
+	}
+	if req.query.Until, err = parseListTime(params.Get("until")); err != nil {
+		utils.Error(w, utils.BadRequest("until must be RFC 3339 or YYYY-MM-DD"))
+		return req, false
+	}
+	if !req.query.Since.IsZero() && !req.query.Until.IsZero() && !req.query.Since.Before(req.query.Until) {
+		utils.Error(w, utils.BadRequest("since must be before until"))
+		return req, false
+	}
+	return req, true
+}
+
+func parseListTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// listFailed writes the response for a failed list query: 400 for bad cursors
+// and sort fields, otherwise a 500 with msg. It returns false when err is nil.
+func listFailed(w http.ResponseWriter, err error, msg string) bool {
+	if err == nil {
+		return false
+	}
+	if e := utils.FromError(apiError(err)); e.Status < http.StatusInternalServerError {
+		utils.Error(w, e)
+	} else {
+		utils.Error(w, utils.Internal(msg, err))
+	}
+	return true
+}
+
+// listItems sets the X-Total-Count and X-Next-Cursor headers and returns the
+// page's items, projected onto the requested fields
+func listItems[T any](w http.ResponseWriter, page repository.Page[T], fields []string) interface{} {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	if len(fields) == 0 {
+		return page.Items
+	}
+
+	projected := make([]map[string]json.RawMessage, 0, len(page.Items))
+	for _, it := range page.Items {
+		raw, err := json.Marshal(it)
+		if err != nil {
+			return page.Items
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &all); err != nil {
+			return page.Items
+		}
+		kept := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				kept[f] = v
+			}
+		}
+		projected = append(projected, kept)
+	}
+	return projected
+}
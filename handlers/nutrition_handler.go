@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend/models"
+	"backend/scoring"
+	"backend/utils"
+)
+
+// nutritionBasisG returns how many grams each view covers: "100g", "serving"
+// or "package". ok is false when the product lacks the size the view needs.
+func nutritionBasisG(p *models.Product, per string) (grams float64, ok bool) {
+	switch per {
+	case "100g":
+		return 100, true
+	case "serving":
+		if p.Nutrition != nil && p.Nutrition.ServingG > 0 {
+			return p.Nutrition.ServingG, true
+		}
+	case "package":
+		if kg := scoring.NetWeightKg(p.NetWeightG, p.Quantity); kg > 0 {
+			return kg * 1000, true
+		}
+	}
+	return 0, false
+}
+
+// productMacros handles GET /api/product/{barcode}/macros?per=100g|serving|package.
+// Unknown nutrients are null rather than zero.
+func (h *Handler) productMacros(w http.ResponseWriter, r *http.Request, product *models.Product) {
+	if product.Barcode == "" {
+		utils.Error(w, utils.NotFound("Product not found"))
+		return
+	}
+
+	per := r.URL.Query().Get("per")
+	if per == "" {
+		per = "100g"
+	}
+	views := []string{}
+	for _, v := range []string{"100g", "serving", "package"} {
+		if _, ok := nutritionBasisG(product, v); ok {
+			views = append(views, v)
+		}
+	}
+	grams, ok := nutritionBasisG(product, per)
+	if !ok {
+		switch per {
+		case "serving", "package":
+			utils.Error(w, utils.BadRequest("No "+per+" size is known for this product"))
+		default:
+			utils.Error(w, utils.BadRequest("per must be one of 100g, serving, package"))
+		}
+		return
+	}
+
+	per100 := models.Nutrition{}
+	if product.Nutrition != nil {
+		per100 = *product.Nutrition
+	}
+	view := per100.Scale(grams / 100)
+
+	categories := append([]string{product.Category}, product.Categories...)
+	nutriScore, _ := scoring.NutriScore(product.Nutrition, categories)
+
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"macros": map[string]interface{}{
+			"calories_kcal": view.EnergyKcal,
+			"protein_g":     view.Proteins,
+			"carbs_g":       view.Carbohydrates,
+			"fat_g":         view.Fat,
+			"per":           per,
+		},
+		"nutrition":       view,
+		"basis_g":         grams,
+		"available_views": views,
+		"nutri_score":     nutriScore,
+	})
+}
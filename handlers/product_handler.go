@@ -2,45 +2,49 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"backend/db"
+	"backend/importer"
 	"backend/models"
+	"backend/repository"
 	"backend/utils"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func GetProducts(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// GetProducts returns a bare product array; paging details travel in the
+// X-Total-Count and X-Next-Cursor headers
+func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
+	req, ok := parseList(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := db.DB.Collection("products").Find(ctx, bson.M{})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	page, err := h.Products.List(ctx, req.query)
+	if listFailed(w, err, "Failed to fetch products") {
 		return
 	}
 
-	var products []models.Product
-	cursor.All(ctx, &products)
-
-	utils.JSON(w, http.StatusOK, products)
+	utils.JSON(w, http.StatusOK, listItems(w, page, req.fields))
 }
 
-func GetProductByBarcode(w http.ResponseWriter, r *http.Request) {
-	barcode := r.URL.Query().Get("barcode")
-
-	var product models.Product
-	err := db.DB.Collection("products").
-		FindOne(context.Background(), bson.M{"barcode": barcode}).
-		Decode(&product)
+func (h *Handler) GetProductByBarcode(w http.ResponseWriter, r *http.Request) {
+	barcode, ok := parseBarcode(w, r.URL.Query().Get("barcode"))
+	if !ok {
+		return
+	}
 
+	product, err := h.findProduct(r.Context(), barcode)
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.NotFound("Product not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "Product not found", http.StatusNotFound)
+		utils.Error(w, utils.Internal("Failed to fetch product", err))
 		return
 	}
 
@@ -48,200 +52,190 @@ func GetProductByBarcode(w http.ResponseWriter, r *http.Request) {
 }
 
 // API-compatible handlers expected by the frontend
-func GetProductsAPI(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (h *Handler) GetProductsAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := parseList(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := db.DB.Collection("products").Find(ctx, bson.M{})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	page, err := h.Products.List(ctx, req.query)
+	if listFailed(w, err, "Failed to fetch products") {
 		return
 	}
 
-	var products []models.Product
-	cursor.All(ctx, &products)
-
 	// Wrap response to match frontend `{ success, products }`
 	resp := map[string]interface{}{
-		"success":  true,
-		"products": products,
+		"success":     true,
+		"products":    listItems(w, page, req.fields),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
 	}
 	utils.JSON(w, http.StatusOK, resp)
 }
 
-// ProductAPIHandler handles dynamic product subpaths:
-// /api/product/<barcode>
-// /api/product/<barcode>/macros
-// /api/product/<barcode>/recommendations
-// /api/product/<barcode>/recipes
-func ProductAPIHandler(w http.ResponseWriter, r *http.Request) {
-	prefix := "/api/product/"
-	path := ""
-	if len(r.URL.Path) > len(prefix) {
-		path = r.URL.Path[len(prefix):]
-	}
-	// path may be "<barcode>" or "<barcode>/macros" etc
-	parts := strings.SplitN(path, "/", 2)
-	barcode := parts[0]
-	sub := ""
-	if len(parts) > 1 {
-		sub = parts[1]
-	}
-
-	// find product in DB (if exists)
-	var productMap bson.M
-	_ = db.DB.Collection("products").FindOne(context.Background(), bson.M{"barcode": barcode}).Decode(&productMap)
-
-	switch sub {
-	case "macros":
-		// try to extract macros from stored raw_data or fields
-		// default to zeros
-		macros := map[string]interface{}{"calories_kcal": 0, "protein_g": 0, "carbs_g": 0, "fat_g": 0, "per": "100g"}
-		if raw, ok := productMap["raw_data"].(string); ok && raw != "" {
-			var rawObj map[string]interface{}
-			if err := json.Unmarshal([]byte(raw), &rawObj); err == nil {
-				if nutr, ok := rawObj["nutriments"].(map[string]interface{}); ok {
-					if v, ok := nutr["energy-kcal_100g"].(float64); ok {
-						macros["calories_kcal"] = v
-					}
-					if v, ok := nutr["proteins_100g"].(float64); ok {
-						macros["protein_g"] = v
-					}
-					if v, ok := nutr["carbohydrates_100g"].(float64); ok {
-						macros["carbs_g"] = v
-					}
-					if v, ok := nutr["fat_100g"].(float64); ok {
-						macros["fat_g"] = v
-					}
-				}
-			}
-		}
-		utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "macros": macros})
-		return
-	case "recommendations":
-		// simple DB-based recommendations: products with higher ecoScore
-		var recommendations []bson.M
-		score := 0
-		if v, ok := productMap["ecoScore"].(float64); ok {
-			score = int(v)
-		}
-		if v, ok := productMap["EcoScore"].(float64); ok {
-			score = int(v)
-		}
-		cursor, err := db.DB.Collection("products").Find(context.Background(), bson.M{"ecoScore": bson.M{"$gt": score}}, options.Find().SetLimit(6))
-		if err == nil {
-			cursor.All(context.Background(), &recommendations)
-		}
-		// map to frontend expectation
-		dbProds := make([]map[string]interface{}, 0)
-		for _, r := range recommendations {
-			dbProds = append(dbProds, map[string]interface{}{
-				"name":        r["name"],
-				"brand":       r["brand"],
-				"barcode":     r["barcode"],
-				"green_score": r["ecoScore"],
-			})
-		}
-		resp := map[string]interface{}{"success": true, "recommendations": map[string]interface{}{"database_products": dbProds, "ai_suggestions": []interface{}{}, "current_score": score, "improvement_tips": []string{}}}
-		utils.JSON(w, http.StatusOK, resp)
-		return
-	case "recipes":
-		// Simple recipe generator using product name and raw_data ingredients
-		recipes := []map[string]interface{}{}
-		prodName := "Product"
-		if n, ok := productMap["name"].(string); ok && n != "" {
-			prodName = n
-		} else if n, ok := productMap["product_name"].(string); ok && n != "" {
-			prodName = n
+// SearchProducts handles GET /api/products/search?q=&brand=&category=&label=&eco=&sort=&limit=&cursor=
+func (h *Handler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := repository.ProductQuery{
+		Text:     strings.TrimSpace(params.Get("q")),
+		Brand:    params.Get("brand"),
+		Category: params.Get("category"),
+		Label:    params.Get("label"),
+		EcoBand:  params.Get("eco"),
+		Sort:     params.Get("sort"),
+		Cursor:   params.Get("cursor"),
+		Limit:    20,
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			utils.Error(w, utils.BadRequest("limit must be between 1 and 100"))
+			return
 		}
+		q.Limit = n
+	}
+	if q.EcoBand != "" && !repository.IsEcoBand(q.EcoBand) {
+		utils.Error(w, utils.BadRequest("eco must be one of a, b, c, d, e"))
+		return
+	}
+	switch q.Sort {
+	case "", "relevance", "ecoScore", "-ecoScore", "name", "-name":
+	default:
+		utils.Error(w, utils.BadRequest("sort must be one of relevance, ecoScore, -ecoScore, name, -name"))
+		return
+	}
 
-		// try to extract ingredients list from raw_data
-		ingredients := []string{}
-		if raw, ok := productMap["raw_data"].(string); ok && raw != "" {
-			var rawObj map[string]interface{}
-			if err := json.Unmarshal([]byte(raw), &rawObj); err == nil {
-				if ingr, ok := rawObj["ingredients_text"].(string); ok && ingr != "" {
-					// split on commas for a simple list
-					parts := strings.Split(ingr, ",")
-					for _, p := range parts {
-						s := strings.TrimSpace(p)
-						if s != "" {
-							ingredients = append(ingredients, s)
-						}
-					}
-				}
-			}
-		}
+	res, err := h.Products.Search(r.Context(), q)
+	if listFailed(w, err, "Search failed") {
+		return
+	}
+
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"products":    res.Products,
+		"total":       res.Total,
+		"next_cursor": res.NextCursor,
+		"facets":      res.Facets,
+	})
+}
 
-		// Build two simple recipes
-		recipes = append(recipes, map[string]interface{}{
-			"title":        prodName + " Quick Stir-Fry",
-			"time_minutes": 20,
-			"ingredients": func() []string {
-				base := []string{prodName, "olive oil", "salt", "pepper", "garlic"}
-				if len(ingredients) > 0 {
-					base = append(base, ingredients[:min(len(ingredients), 3)]...)
-				}
-				return base
-			}(),
-			"steps": []string{
-				"Heat oil in a pan.",
-				"Add " + prodName + " and stir-fry for 5-8 minutes.",
-				"Season with salt and pepper, add garlic and cook 1 more minute.",
-				"Serve hot over rice or noodles.",
-			},
-		})
-
-		recipes = append(recipes, map[string]interface{}{
-			"title":        "Roasted " + prodName + " with Herbs",
-			"time_minutes": 35,
-			"ingredients": func() []string {
-				base := []string{prodName, "olive oil", "rosemary", "thyme", "lemon"}
-				if len(ingredients) > 0 {
-					base = append(base, ingredients[:min(len(ingredients), 2)]...)
-				}
-				return base
-			}(),
-			"steps": []string{
-				"Preheat oven to 200°C (400°F).",
-				"Toss " + prodName + " with oil, herbs, salt, and lemon.",
-				"Roast for 20-30 minutes until golden.",
-				"Serve warm with a side salad.",
-			},
-		})
-
-		utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "recipes": recipes})
+// productFromPath loads the product named by the {barcode} path value, backfilled
+// from its raw data. Unknown products come back empty so each endpoint decides
+// how to report them; an invalid barcode writes a 400 and a failed lookup a 500.
+func (h *Handler) productFromPath(w http.ResponseWriter, r *http.Request) (*models.Product, bool) {
+	barcode, ok := parseBarcode(w, r.PathValue("barcode"))
+	if !ok {
+		return nil, false
+	}
+	product, err := h.findProduct(r.Context(), barcode)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.Internal("Failed to fetch product", err))
+		return nil, false
+	}
+	if product == nil {
+		product = &models.Product{}
+	}
+	importer.Backfill(product)
+	return product, true
+}
+
+// GetProductAPI handles GET /api/product/{barcode}
+func (h *Handler) GetProductAPI(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.productFromPath(w, r)
+	if !ok {
 		return
-	default:
-		// return product
-		if productMap == nil {
-			utils.JSON(w, http.StatusOK, map[string]interface{}{"success": false})
-			return
-		}
-		utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "product": productMap})
+	}
+	if product.Barcode == "" {
+		utils.Error(w, utils.NotFound("Product not found"))
+		return
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "product": product})
+}
+
+// GetProductMacros handles GET /api/product/{barcode}/macros
+func (h *Handler) GetProductMacros(w http.ResponseWriter, r *http.Request) {
+	if product, ok := h.productFromPath(w, r); ok {
+		h.productMacros(w, r, product)
+	}
+}
+
+// GetProductRecipes handles GET /api/product/{barcode}/recipes
+func (h *Handler) GetProductRecipes(w http.ResponseWriter, r *http.Request) {
+	if product, ok := h.productFromPath(w, r); ok {
+		h.productRecipes(w, r, product)
+	}
+}
+
+// GetProductAllergens handles GET /api/product/{barcode}/allergens
+func (h *Handler) GetProductAllergens(w http.ResponseWriter, r *http.Request) {
+	if product, ok := h.productFromPath(w, r); ok {
+		h.productAllergens(w, r, product)
+	}
+}
+
+// GetProductRecommendations handles GET /api/product/{barcode}/recommendations
+func (h *Handler) GetProductRecommendations(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.productFromPath(w, r)
+	if !ok {
 		return
 	}
+	if product.Barcode == "" {
+		utils.Error(w, utils.NotFound("Product not found"))
+		return
+	}
+	res, err := h.recommend.Recommend(r.Context(), *product, 6)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to build recommendations", err))
+		return
+	}
+	// swap tips suggest another kind of product, the rest improve on this one
+	suggestions, tips := []string{}, []string{}
+	for _, t := range res.Tips {
+		if t.Swap {
+			suggestions = append(suggestions, t.Message)
+		} else {
+			tips = append(tips, t.Message)
+		}
+	}
+	var current interface{} // null for an unscored product
+	if product.HasEcoScore() {
+		current = product.EcoScore
+	}
+	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true, "recommendations": map[string]interface{}{
+		"database_products": res.Alternatives,
+		"ai_suggestions":    suggestions,
+		"current_score":     current,
+		"improvement_tips":  tips,
+		"category":          res.Category,
+		"tips":              res.Tips,
+	}})
 }
 
 // Add or update a product in the products collection
-func AddProductAPI(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) AddProductAPI(w http.ResponseWriter, r *http.Request) {
 	var p models.Product
-	err := json.NewDecoder(r.Body).Decode(&p)
-	if err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	if !decodeBody(w, r, &p) {
 		return
 	}
 
-	// Try to update by barcode, otherwise insert
-	filter := bson.M{"barcode": p.Barcode}
-	update := bson.M{"$set": p}
-	opts := options.Update().SetUpsert(true)
+	gtin, ok := parseBarcode(w, p.Barcode)
+	if !ok {
+		return
+	}
+	p.Barcode = gtin
+	// derive the typed nutrition from raw nutrients so the Nutri-Score can be computed
+	importer.Backfill(&p)
 
-	_, err = db.DB.Collection("products").UpdateOne(context.Background(), filter, update, opts)
-	if err != nil {
-		http.Error(w, "Failed to save product", http.StatusInternalServerError)
+	// Try to update by barcode, otherwise insert
+	if err := h.Products.Upsert(r.Context(), p); err != nil {
+		utils.Error(w, utils.Internal("Failed to save product", err))
 		return
 	}
+	if h.productCache != nil {
+		h.productCache.Invalidate(p.Barcode)
+	}
 
 	utils.JSON(w, http.StatusOK, map[string]interface{}{"success": true})
 }
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/allergens"
+	"backend/models"
+	"backend/repository"
+	basketsvc "backend/services/basket"
+	"backend/services/recipes"
+	"backend/utils"
+)
+
+const (
+	defaultRecipeLimit = 10
+	maxRecipeLimit     = 50
+)
+
+// parseRecipeQuery reads ?diet= (comma separated or repeated) and ?limit=, writing a 400 on bad values
+func parseRecipeQuery(w http.ResponseWriter, r *http.Request) (recipes.Query, bool) {
+	q := recipes.Query{Limit: defaultRecipeLimit}
+	for _, v := range r.URL.Query()["diet"] {
+		for _, d := range strings.Split(v, ",") {
+			if strings.TrimSpace(d) == "" {
+				continue
+			}
+			diet, ok := recipes.NormalizeDiet(d)
+			if !ok {
+				utils.Error(w, utils.BadRequest("diet must be one of "+strings.Join(allergens.Diets, ", ")))
+				return q, false
+			}
+			q.Diets = append(q.Diets, diet)
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRecipeLimit {
+			utils.Error(w, utils.BadRequest("limit must be between 1 and "+strconv.Itoa(maxRecipeLimit)))
+			return q, false
+		}
+		q.Limit = n
+	}
+	return q, true
+}
+
+func writeRecipes(w http.ResponseWriter, products []models.Product, q recipes.Query) {
+	utils.JSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"recipes": recipes.Default().Match(products, q),
+	})
+}
+
+// productRecipes handles GET /api/product/{barcode}/recipes?diet=vegan
+func (h *Handler) productRecipes(w http.ResponseWriter, r *http.Request, product *models.Product) {
+	if product.Barcode == "" {
+		utils.Error(w, utils.NotFound("Product not found"))
+		return
+	}
+	q, ok := parseRecipeQuery(w, r)
+	if !ok {
+		return
+	}
+	writeRecipes(w, []models.Product{*product}, q)
+}
+
+// GetRecipes handles GET /api/recipes?barcodes=a,b&diet=gluten-free. Unknown barcodes are ignored.
+func (h *Handler) GetRecipes(w http.ResponseWriter, r *http.Request) {
+	var codes []string
+	for _, v := range r.URL.Query()["barcodes"] {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				codes = append(codes, c)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		utils.Error(w, utils.BadRequest("barcodes is required"))
+		return
+	}
+	lines := basketsvc.LinesFromBarcodes(codes)
+	if !normalizeLines(w, lines) {
+		return
+	}
+	q, ok := parseRecipeQuery(w, r)
+	if !ok {
+		return
+	}
+
+	barcodes := make([]string, len(lines))
+	for i, l := range lines {
+		barcodes[i] = l.Barcode
+	}
+	products, err := h.productsFor(r, barcodes)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch products", err))
+		return
+	}
+	writeRecipes(w, products, q)
+}
+
+// GetBasketRecipes handles GET /api/basket/{id}/recipes for the current user's saved baskets
+func (h *Handler) GetBasketRecipes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	b, err := h.Baskets.FindByID(r.Context(), userID, r.PathValue("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.Error(w, utils.NotFound("Basket not found"))
+		return
+	}
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch basket", err))
+		return
+	}
+	q, ok := parseRecipeQuery(w, r)
+	if !ok {
+		return
+	}
+	products, err := h.productsFor(r, b.Barcodes)
+	if err != nil {
+		utils.Error(w, utils.Internal("Failed to fetch products", err))
+		return
+	}
+	writeRecipes(w, products, q)
+}
+
+// productsFor resolves barcodes in order, skipping unknown ones and duplicates
+func (h *Handler) productsFor(r *http.Request, barcodes []string) ([]models.Product, error) {
+	found, err := h.Products.FindByBarcodes(r.Context(), barcodes)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.Product{}
+	seen := map[string]bool{}
+	for _, code := range barcodes {
+		if p, ok := found[code]; ok && !seen[code] {
+			seen[code] = true
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
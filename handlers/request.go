@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"backend/utils"
+	"backend/validate"
+)
+
+// decodeJSON reads one JSON value of at most maxBytes from the request body
+// into dst and validates it, see package validate. Unknown fields, like rule
+// violations and values of the wrong type, fail with validate.Errors; a body
+// that is not JSON is utils.InvalidBody and an oversized one a 413.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err, maxBytes)
+	}
+	// anything after the value, even another valid one, is malformed
+	if _, err := dec.Token(); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err, maxBytes)
+		}
+		return utils.InvalidBody()
+	}
+	return validate.Struct(dst)
+}
+
+func decodeError(err error, maxBytes int64) error {
+	var (
+		tooLarge *http.MaxBytesError
+		typeErr  *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return utils.NewError(http.StatusRequestEntityTooLarge, utils.CodeBodyTooLarge, "Body must be at most "+strconv.FormatInt(maxBytes, 10)+" bytes")
+	case errors.As(err, &typeErr):
+		return validate.Errors{{Field: fieldPath(typeErr.Field), Rule: "type", Message: "must be " + jsonKind(typeErr.Type)}}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, _ = strconv.Unquote(field)
+		return validate.Errors{{Field: field, Rule: "unknown", Message: "is not a known field"}}
+	}
+	return utils.InvalidBody()
+}
+
+// fieldPath writes encoding/json's "items.0.quantity" the way package validate
+// reports fields, "items[0].quantity"
+func fieldPath(field string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		switch _, err := strconv.Atoi(part); {
+		case err == nil:
+			b.WriteString("[" + part + "]")
+		case i > 0:
+			b.WriteString("." + part)
+		default:
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// jsonKind names the JSON type a Go type decodes from
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"backend/auth"
+	"backend/handlers"
+	"backend/models"
+	"backend/repository"
+	"backend/routes"
+)
+
+var errStoreDown = errors.New("connection refused")
+
+// wrap adds context to a store error the way a real store might, so handlers
+// have to look through it for the sentinel
+func wrap(store string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", store, err)
+}
+
+// wrappingUsers wraps every lookup error, and fails lookups while down is set
+type wrappingUsers struct {
+	repository.UserRepo
+	down *atomic.Bool
+}
+
+func (u wrappingUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	if u.down.Load() {
+		return nil, errStoreDown
+	}
+	user, err := u.UserRepo.FindByEmail(ctx, email)
+	return user, wrap("users", err)
+}
+
+func (u wrappingUsers) FindByID(ctx context.Context, id string) (*models.User, error) {
+	if u.down.Load() {
+		return nil, errStoreDown
+	}
+	user, err := u.UserRepo.FindByID(ctx, id)
+	return user, wrap("users", err)
+}
+
+func (u wrappingUsers) SetRole(ctx context.Context, id, role string) error {
+	return wrap("users", u.UserRepo.SetRole(ctx, id, role))
+}
+
+type wrappingGoals struct{ repository.GoalRepo }
+
+func (g wrappingGoals) FindByID(ctx context.Context, userID, id string) (*models.Goal, error) {
+	goal, err := g.GoalRepo.FindByID(ctx, userID, id)
+	return goal, wrap("goals", err)
+}
+
+func (g wrappingGoals) Delete(ctx context.Context, userID, id string) error {
+	return wrap("goals", g.GoalRepo.Delete(ctx, userID, id))
+}
+
+type wrappingProducts struct{ repository.ProductRepo }
+
+func (p wrappingProducts) FindByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	product, err := p.ProductRepo.FindByBarcode(ctx, barcode)
+	return product, wrap("products", err)
+}
+
+func (p wrappingProducts) Search(ctx context.Context, q repository.ProductQuery) (repository.SearchResult, error) {
+	res, err := p.ProductRepo.Search(ctx, q)
+	return res, wrap("products", err)
+}
+
+// TestStoreErrors checks that wrapped sentinel errors keep their status and
+// that a failing store is a 500, not a 404 or 401
+func TestStoreErrors(t *testing.T) {
+	repos := repository.NewMemory()
+	down := &atomic.Bool{}
+	repos.Users = wrappingUsers{repos.Users, down}
+	repos.Goals = wrappingGoals{repos.Goals}
+	repos.Products = wrappingProducts{repos.Products}
+	h := handlers.New(repos)
+	srv := httptest.NewServer(routes.RegisterRoutes(h))
+	t.Cleanup(srv.Close)
+
+	anon := client{srv: srv}
+	user := signup(t, srv, "wrapped@example.com")
+	admin := signup(t, srv, adminEmail)
+	missing := "64b000000000000000000099"
+
+	anon.do(t, "GET", "/product/barcode?barcode="+gtin(7), nil).wantError(t, http.StatusNotFound, "not_found")
+	anon.do(t, "GET", "/api/product/"+gtin(7)+"/recommendations", nil).wantError(t, http.StatusNotFound, "not_found")
+	anon.do(t, "GET", "/api/products/search?cursor=garbage", nil).wantError(t, http.StatusBadRequest, "bad_request")
+	user.do(t, "GET", "/api/goals/"+missing, nil).wantError(t, http.StatusNotFound, "not_found")
+	user.do(t, "DELETE", "/api/goals/"+missing, nil).wantError(t, http.StatusNotFound, "not_found")
+	admin.do(t, "POST", "/api/admin/users/role", map[string]string{"user_id": missing, "role": "moderator"}).
+		wantError(t, http.StatusNotFound, "not_found")
+
+	login := func(email, password string) response {
+		return anon.do(t, "POST", "/api/auth/login", map[string]string{"email": email, "password": password})
+	}
+	login("nobody@example.com", "correct horse").wantError(t, http.StatusUnauthorized, "unauthorized")
+	login("wrapped@example.com", "wrong horse").wantError(t, http.StatusUnauthorized, "unauthorized")
+	login("wrapped@example.com", "correct horse").want(t, http.StatusOK)
+
+	// GetMe is called directly, as the middleware's own role lookup would fail first
+	me := func(userID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/auth/me", nil)
+		h.GetMe(rec, req.WithContext(auth.WithUserID(req.Context(), userID)))
+		return rec
+	}
+	if rec := me(missing); rec.Code != http.StatusNotFound {
+		t.Errorf("GetMe for an unknown user = %d, want 404", rec.Code)
+	}
+
+	down.Store(true)
+	t.Cleanup(func() { down.Store(false) })
+	login("wrapped@example.com", "correct horse").wantError(t, http.StatusInternalServerError, "internal_error")
+	if rec := me(missing); rec.Code != http.StatusInternalServerError {
+		t.Errorf("GetMe with the user store down = %d, want 500: %s", rec.Code, rec.Body)
+	}
+}
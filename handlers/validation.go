@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"backend/allergens"
+	"backend/config"
+	"backend/models"
+	goalsvc "backend/services/goals"
+	"backend/utils"
+	"backend/validate"
+)
+
+// requests are the bodies decodeBody validates. Their tags are checked at
+// startup, so a typo fails every test rather than the first real request.
+var requests = []interface{}{
+	credentials{}, loginRequest{}, roleRequest{},
+	basketRequest{}, models.BasketItem{}, quantityRequest{},
+	goalRequest{}, models.DietaryProfile{}, models.Product{},
+}
+
+// enums referenced by `validate:"enum=..."` tags on request structs
+func init() {
+	validate.RegisterEnum("goal_type", goalsvc.Types, func(t string) bool {
+		_, ok := goalsvc.NormalizeType(t)
+		return ok
+	})
+	validate.RegisterEnum("goal_window", goalsvc.Windows, func(win string) bool {
+		_, ok := goalsvc.NormalizeWindow(win)
+		return ok
+	})
+	validate.RegisterEnum("diet", allergens.Diets, func(d string) bool {
+		return allergens.ValidDiet(strings.ToLower(strings.TrimSpace(d)))
+	})
+	ids := make([]string, len(allergens.EU14))
+	for i, a := range allergens.EU14 {
+		ids[i] = a.ID
+	}
+	validate.RegisterEnum("allergen", ids, func(a string) bool {
+		return allergens.ValidAllergen(strings.ToLower(strings.TrimSpace(a)))
+	})
+	for _, req := range requests {
+		if err := validate.Check(req); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// decodeBody decodes and validates the JSON body into dst, see decodeJSON,
+// writing a 400, 413 or 422 and returning false when it is not acceptable
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := decodeJSON(w, r, dst, config.MaxBodyBytes); err != nil {
+		utils.Error(w, apiError(err))
+		return false
+	}
+	return true
+}
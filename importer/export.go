@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"backend/models"
+)
+
+// ToOFF converts a catalog product back into an Open Food Facts style product object,
+// the inverse of Normalize for the fields we keep
+func ToOFF(p models.Product) map[string]interface{} {
+	off := map[string]interface{}{
+		"code":         p.Barcode,
+		"product_name": p.Name,
+	}
+	set := func(key string, v interface{}, empty bool) {
+		if !empty {
+			off[key] = v
+		}
+	}
+	set("generic_name", p.Description, p.Description == "")
+	set("brands", p.Brand, p.Brand == "")
+	set("image_url", p.ImageURL, p.ImageURL == "")
+	set("quantity", p.Quantity, p.Quantity == "")
+	set("product_quantity", strconv.FormatFloat(p.NetWeightG, 'f', -1, 64), p.NetWeightG == 0)
+	set("categories_tags", addTags(p.Categories), len(p.Categories) == 0)
+	set("ingredients_text", p.IngredientsText, p.IngredientsText == "")
+	set("packaging_tags", addTags(p.Packaging), len(p.Packaging) == 0)
+	set("origins_tags", addTags(p.Origins), len(p.Origins) == 0)
+	set("labels_tags", addTags(p.Labels), len(p.Labels) == 0)
+	set("ecoscore_grade", p.EcoscoreGrade, p.EcoscoreGrade == "")
+	set("ecoscore_score", p.EcoScore, !p.HasEcoScore())
+	set("nutriments", p.Nutrients, len(p.Nutrients) == 0)
+	if n := p.Nutrition; n != nil {
+		if len(p.Nutrients) == 0 {
+			set("nutriments", nutrimentsOf(n), false)
+		}
+		set("serving_size", n.ServingSize, n.ServingSize == "")
+		set("serving_quantity", strconv.FormatFloat(n.ServingG, 'f', -1, 64), n.ServingG == 0)
+	}
+
+	if len(p.Categories) == 0 && p.Category != "" {
+		off["categories_tags"] = addTags([]string{p.Category})
+	}
+	return off
+}
+
+// MarshalOFF encodes ToOFF(p) as a single JSON line
+func MarshalOFF(p models.Product) ([]byte, error) {
+	return json.Marshal(ToOFF(p))
+}
+
+func addTags(values []string) []string {
+	tags := make([]string, len(values))
+	for i, v := range values {
+		tags[i] = "en:" + v
+	}
+	return tags
+}
@@ -0,0 +1,213 @@
+// Package importer fills the product catalog from Open Food Facts style data,
+// either an HTTP API or a local JSONL dump, when a barcode is not in `products` yet.
+package importer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+)
+
+// Defaults for the limits of an Importer made by New
+const (
+	DefaultBatchLimit       = 20
+	DefaultBatchConcurrency = 4
+	DefaultBatchTimeout     = 3 * time.Second
+	DefaultMissTTL          = time.Hour
+	DefaultFailureTTL       = time.Minute
+)
+
+// maxMisses bounds the remembered misses; expired ones are dropped first
+const maxMisses = 10000
+
+// Importer resolves barcodes through the catalog first and the external source second.
+// A barcode the source does not know, or failed to fetch, is not asked for again
+// until MissTTL, or FailureTTL, has passed.
+type Importer struct {
+	Source   Source
+	Products repository.ProductRepo
+
+	// FindByBarcodes imports at most BatchLimit barcodes per call, BatchConcurrency
+	// at a time, and gives up on those still running after BatchTimeout
+	BatchLimit       int
+	BatchConcurrency int
+	BatchTimeout     time.Duration
+
+	MissTTL    time.Duration
+	FailureTTL time.Duration
+
+	mu     sync.Mutex
+	misses map[string]time.Time // barcode -> when the source may be asked again
+}
+
+func New(source Source, products repository.ProductRepo) *Importer {
+	return &Importer{
+		Source:           source,
+		Products:         products,
+		BatchLimit:       DefaultBatchLimit,
+		BatchConcurrency: DefaultBatchConcurrency,
+		BatchTimeout:     DefaultBatchTimeout,
+		MissTTL:          DefaultMissTTL,
+		FailureTTL:       DefaultFailureTTL,
+	}
+}
+
+// Import fetches a barcode from the source, normalizes it and upserts it into the catalog
+func (im *Importer) Import(ctx context.Context, barcode string) (*models.Product, error) {
+	raw, err := im.Source.Fetch(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Normalize(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.Barcode = barcode
+	p.CreatedAt = time.Now()
+	if err := im.Products.Upsert(ctx, p); err != nil {
+		return nil, err
+	}
+	return im.Products.FindByBarcode(ctx, barcode)
+}
+
+// Lookup returns the catalog product, importing it when missing.
+// It returns repository.ErrNotFound when neither the catalog nor the source knows the barcode.
+func (im *Importer) Lookup(ctx context.Context, barcode string) (*models.Product, error) {
+	p, err := im.Products.FindByBarcode(ctx, barcode)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return p, err
+	}
+	p, err = im.importOnce(ctx, barcode)
+	if errors.Is(err, ErrNotFound) {
+		return nil, repository.ErrNotFound
+	}
+	return p, err
+}
+
+// importOnce is Import for barcodes that missed recently: those fail with
+// ErrNotFound without asking the source, and new misses are remembered
+func (im *Importer) importOnce(ctx context.Context, barcode string) (*models.Product, error) {
+	if im.missed(barcode) {
+		return nil, ErrNotFound
+	}
+	p, err := im.Import(ctx, barcode)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		im.remember(barcode, im.MissTTL)
+	case err != nil && ctx.Err() == nil:
+		// a failure of the source, not a request that was cancelled or ran out of time
+		im.remember(barcode, im.FailureTTL)
+	}
+	return p, err
+}
+
+func (im *Importer) missed(barcode string) bool {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	until, ok := im.misses[barcode]
+	if ok && time.Now().After(until) {
+		delete(im.misses, barcode)
+		return false
+	}
+	return ok
+}
+
+func (im *Importer) remember(barcode string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.misses == nil {
+		im.misses = map[string]time.Time{}
+	}
+	if len(im.misses) >= maxMisses {
+		now := time.Now()
+		for code, until := range im.misses {
+			if now.After(until) {
+				delete(im.misses, code)
+			}
+		}
+		if len(im.misses) >= maxMisses {
+			im.misses = map[string]time.Time{}
+		}
+	}
+	im.misses[barcode] = time.Now().Add(ttl)
+}
+
+// FindByBarcodes lets an Importer stand in as a basket ProductStore: the catalog is
+// queried in one batch and only the missing barcodes go to the source, within
+// the batch limits. Barcodes over the limit, recently missed, failing or not
+// imported in time are left unknown rather than failing the basket.
+func (im *Importer) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	found, err := im.Products.FindByBarcodes(ctx, barcodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	seen := map[string]bool{}
+	for _, code := range barcodes {
+		if _, ok := found[code]; ok || seen[code] || im.missed(code) {
+			continue
+		}
+		seen[code] = true
+		missing = append(missing, code)
+	}
+	if im.BatchLimit > 0 && len(missing) > im.BatchLimit {
+		missing = missing[:im.BatchLimit]
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	if im.BatchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, im.BatchTimeout)
+		defer cancel()
+	}
+	workers := im.BatchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = make(chan string)
+	)
+	for i := 0; i < workers && i < len(missing); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for code := range codes {
+				p, err := im.importOnce(ctx, code)
+				if err != nil {
+					if !errors.Is(err, ErrNotFound) && ctx.Err() == nil {
+						log.Println("importer:", code+":", err)
+					}
+					continue
+				}
+				mu.Lock()
+				found[code] = p
+				mu.Unlock()
+			}
+		}()
+	}
+send:
+	for _, code := range missing {
+		select {
+		case codes <- code:
+		case <-ctx.Done():
+			break send // out of time; the rest stay unknown
+		}
+	}
+	close(codes)
+	wg.Wait()
+	return found, nil
+}
@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/barcode"
+	"backend/models"
+	"backend/repository"
+)
+
+// fakeSource knows the products in known, fails for the barcodes in failing and
+// counts what it is asked for. Each fetch takes delay.
+type fakeSource struct {
+	known   map[string]string // barcode -> product name
+	failing map[string]bool
+	delay   time.Duration
+
+	mu       sync.Mutex
+	fetches  map[string]int
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, barcode string) ([]byte, error) {
+	s.mu.Lock()
+	if s.fetches == nil {
+		s.fetches = map[string]int{}
+	}
+	s.fetches[barcode]++
+	s.mu.Unlock()
+
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		seen := s.maxSeen.Load()
+		if n <= seen || s.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.failing[barcode] {
+		return nil, errors.New("source unavailable")
+	}
+	name, ok := s.known[barcode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []byte(`{"code": "` + barcode + `", "product_name": "` + name + `"}`), nil
+}
+
+func (s *fakeSource) count(barcode string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches[barcode]
+}
+
+func TestLookup(t *testing.T) {
+	src := &fakeSource{known: map[string]string{"00000000000017": "Imported"}}
+	products := repository.NewMemoryProducts(models.Product{Barcode: "00000000000024", Name: "Cataloged"})
+	im := New(src, products)
+	ctx := context.Background()
+
+	p, err := im.Lookup(ctx, "00000000000024")
+	if err != nil || p.Name != "Cataloged" || src.count("00000000000024") != 0 {
+		t.Errorf("Lookup(cataloged) = %+v, %v after %d fetches", p, err, src.count("00000000000024"))
+	}
+
+	p, err = im.Lookup(ctx, "00000000000017")
+	if err != nil || p.Name != "Imported" {
+		t.Fatalf("Lookup(importable) = %+v, %v", p, err)
+	}
+	if stored, err := products.FindByBarcode(ctx, "00000000000017"); err != nil || stored.Name != "Imported" {
+		t.Errorf("import was not stored: %+v, %v", stored, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := im.Lookup(ctx, "00000000000031"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Lookup(unknown) = %v, want repository.ErrNotFound", err)
+		}
+	}
+	if n := src.count("00000000000031"); n != 1 {
+		t.Errorf("unknown barcode fetched %d times, want 1", n)
+	}
+}
+
+func TestFindByBarcodes(t *testing.T) {
+	known := map[string]string{}
+	var codes []string
+	for i := 0; i < 30; i++ {
+		body := strconv.Itoa(4000000000000 + i)
+		code := body + string(barcode.CheckDigit(body))
+		codes = append(codes, code)
+		known[code] = "Product " + code
+	}
+	ctx := context.Background()
+
+	t.Run("bounded batch", func(t *testing.T) {
+		src := &fakeSource{known: known, delay: 5 * time.Millisecond}
+		im := New(src, repository.NewMemoryProducts())
+		im.BatchLimit, im.BatchConcurrency = 10, 3
+
+		found, err := im.FindByBarcodes(ctx, append(codes, codes[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 10 {
+			t.Errorf("imported %d products, want the batch limit of 10", len(found))
+		}
+		if max := src.maxSeen.Load(); max > 3 {
+			t.Errorf("%d fetches ran at once, want at most 3", max)
+		}
+		if src.count(codes[0]) != 1 {
+			t.Errorf("duplicate barcode fetched %d times", src.count(codes[0]))
+		}
+
+		// the imported products now come from the catalog
+		found, _ = im.FindByBarcodes(ctx, codes[:10])
+		if len(found) != 10 || src.count(codes[0]) != 1 {
+			t.Errorf("second batch found %d, fetched %d times", len(found), src.count(codes[0]))
+		}
+	})
+
+	t.Run("misses and failures are remembered", func(t *testing.T) {
+		src := &fakeSource{known: known, failing: map[string]bool{codes[1]: true}}
+		im := New(src, repository.NewMemoryProducts())
+		batch := []string{"00000000000093", codes[1], codes[2]}
+
+		for i := 0; i < 3; i++ {
+			found, err := im.FindByBarcodes(ctx, batch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != 1 || found[codes[2]] == nil {
+				t.Errorf("round %d found %v", i, found)
+			}
+		}
+		if n := src.count("00000000000093"); n != 1 {
+			t.Errorf("unknown barcode fetched %d times, want 1", n)
+		}
+		if n := src.count(codes[1]); n != 1 {
+			t.Errorf("failing barcode fetched %d times, want 1", n)
+		}
+
+		// a failure is retried once FailureTTL has passed
+		im.FailureTTL = time.Millisecond
+		im.remember(codes[1], im.FailureTTL)
+		time.Sleep(5 * time.Millisecond)
+		im.FindByBarcodes(ctx, batch)
+		if n := src.count(codes[1]); n != 2 {
+			t.Errorf("failing barcode fetched %d times after FailureTTL, want 2", n)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		src := &fakeSource{known: known, delay: time.Second}
+		im := New(src, repository.NewMemoryProducts())
+		im.BatchTimeout = 20 * time.Millisecond
+
+		start := time.Now()
+		found, err := im.FindByBarcodes(ctx, codes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("batch took %v past a 20ms deadline", elapsed)
+		}
+		if len(found) != 0 {
+			t.Errorf("found %d products from a source slower than the deadline", len(found))
+		}
+		// running out of time is not the source's fault, so nothing is remembered
+		if im.missed(codes[0]) {
+			t.Error("timed out barcode remembered as a miss")
+		}
+	})
+}
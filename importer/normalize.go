@@ -0,0 +1,217 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"backend/barcode"
+	"backend/models"
+)
+
+// offProduct is the subset of an Open Food Facts product object we read
+type offProduct struct {
+	Code            string                     `json:"code"`
+	ProductName     string                     `json:"product_name"`
+	GenericName     string                     `json:"generic_name"`
+	Brands          string                     `json:"brands"`
+	ImageURL        string                     `json:"image_url"`
+	Quantity        string                     `json:"quantity"`
+	ProductQuantity json.RawMessage            `json:"product_quantity"` // number or numeric string, grams
+	CategoriesTags  []string                   `json:"categories_tags"`
+	IngredientsText string                     `json:"ingredients_text"`
+	IngredientsList []struct{ Text string }    `json:"ingredients"` // parsed ingredient objects, only "text" is used
+	PackagingTags   []string                   `json:"packaging_tags"`
+	Packaging       string                     `json:"packaging"`
+	OriginsTags     []string                   `json:"origins_tags"`
+	Origins         string                     `json:"origins"`
+	LabelsTags      []string                   `json:"labels_tags"`
+	EcoscoreGrade   string                     `json:"ecoscore_grade"`
+	EcoscoreScore   *float64                   `json:"ecoscore_score"`
+	Nutriments      map[string]json.RawMessage `json:"nutriments"`
+	ServingSize     string                     `json:"serving_size"`
+	ServingQuantity json.RawMessage            `json:"serving_quantity"` // number or numeric string, grams
+}
+
+// Normalize turns one Open Food Facts product JSON object into a models.Product.
+// The original JSON is kept in RawData.
+func Normalize(raw []byte) (models.Product, error) {
+	var off offProduct
+	if err := json.Unmarshal(raw, &off); err != nil {
+		return models.Product{}, err
+	}
+	if strings.TrimSpace(off.Code) == "" {
+		return models.Product{}, errors.New("importer: product has no code")
+	}
+	code, err := barcode.GTIN(off.Code)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	p := models.Product{
+		Barcode:         code,
+		Name:            strings.TrimSpace(off.ProductName),
+		Description:     strings.TrimSpace(off.GenericName),
+		Brand:           firstOf(splitList(off.Brands)),
+		ImageURL:        off.ImageURL,
+		Quantity:        strings.TrimSpace(off.Quantity),
+		NetWeightG:      parseNumber(off.ProductQuantity),
+		Categories:      stripTags(off.CategoriesTags),
+		IngredientsText: strings.TrimSpace(off.IngredientsText),
+		Packaging:       stripTags(off.PackagingTags),
+		Origins:         stripTags(off.OriginsTags),
+		Labels:          stripTags(off.LabelsTags),
+		EcoscoreGrade:   strings.ToLower(off.EcoscoreGrade),
+		Nutrients:       numericNutriments(off.Nutriments),
+		RawData:         string(raw),
+		Source:          "openfoodfacts",
+	}
+
+	for _, ing := range off.IngredientsList {
+		if t := strings.TrimSpace(ing.Text); t != "" {
+			p.Ingredients = append(p.Ingredients, t)
+		}
+	}
+	if len(p.Ingredients) == 0 {
+		p.Ingredients = splitList(p.IngredientsText)
+	}
+	if len(p.Packaging) == 0 {
+		p.Packaging = splitList(off.Packaging)
+	}
+	if len(p.Origins) == 0 {
+		p.Origins = splitList(off.Origins)
+	}
+	if n := len(p.Categories); n > 0 {
+		p.Category = p.Categories[n-1]
+	}
+	if off.EcoscoreScore != nil {
+		p.EcoScore = int(*off.EcoscoreScore)
+	}
+	p.Nutrition = NutritionFrom(p.Nutrients, off.ServingSize, parseNumber(off.ServingQuantity))
+	return p, nil
+}
+
+// Backfill fills empty structured fields of a product from its RawData,
+// for catalog entries saved before normalization existed
+func Backfill(p *models.Product) {
+	if p.Nutrition == nil && len(p.Nutrients) > 0 {
+		p.Nutrition = NutritionFrom(p.Nutrients, "", 0)
+	}
+	if p.RawData == "" || p.Nutrients != nil || p.IngredientsText != "" {
+		return
+	}
+	var withCode map[string]json.RawMessage
+	if json.Unmarshal([]byte(p.RawData), &withCode) != nil {
+		return
+	}
+	if _, ok := withCode["code"]; !ok {
+		withCode["code"], _ = json.Marshal(p.Barcode)
+	}
+	raw, _ := json.Marshal(withCode)
+	n, err := Normalize(raw)
+	if err != nil {
+		return
+	}
+
+	if len(p.Categories) == 0 {
+		p.Categories = n.Categories
+	}
+	if p.Category == "" {
+		p.Category = n.Category
+	}
+	if p.Quantity == "" {
+		p.Quantity = n.Quantity
+	}
+	if p.NetWeightG == 0 {
+		p.NetWeightG = n.NetWeightG
+	}
+	p.IngredientsText = n.IngredientsText
+	p.Ingredients = n.Ingredients
+	if len(p.Packaging) == 0 {
+		p.Packaging = n.Packaging
+	}
+	if len(p.Origins) == 0 {
+		p.Origins = n.Origins
+	}
+	if len(p.Labels) == 0 {
+		p.Labels = n.Labels
+	}
+	if p.EcoscoreGrade == "" {
+		p.EcoscoreGrade = n.EcoscoreGrade
+	}
+	p.Nutrients = n.Nutrients
+	if p.Nutrition == nil {
+		p.Nutrition = n.Nutrition
+	}
+}
+
+// numericNutriments keeps the nutriment values that are numbers (OFF mixes in unit strings)
+func numericNutriments(in map[string]json.RawMessage) map[string]float64 {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]float64, len(in))
+	for k, v := range in {
+		if strings.HasSuffix(k, "_unit") {
+			continue
+		}
+		if f, ok := parseNumberOK(v); ok {
+			out[k] = f
+		}
+	}
+	return out
+}
+
+func parseNumber(raw json.RawMessage) float64 {
+	f, _ := parseNumberOK(raw)
+	return f
+}
+
+func parseNumberOK(raw json.RawMessage) (float64, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var f float64
+	if json.Unmarshal(raw, &f) == nil {
+		return f, true
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if f, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// stripTags removes the "en:" style language prefix from taxonomy tags
+func stripTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		if i := strings.Index(t, ":"); i == 2 {
+			t = t[i+1:]
+		}
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func firstOf(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0]
+}
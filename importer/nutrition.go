@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"strings"
+
+	"backend/models"
+	"backend/scoring"
+)
+
+// kcalToKJ converts kilocalories to kilojoules
+const kcalToKJ = 4.184
+
+// saltPerSodium is the conversion used on EU labels: salt = sodium × 2.5
+const saltPerSodium = 2.5
+
+// nutritionKeys maps Open Food Facts per-100g nutriment keys to Nutrition fields
+var nutritionKeys = []struct {
+	key   string
+	field func(n *models.Nutrition) **float64
+}{
+	{"energy-kj_100g", func(n *models.Nutrition) **float64 { return &n.EnergyKJ }},
+	{"energy-kcal_100g", func(n *models.Nutrition) **float64 { return &n.EnergyKcal }},
+	{"fat_100g", func(n *models.Nutrition) **float64 { return &n.Fat }},
+	{"saturated-fat_100g", func(n *models.Nutrition) **float64 { return &n.SaturatedFat }},
+	{"carbohydrates_100g", func(n *models.Nutrition) **float64 { return &n.Carbohydrates }},
+	{"sugars_100g", func(n *models.Nutrition) **float64 { return &n.Sugars }},
+	{"fiber_100g", func(n *models.Nutrition) **float64 { return &n.Fiber }},
+	{"proteins_100g", func(n *models.Nutrition) **float64 { return &n.Proteins }},
+	{"salt_100g", func(n *models.Nutrition) **float64 { return &n.Salt }},
+	{"sodium_100g", func(n *models.Nutrition) **float64 { return &n.Sodium }},
+	{"fruits-vegetables-nuts_100g", func(n *models.Nutrition) **float64 { return &n.FruitsVegNuts }},
+	{"fruits-vegetables-nuts-estimate-from-ingredients_100g", func(n *models.Nutrition) **float64 { return &n.FruitsVegNuts }},
+}
+
+// micronutrients are the vitamins and minerals kept in Nutrition.Micronutrients
+var micronutrients = []string{
+	"vitamin-a", "vitamin-d", "vitamin-e", "vitamin-k", "vitamin-c", "vitamin-b1", "vitamin-b2",
+	"vitamin-pp", "vitamin-b6", "vitamin-b9", "vitamin-b12", "biotin", "pantothenic-acid",
+	"potassium", "calcium", "phosphorus", "iron", "magnesium", "zinc", "copper", "manganese",
+	"selenium", "iodine", "chloride", "fluoride",
+}
+
+// NutritionFrom builds typed nutrition facts from OFF nutriment keys. Energy in
+// kJ/kcal and salt/sodium are derived from each other when only one is given.
+// It returns nil when there is nothing to report.
+func NutritionFrom(nutrients map[string]float64, servingSize string, servingG float64) *models.Nutrition {
+	n := models.Nutrition{ServingSize: strings.TrimSpace(servingSize), ServingG: servingG}
+	known := false
+	for _, k := range nutritionKeys {
+		if v, ok := nutrients[k.key]; ok {
+			if f := k.field(&n); *f == nil {
+				*f = &v
+				known = true
+			}
+		}
+	}
+	for _, name := range micronutrients {
+		if v, ok := nutrients[name+"_100g"]; ok {
+			if n.Micronutrients == nil {
+				n.Micronutrients = map[string]float64{}
+			}
+			n.Micronutrients[name] = v
+			known = true
+		}
+	}
+
+	derive := func(dst **float64, src *float64, factor float64) {
+		if *dst == nil && src != nil {
+			v := *src * factor
+			*dst = &v
+		}
+	}
+	derive(&n.EnergyKJ, n.EnergyKcal, kcalToKJ)
+	derive(&n.EnergyKcal, n.EnergyKJ, 1/kcalToKJ)
+	derive(&n.Salt, n.Sodium, saltPerSodium)
+	derive(&n.Sodium, n.Salt, 1/saltPerSodium)
+
+	if n.ServingG == 0 && n.ServingSize != "" {
+		n.ServingG = scoring.ParseWeightKg(n.ServingSize) * 1000
+	}
+	if !known && n.ServingG == 0 {
+		return nil
+	}
+	return &n
+}
+
+// nutrimentsOf is the inverse of NutritionFrom, used when exporting
+func nutrimentsOf(n *models.Nutrition) map[string]float64 {
+	out := map[string]float64{}
+	if n == nil {
+		return out
+	}
+	for _, k := range nutritionKeys {
+		if v := *k.field(n); v != nil && !strings.Contains(k.key, "estimate") {
+			out[k.key] = *v
+		}
+	}
+	for name, v := range n.Micronutrients {
+		out[name+"_100g"] = v
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gtin "backend/barcode"
+)
+
+// ErrNotFound is returned by a Source that has no data for a barcode
+var ErrNotFound = errors.New("importer: product not found in source")
+
+// Source returns the raw Open Food Facts product JSON for a barcode
+type Source interface {
+	Fetch(ctx context.Context, barcode string) ([]byte, error)
+}
+
+// HTTPSource reads products from an Open Food Facts compatible API:
+// GET {BaseURL}/api/v2/product/{barcode}.json -> {"status": 1, "product": {...}}
+// Barcodes are requested in their compact EAN-8/EAN-13 form.
+type HTTPSource struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+}
+
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		UserAgent: "GreenLabelAI-Backend/1.0",
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context, barcode string) ([]byte, error) {
+	u := s.BaseURL + "/api/v2/product/" + url.PathEscape(gtin.Compact(barcode)) + ".json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.UserAgent)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("importer: %s returned %s", u, resp.Status)
+	}
+
+	var body struct {
+		Status  int             `json:"status"`
+		Code    string          `json:"code"`
+		Product json.RawMessage `json:"product"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Status != 1 || len(body.Product) == 0 {
+		return nil, ErrNotFound
+	}
+	return withCode(body.Product, barcode)
+}
+
+// DumpSource serves products from a local JSONL dump (one OFF product object per line).
+// The file is indexed by GTIN-14 on first use; lines are read back on demand.
+type DumpSource struct {
+	Path string
+
+	once    sync.Once
+	initErr error
+	offsets map[string]int64
+}
+
+func NewDumpSource(path string) *DumpSource {
+	return &DumpSource{Path: path}
+}
+
+func (s *DumpSource) Fetch(ctx context.Context, barcode string) ([]byte, error) {
+	s.once.Do(func() { s.initErr = s.index() })
+	if s.initErr != nil {
+		return nil, s.initErr
+	}
+	off, ok := s.offsets[barcode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return line, nil
+}
+
+// Each calls fn with every product line in the dump, in file order
+func (s *DumpSource) Each(fn func(raw []byte) error) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			if ferr := fn(line); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *DumpSource) index() error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s.offsets = map[string]int64{}
+	r := bufio.NewReaderSize(f, 1<<20)
+	var pos int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var head struct {
+				Code string `json:"code"`
+			}
+			if json.Unmarshal(line, &head) == nil && head.Code != "" {
+				code, err := gtin.GTIN(head.Code)
+				if err != nil {
+					code = head.Code
+				}
+				if _, dup := s.offsets[code]; !dup {
+					s.offsets[code] = pos
+				}
+			}
+			pos += int64(len(line))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// withCode makes sure the product object carries its barcode, which the v2 API puts one level up
+func withCode(product json.RawMessage, barcode string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(product, &obj); err != nil {
+		return nil, err
+	}
+	if _, ok := obj["code"]; ok {
+		return product, nil
+	}
+	obj["code"], _ = json.Marshal(barcode)
+	return json.Marshal(obj)
+}
@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const fixture = "fixtures/off_sample.jsonl"
+
+// offStub serves the Open Food Facts v2 product API from a map of barcode to response
+func offStub(t *testing.T, responses map[string]func(w http.ResponseWriter)) *HTTPSource {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			t.Errorf("%s sent without a User-Agent", r.URL.Path)
+		}
+		respond, ok := responses[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		respond(w)
+	}))
+	t.Cleanup(srv.Close)
+	return NewHTTPSource(srv.URL + "/")
+}
+
+func reply(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	src := offStub(t, map[string]func(w http.ResponseWriter){
+		"/api/v2/product/3017620422003.json": reply(http.StatusOK, `{"status": 1, "code": "3017620422003", "product": {"product_name": "Nutella"}}`),
+		"/api/v2/product/5449000000996.json": reply(http.StatusNotFound, `{"status": 0}`),
+		"/api/v2/product/3033490004743.json": reply(http.StatusOK, `{"status": 0, "status_verbose": "product not found"}`),
+		"/api/v2/product/40000000.json":      reply(http.StatusServiceUnavailable, `busy`),
+		"/api/v2/product/4000000000017.json": reply(http.StatusOK, `{"status": 1, "product": `),
+	})
+
+	tests := []struct {
+		name     string
+		barcode  string
+		wantName string
+		wantErr  error // nil with wantName "" means any other error
+	}{
+		{"found, compact barcode requested", "03017620422003", "Nutella", nil},
+		{"404", "05449000000996", "", ErrNotFound},
+		{"status 0", "03033490004743", "", ErrNotFound},
+		{"server error", "00000040000000", "", nil},
+		{"truncated body", "04000000000017", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := src.Fetch(context.Background(), tt.barcode)
+			if tt.wantName == "" {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) || (tt.wantErr == nil && errors.Is(err, ErrNotFound)) {
+					t.Fatalf("Fetch = %s, %v; want error %v", raw, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var product struct {
+				Code string `json:"code"`
+				Name string `json:"product_name"`
+			}
+			if err := json.Unmarshal(raw, &product); err != nil {
+				t.Fatal(err)
+			}
+			// the v2 API keeps the code outside the product; Fetch puts it back
+			if product.Name != tt.wantName || product.Code != tt.barcode {
+				t.Errorf("Fetch = %s", raw)
+			}
+		})
+	}
+}
+
+func TestDumpSource(t *testing.T) {
+	src := NewDumpSource(fixture)
+	tests := []struct {
+		barcode  string
+		wantName string
+	}{
+		{"03017620422003", "Nutella"},
+		{"05449000000996", "Coca-Cola"},
+		{"03033490004743", "Yaourt nature bio"},
+		{"3017620422003", ""}, // lines are indexed by GTIN-14
+		{"04000000000017", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.barcode, func(t *testing.T) {
+			raw, err := src.Fetch(context.Background(), tt.barcode)
+			if tt.wantName == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("Fetch = %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := Normalize(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", p.Name, tt.wantName)
+			}
+		})
+	}
+
+	lines := 0
+	if err := src.Each(func(raw []byte) error { lines++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if lines != 3 {
+		t.Errorf("Each visited %d lines, want 3", lines)
+	}
+
+	if _, err := NewDumpSource("fixtures/missing.jsonl").Fetch(context.Background(), "03017620422003"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch from a missing dump = %v, want the open error", err)
+	}
+}
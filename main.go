@@ -5,16 +5,59 @@ import (
 	"net/http"
 	"os"
 
+	"backend/auth"
 	"backend/config"
 	"backend/db"
+	"backend/handlers"
+	"backend/repository"
 	"backend/routes"
+	"backend/scoring"
+	"backend/services/badges"
+	"backend/services/recipes"
 )
 
 func main() {
 	config.LoadEnv()
+	if err := config.CheckJWTSecret(); err != nil {
+		log.Fatal("JWT secret error: ", err)
+	}
+	if config.DevMode && config.JWTSecret == config.DevJWTSecret {
+		log.Println("DEV_MODE: signing tokens with the public development secret")
+	}
 	db.ConnectMongo()
 
-	router := routes.RegisterRoutes()
+	if config.JWKSFile != "" {
+		if err := auth.LoadJWKS(config.JWKSFile); err != nil {
+			log.Fatal("JWKS load error:", err)
+		}
+	}
+
+	if config.EmissionFactorsFile != "" {
+		table, err := scoring.LoadFactorTable(config.EmissionFactorsFile)
+		if err != nil {
+			log.Fatal("Emission factors load error:", err)
+		}
+		scoring.SetDefault(scoring.Chain{scoring.NewFactorModel(table), scoring.Linear{}})
+	}
+
+	if config.RecipesFile != "" {
+		corpus, err := recipes.Load(config.RecipesFile)
+		if err != nil {
+			log.Fatal("Recipes load error:", err)
+		}
+		recipes.SetDefault(corpus)
+	}
+
+	if config.BadgesFile != "" {
+		catalog, err := badges.Load(config.BadgesFile)
+		if err != nil {
+			log.Fatal("Badge catalog load error:", err)
+		}
+		badges.SetDefault(catalog)
+	}
+
+	h := handlers.New(repository.NewMongo(db.DB))
+	router := routes.RegisterRoutes(h)
 
 	port := os.Getenv("PORT")
 	if port == "" {
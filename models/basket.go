@@ -1,6 +1,51 @@
 package models
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BasketItem is one product of a basket; a quantity of 0 in a request counts as 1
 type BasketItem struct {
-	ProductID string `json:"productId"`
-	Quantity  int    `json:"quantity"`
+	ProductID string `bson:"product_id" json:"productId" validate:"required,maxlen=32"`
+	Quantity  int    `bson:"quantity" json:"quantity" validate:"min=0,max=999"`
+}
+
+// ActiveBasket is the basket a user or anonymous session is still filling, one
+// document per owner in `active_baskets`. Checkout turns it into a Basket.
+type ActiveBasket struct {
+	Owner     string       `bson:"_id" json:"-"`
+	Items     []BasketItem `bson:"items" json:"items"`
+	UpdatedAt time.Time    `bson:"updated_at" json:"updated_at,omitzero"`
+}
+
+// BasketLineReport is one analyzed line of a basket, including which carbon model priced it
+type BasketLineReport struct {
+	Barcode        string  `json:"barcode" bson:"barcode"`
+	ProductName    string  `json:"product_name" bson:"product_name"`
+	Quantity       int     `json:"quantity" bson:"quantity"`
+	Known          bool    `json:"known" bson:"known"`
+	Carbon         float64 `json:"carbon" bson:"carbon"`
+	HealthScore    int     `json:"health_score" bson:"health_score"`
+	Unscored       bool    `json:"unscored,omitempty" bson:"unscored,omitempty"` // no Eco-Score: left out of avg_health_score
+	CarbonModel    string  `json:"carbon_model,omitempty" bson:"carbon_model,omitempty"`
+	CarbonFactor   float64 `json:"carbon_factor,omitempty" bson:"carbon_factor,omitempty"`
+	FactorUnit     string  `json:"factor_unit,omitempty" bson:"factor_unit,omitempty"`
+	FactorCategory string  `json:"factor_category,omitempty" bson:"factor_category,omitempty"`
+}
+
+// Basket is a saved, analyzed basket in the `baskets` collection
+type Basket struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          string             `bson:"user_id" json:"-"`
+	Barcodes        []string           `bson:"barcodes" json:"barcodes"`
+	Items           []BasketLineReport `bson:"items" json:"items"`
+	TotalItems      int                `bson:"total_items" json:"total_items"`
+	TotalCarbon     float64            `bson:"total_carbon" json:"total_carbon"`
+	AvgHealthScore  int                `bson:"avg_health_score" json:"avg_health_score"`
+	UnknownBarcodes []string           `bson:"unknown_barcodes" json:"unknown_barcodes"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	// IdempotencyKey is the client's Idempotency-Key for the save, unique per user
+	IdempotencyKey string `bson:"idempotency_key,omitempty" json:"-"`
 }
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Goal statuses; goals saved before statuses existed have none and count as active
+const (
+	GoalActive    = "active"
+	GoalCompleted = "completed"
+)
+
+// Goal is a target on one of the metrics of package services/goals, measured
+// over Window. Progress is recomputed from the user's baskets and scans.
+type Goal struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      string             `bson:"user_id" json:"-"`
+	Type        string             `bson:"type" json:"type"`
+	Description string             `bson:"description" json:"description"`
+	TargetValue float64            `bson:"target_value" json:"target_value"`
+	Progress    float64            `bson:"progress" json:"progress"`
+	Window      string             `bson:"window,omitempty" json:"window"`
+	Status      string             `bson:"status,omitempty" json:"status"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Active reports whether the goal still tracks progress
+func (g Goal) Active() bool { return g.Status != GoalCompleted }
@@ -3,6 +3,7 @@ package models
 import "time"
 
 type ScanHistory struct {
+	UserID  string    `bson:"user_id" json:"-"`
 	Barcode string    `bson:"barcode" json:"barcode"`
 	Time    time.Time `bson:"time" json:"time"`
 }
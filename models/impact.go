@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Impact holds a user's running totals; the document _id is the user id
+type Impact struct {
+	UserID           string    `bson:"_id" json:"-"`
+	TotalCarbonSaved float64   `bson:"total_carbon_saved" json:"total_carbon_saved"`
+	TotalBaskets     int       `bson:"total_baskets" json:"total_baskets"`
+	TotalScore       float64   `bson:"total_score" json:"total_score"`
+	CreatedAt        time.Time `bson:"created_at,omitempty" json:"created_at"`
+}
+
+// Badge is a badge as earned; the rules for earning it live in package services/badges
+type Badge struct {
+	ID          int    `bson:"id" json:"id"`
+	Name        string `bson:"name" json:"name"`
+	Description string `bson:"description" json:"description"`
+	Icon        string `bson:"icon,omitempty" json:"icon,omitempty"`
+}
+
+// UserBadge records a badge earned by a user in `user_badges`
+type UserBadge struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+	UserID   string             `bson:"user_id" json:"-"`
+	BadgeID  int                `bson:"badge_id" json:"badge_id"`
+	Badge    Badge              `bson:"badge" json:"badge"`
+	EarnedAt time.Time          `bson:"earned_at" json:"earned_at"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "math"
+
+// Nutrition is a product's nutrition facts per 100 g (or 100 ml). A nil value
+// means the label does not say, which is not the same as zero.
+type Nutrition struct {
+	EnergyKJ      *float64 `bson:"energy_kj,omitempty" json:"energy_kj"`
+	EnergyKcal    *float64 `bson:"energy_kcal,omitempty" json:"energy_kcal"`
+	Fat           *float64 `bson:"fat_g,omitempty" json:"fat_g"`
+	SaturatedFat  *float64 `bson:"saturated_fat_g,omitempty" json:"saturated_fat_g"`
+	Carbohydrates *float64 `bson:"carbohydrates_g,omitempty" json:"carbohydrates_g"`
+	Sugars        *float64 `bson:"sugars_g,omitempty" json:"sugars_g"`
+	Fiber         *float64 `bson:"fiber_g,omitempty" json:"fiber_g"`
+	Proteins      *float64 `bson:"proteins_g,omitempty" json:"proteins_g"`
+	Salt          *float64 `bson:"salt_g,omitempty" json:"salt_g"`
+	Sodium        *float64 `bson:"sodium_g,omitempty" json:"sodium_g"`
+
+	// FruitsVegNuts is the share of fruit, vegetables, legumes and nuts in percent; it does not scale with portion
+	FruitsVegNuts *float64 `bson:"fruits_vegetables_nuts_pct,omitempty" json:"fruits_vegetables_nuts_pct"`
+	// Micronutrients are in grams, keyed by Open Food Facts name, e.g. "vitamin-c", "calcium"
+	Micronutrients map[string]float64 `bson:"micronutrients,omitempty" json:"micronutrients,omitempty"`
+
+	ServingSize string  `bson:"serving_size,omitempty" json:"serving_size,omitempty"` // label text, e.g. "15 g"
+	ServingG    float64 `bson:"serving_g,omitempty" json:"serving_g,omitempty"`       // grams, 0 when unknown
+}
+
+// Scale returns the values for factor × 100 g, e.g. 0.3 for a 30 g serving.
+// Results are rounded to 0.001; unknown values stay unknown and FruitsVegNuts is unchanged.
+func (n Nutrition) Scale(factor float64) Nutrition {
+	scale := func(v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		s := math.Round(*v*factor*1000) / 1000
+		return &s
+	}
+	out := n
+	out.EnergyKJ = scale(n.EnergyKJ)
+	out.EnergyKcal = scale(n.EnergyKcal)
+	out.Fat = scale(n.Fat)
+	out.SaturatedFat = scale(n.SaturatedFat)
+	out.Carbohydrates = scale(n.Carbohydrates)
+	out.Sugars = scale(n.Sugars)
+	out.Fiber = scale(n.Fiber)
+	out.Proteins = scale(n.Proteins)
+	out.Salt = scale(n.Salt)
+	out.Sodium = scale(n.Sodium)
+	if n.Micronutrients != nil {
+		out.Micronutrients = make(map[string]float64, len(n.Micronutrients))
+		for k, v := range n.Micronutrients {
+			out.Micronutrients[k] = math.Round(v*factor*1e6) / 1e6
+		}
+	}
+	return out
+}
@@ -8,12 +8,45 @@ import (
 
 type Product struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string             `bson:"name" json:"name"`
-	Barcode     string             `bson:"barcode" json:"barcode"`
-	EcoScore    int                `bson:"ecoScore" json:"ecoScore"`
-	Description string             `bson:"description" json:"description"`
-	ImageURL    string             `bson:"image_url,omitempty" json:"image_url"`
-	Brand       string             `bson:"brand,omitempty" json:"brand"`
+	Name        string             `bson:"name" json:"name" validate:"maxlen=200"`
+	Barcode     string             `bson:"barcode" json:"barcode" validate:"required,maxlen=32"`
+	EcoScore    int                `bson:"ecoScore" json:"ecoScore" validate:"min=0,max=100"`
+	Description string             `bson:"description" json:"description" validate:"maxlen=5000"`
+	ImageURL    string             `bson:"image_url,omitempty" json:"image_url" validate:"maxlen=2048"`
+	Brand       string             `bson:"brand,omitempty" json:"brand" validate:"maxlen=200"`
+	Category    string             `bson:"category,omitempty" json:"category,omitempty" validate:"maxlen=200"`
+	Quantity    string             `bson:"quantity,omitempty" json:"quantity,omitempty" validate:"maxlen=64"`     // label text, e.g. "500 g"
+	NetWeightG  float64            `bson:"net_weight_g,omitempty" json:"net_weight_g,omitempty" validate:"min=0"` // grams, overrides Quantity
+	Price       float64            `bson:"price,omitempty" json:"price,omitempty" validate:"min=0"`               // shelf price per package, 0 when unknown
 	RawData     string             `bson:"raw_data,omitempty" json:"raw_data"`
 	CreatedAt   time.Time          `bson:"created_at,omitempty" json:"created_at"`
+
+	// Structured fields normalized from Open Food Facts style data (see package importer)
+	Categories      []string           `bson:"categories,omitempty" json:"categories,omitempty" validate:"maxitems=50"` // general to specific
+	IngredientsText string             `bson:"ingredients_text,omitempty" json:"ingredients_text,omitempty" validate:"maxlen=20000"`
+	Ingredients     []string           `bson:"ingredients,omitempty" json:"ingredients,omitempty" validate:"maxitems=500"`
+	Packaging       []string           `bson:"packaging,omitempty" json:"packaging,omitempty" validate:"maxitems=100"`
+	Origins         []string           `bson:"origins,omitempty" json:"origins,omitempty" validate:"maxitems=100"`
+	Labels          []string           `bson:"labels,omitempty" json:"labels,omitempty" validate:"maxitems=100"`
+	EcoscoreGrade   string             `bson:"ecoscore_grade,omitempty" json:"ecoscore_grade,omitempty"`
+	Nutrients       map[string]float64 `bson:"nutrients,omitempty" json:"nutrients,omitempty" validate:"maxitems=300"` // OFF nutriment keys, e.g. "proteins_100g"
+	Nutrition       *Nutrition         `bson:"nutrition,omitempty" json:"nutrition,omitempty"`
+	Source          string             `bson:"source,omitempty" json:"source,omitempty"`
+
+	// Computed by package scoring on every upsert
+	NutriScore        *NutriScore        `bson:"nutri_score,omitempty" json:"nutri_score,omitempty"`
+	EcoScoreBreakdown *EcoScoreBreakdown `bson:"eco_score_breakdown,omitempty" json:"eco_score_breakdown,omitempty"`
+}
+
+// HasEcoScore reports whether the product carries an Eco-Score. A product whose
+// category has no emission factor and that came without a score is unscored: its
+// ecoScore of 0 means "unknown", not "worst".
+func (p Product) HasEcoScore() bool {
+	return p.EcoScore > 0 || IsEcoGrade(p.EcoscoreGrade)
+}
+
+// IsEcoGrade reports whether g is an Eco-Score letter, "a" to "e". Open Food Facts
+// also sends "unknown" and "not-applicable".
+func IsEcoGrade(g string) bool {
+	return len(g) == 1 && g >= "a" && g <= "e"
 }
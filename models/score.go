@@ -0,0 +1,35 @@
+package models
+
+// NutriScore is a Nutri-Score grade with the points behind it
+type NutriScore struct {
+	Grade      string           `bson:"grade,omitempty" json:"grade,omitempty"` // "a".."e", empty when it cannot be computed
+	Score      int              `bson:"score" json:"score"`
+	Profile    string           `bson:"profile" json:"profile"` // "general", "beverage", "water", "cheese" or "fat"
+	Negative   int              `bson:"negative_points" json:"negative_points"`
+	Positive   int              `bson:"positive_points" json:"positive_points"`
+	Components []ScoreComponent `bson:"components" json:"components"`
+	Missing    []string         `bson:"missing,omitempty" json:"missing,omitempty"` // required nutrients the label lacks
+}
+
+// EcoScoreBreakdown is a computed Eco-Score: the category's life-cycle
+// baseline plus the bonus and malus components
+type EcoScoreBreakdown struct {
+	Grade      string           `bson:"grade,omitempty" json:"grade,omitempty"` // "a".."e", empty when it cannot be computed
+	Score      int              `bson:"score" json:"score"`
+	Baseline   int              `bson:"baseline" json:"baseline"`
+	Adjustment int              `bson:"adjustment" json:"adjustment"` // sum of the component points
+	Category   string           `bson:"category,omitempty" json:"category,omitempty"`
+	Factor     float64          `bson:"factor,omitempty" json:"factor,omitempty"` // kg CO2e per kg behind the baseline
+	Components []ScoreComponent `bson:"components" json:"components"`
+	Missing    []string         `bson:"missing,omitempty" json:"missing,omitempty"`
+}
+
+// ScoreComponent is one line of a score breakdown
+type ScoreComponent struct {
+	Name   string   `bson:"name" json:"name"`
+	Value  *float64 `bson:"value,omitempty" json:"value"` // nil when unknown
+	Unit   string   `bson:"unit,omitempty" json:"unit,omitempty"`
+	Points int      `bson:"points" json:"points"`
+	Max    int      `bson:"max,omitempty" json:"max,omitempty"`
+	Note   string   `bson:"note,omitempty" json:"note,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type User struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email        string             `bson:"email" json:"email"`
+	Name         string             `bson:"name,omitempty" json:"name"`
+	Role         string             `bson:"role" json:"role"`
+	PasswordHash string             `bson:"password_hash" json:"-"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+
+	DietaryProfile *DietaryProfile `bson:"dietary_profile,omitempty" json:"dietary_profile,omitempty"`
+}
+
+// DietaryProfile is what a user wants to be warned about, see package allergens
+type DietaryProfile struct {
+	Diets     []string `bson:"diets" json:"diets" validate:"maxitems=10,dive,enum=diet"`             // e.g. "vegan", "gluten-free"
+	Allergens []string `bson:"allergens" json:"allergens" validate:"maxitems=14,dive,enum=allergen"` // EU 14 ids, e.g. "peanuts"
+}
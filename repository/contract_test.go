@@ -0,0 +1,516 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/db"
+	"backend/models"
+	"backend/repository"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Every contract runs against each store. The Mongo store needs a server:
+//
+//	MONGO_TEST_URI=mongodb://127.0.0.1:27017 go test ./repository
+//
+// Each test gets its own database, dropped when it ends.
+func stores(t *testing.T) map[string]func(t *testing.T) *repository.Repos {
+	t.Helper()
+	stores := map[string]func(t *testing.T) *repository.Repos{
+		"memory": func(t *testing.T) *repository.Repos { return repository.NewMemory() },
+	}
+	if uri := os.Getenv("MONGO_TEST_URI"); uri != "" {
+		stores["mongo"] = func(t *testing.T) *repository.Repos { return mongoRepos(t, uri) }
+	}
+	return stores
+}
+
+var mongoDBs atomic.Int64
+
+func mongoRepos(t *testing.T, uri string) *repository.Repos {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "greenlabelai_test_" + strconv.FormatInt(time.Now().UnixNano(), 36) + "_" + strconv.FormatInt(mongoDBs.Add(1), 10)
+	database := client.Database(name)
+	db.EnsureIndexes(ctx, database)
+	t.Cleanup(func() {
+		_ = database.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	return repository.NewMongo(database)
+}
+
+// runContract runs test against a fresh instance of every store
+func runContract(t *testing.T, test func(t *testing.T, repos *repository.Repos)) {
+	for name, open := range stores(t) {
+		t.Run(name, func(t *testing.T) { test(t, open(t)) })
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProductsContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		products := repos.Products
+		must(t, products.Upsert(ctx, models.Product{Barcode: "00000000000001", Name: "Yogurt", Category: "yogurts"}))
+		must(t, products.Upsert(ctx, models.Product{Barcode: "00000000000002", Name: "Oats"}))
+		must(t, products.Upsert(ctx, models.Product{Barcode: "00000000000001", Name: "Greek yogurt", Category: "yogurts"}))
+
+		p, err := products.FindByBarcode(ctx, "00000000000001")
+		must(t, err)
+		if p.Name != "Greek yogurt" {
+			t.Errorf("upsert did not replace the product: %+v", p)
+		}
+		if _, err := products.FindByBarcode(ctx, "00000000000009"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByBarcode(missing) = %v, want ErrNotFound", err)
+		}
+
+		found, err := products.FindByBarcodes(ctx, []string{"00000000000001", "00000000000009", "00000000000002"})
+		must(t, err)
+		if len(found) != 2 || found["00000000000002"] == nil || found["00000000000009"] != nil {
+			t.Errorf("FindByBarcodes = %v", found)
+		}
+
+		page, err := products.List(ctx, repository.ListQuery{Sort: "name", Limit: 1})
+		must(t, err)
+		if page.Total != 2 || len(page.Items) != 1 || page.Items[0].Name != "Greek yogurt" || page.NextCursor == "" {
+			t.Errorf("first page = %+v", page)
+		}
+		page, err = products.List(ctx, repository.ListQuery{Sort: "name", Limit: 1, Cursor: page.NextCursor})
+		must(t, err)
+		if len(page.Items) != 1 || page.Items[0].Name != "Oats" || page.NextCursor != "" {
+			t.Errorf("second page = %+v", page)
+		}
+		if _, err := products.List(ctx, repository.ListQuery{Sort: "password"}); err == nil {
+			t.Error("List accepted an unsortable field")
+		}
+
+		if err := products.ChangeBarcode(ctx, "00000000000001", "00000000000002"); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("ChangeBarcode onto a taken barcode = %v, want ErrDuplicate", err)
+		}
+		if err := products.ChangeBarcode(ctx, "00000000000009", "00000000000008"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("ChangeBarcode(missing) = %v, want ErrNotFound", err)
+		}
+		must(t, products.ChangeBarcode(ctx, "00000000000001", "00000000000003"))
+		if _, err := products.FindByBarcode(ctx, "00000000000003"); err != nil {
+			t.Errorf("renamed product: %v", err)
+		}
+	})
+}
+
+func TestUsersContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		users := repos.Users
+		u := &models.User{Email: "jane@example.com", Role: "viewer", CreatedAt: time.Now()}
+		must(t, users.Create(ctx, u))
+		if u.ID.IsZero() {
+			t.Fatal("Create did not assign an id")
+		}
+		if err := users.Create(ctx, &models.User{Email: "jane@example.com"}); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("Create with a taken email = %v, want ErrDuplicate", err)
+		}
+
+		byEmail, err := users.FindByEmail(ctx, "jane@example.com")
+		must(t, err)
+		if byEmail.ID != u.ID {
+			t.Errorf("FindByEmail = %+v", byEmail)
+		}
+		must(t, users.SetRole(ctx, u.ID.Hex(), "admin"))
+		must(t, users.SetDietaryProfile(ctx, u.ID.Hex(), models.DietaryProfile{Allergens: []string{"milk"}}))
+		byID, err := users.FindByID(ctx, u.ID.Hex())
+		must(t, err)
+		if byID.Role != "admin" || byID.DietaryProfile == nil || len(byID.DietaryProfile.Allergens) != 1 {
+			t.Errorf("FindByID = %+v", byID)
+		}
+
+		for _, id := range []string{"not-an-id", "000000000000000000000000"} {
+			if _, err := users.FindByID(ctx, id); !errors.Is(err, repository.ErrNotFound) {
+				t.Errorf("FindByID(%q) = %v, want ErrNotFound", id, err)
+			}
+			if err := users.SetRole(ctx, id, "admin"); !errors.Is(err, repository.ErrNotFound) {
+				t.Errorf("SetRole(%q) = %v, want ErrNotFound", id, err)
+			}
+		}
+		if _, err := users.FindByEmail(ctx, "nobody@example.com"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByEmail(missing) = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestBasketsContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+		save := func(user string, carbon float64, items int, key string, at time.Time) *models.Basket {
+			b := &models.Basket{UserID: user, TotalCarbon: carbon, TotalItems: items, AvgHealthScore: 50, CreatedAt: at, IdempotencyKey: key}
+			_, err := repos.Saver.Save(ctx, b)
+			must(t, err)
+			return b
+		}
+		first := save("u1", 1.5, 2, "", start)
+		save("u1", 2.5, 3, "key-1", start.Add(time.Minute))
+		save("u2", 9, 1, "key-1", start) // keys are per user
+
+		dup := &models.Basket{UserID: "u1", TotalCarbon: 100, CreatedAt: start, IdempotencyKey: "key-1"}
+		if _, err := repos.Saver.Save(ctx, dup); !errors.Is(err, repository.ErrDuplicate) {
+			t.Errorf("Save with a used key = %v, want ErrDuplicate", err)
+		}
+		impact, err := repos.Impact.Get(ctx, "u1")
+		must(t, err)
+		if impact.TotalBaskets != 2 || impact.TotalCarbonSaved != 4 || impact.TotalScore != 100 {
+			t.Errorf("impact = %+v, want the two saves and not the duplicate", impact)
+		}
+
+		keyed, err := repos.Baskets.FindByIdempotencyKey(ctx, "u1", "key-1")
+		must(t, err)
+		if keyed.TotalCarbon != 2.5 {
+			t.Errorf("FindByIdempotencyKey = %+v", keyed)
+		}
+		if _, err := repos.Baskets.FindByIdempotencyKey(ctx, "u1", "key-2"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByIdempotencyKey(missing) = %v, want ErrNotFound", err)
+		}
+		if _, err := repos.Baskets.FindByID(ctx, "u2", first.ID.Hex()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByID of another user's basket = %v, want ErrNotFound", err)
+		}
+		if b, err := repos.Baskets.FindByID(ctx, "u1", first.ID.Hex()); err != nil || b.TotalCarbon != 1.5 {
+			t.Errorf("FindByID = %+v, %v", b, err)
+		}
+
+		page, err := repos.Baskets.ListByUser(ctx, "u1", repository.ListQuery{})
+		must(t, err)
+		if page.Total != 2 || page.Items[0].TotalCarbon != 2.5 {
+			t.Errorf("ListByUser = %+v, want most recent first", page)
+		}
+		stats, err := repos.Baskets.StatsSince(ctx, "u1", start.Add(time.Second))
+		must(t, err)
+		if stats.Baskets != 1 || stats.Items != 3 || stats.Carbon != 2.5 {
+			t.Errorf("StatsSince = %+v", stats)
+		}
+		sum, err := repos.Baskets.SumCarbonSince(ctx, "u1", start)
+		must(t, err)
+		if sum != 4 {
+			t.Errorf("SumCarbonSince = %v", sum)
+		}
+	})
+}
+
+func TestActiveBasketsContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		active := repos.Active
+		empty, err := active.Get(ctx, "session:a")
+		must(t, err)
+		if len(empty.Items) != 0 {
+			t.Errorf("missing basket = %+v, want empty", empty)
+		}
+
+		_, err = active.AddItem(ctx, "session:a", "p1", 1)
+		must(t, err)
+		_, err = active.AddItem(ctx, "session:a", "p2", 1)
+		must(t, err)
+		b, err := active.AddItem(ctx, "session:a", "p1", 2)
+		must(t, err)
+		if len(b.Items) != 2 || b.Items[0] != (models.BasketItem{ProductID: "p1", Quantity: 3}) {
+			t.Errorf("after adds = %+v", b.Items)
+		}
+
+		b, err = active.SetQuantity(ctx, "session:a", "p2", 5)
+		must(t, err)
+		if b.Items[1].Quantity != 5 {
+			t.Errorf("after SetQuantity = %+v", b.Items)
+		}
+		if _, err := active.SetQuantity(ctx, "session:a", "p9", 1); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("SetQuantity(missing) = %v, want ErrNotFound", err)
+		}
+		b, err = active.RemoveItem(ctx, "session:a", "p1")
+		must(t, err)
+		if len(b.Items) != 1 {
+			t.Errorf("after RemoveItem = %+v", b.Items)
+		}
+		if _, err := active.RemoveItem(ctx, "session:a", "p1"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("RemoveItem(missing) = %v, want ErrNotFound", err)
+		}
+
+		must(t, active.Clear(ctx, "session:a"))
+		must(t, active.Clear(ctx, "session:never-used"))
+		if b, _ := active.Get(ctx, "session:a"); len(b.Items) != 0 {
+			t.Errorf("after Clear = %+v", b.Items)
+		}
+
+		// Merge adds the session's lines to the user's and empties the session
+		_, err = active.AddItem(ctx, "user:u", "p1", 1)
+		must(t, err)
+		_, err = active.AddItem(ctx, "session:b", "p1", 2)
+		must(t, err)
+		_, err = active.AddItem(ctx, "session:b", "p2", 4)
+		must(t, err)
+		b, err = active.Merge(ctx, "session:b", "user:u")
+		must(t, err)
+		want := []models.BasketItem{{ProductID: "p1", Quantity: 3}, {ProductID: "p2", Quantity: 4}}
+		if !reflect.DeepEqual(b.Items, want) {
+			t.Errorf("after Merge = %+v, want %+v", b.Items, want)
+		}
+		if s, _ := active.Get(ctx, "session:b"); len(s.Items) != 0 {
+			t.Errorf("session after Merge = %+v", s.Items)
+		}
+		// merging again, or from a session that never had a basket, changes nothing
+		for _, from := range []string{"session:b", "session:never-used"} {
+			b, err = active.Merge(ctx, from, "user:u")
+			must(t, err)
+			if !reflect.DeepEqual(b.Items, want) {
+				t.Errorf("Merge from %s = %+v, want %+v", from, b.Items, want)
+			}
+		}
+		// into a user without a basket
+		_, err = active.AddItem(ctx, "session:c", "p3", 1)
+		must(t, err)
+		b, err = active.Merge(ctx, "session:c", "user:new")
+		must(t, err)
+		if len(b.Items) != 1 || b.Items[0] != (models.BasketItem{ProductID: "p3", Quantity: 1}) {
+			t.Errorf("Merge into a new basket = %+v", b.Items)
+		}
+	})
+}
+
+func TestHistoryContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+		for i := 0; i < 3; i++ {
+			must(t, repos.History.Add(ctx, models.ScanHistory{UserID: "u1", Barcode: "p" + strconv.Itoa(i), Time: start.Add(time.Duration(i) * time.Minute)}))
+		}
+		must(t, repos.History.Add(ctx, models.ScanHistory{UserID: "u2", Barcode: "p9", Time: start}))
+
+		page, err := repos.History.ListByUser(ctx, "u1", repository.ListQuery{Limit: 2})
+		must(t, err)
+		if page.Total != 3 || len(page.Items) != 2 || page.Items[0].Barcode != "p2" || page.NextCursor == "" {
+			t.Errorf("first page = %+v", page)
+		}
+		page, err = repos.History.ListByUser(ctx, "u1", repository.ListQuery{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)})
+		must(t, err)
+		if page.Total != 1 || page.Items[0].Barcode != "p1" {
+			t.Errorf("since/until page = %+v", page)
+		}
+		if _, err := repos.History.ListByUser(ctx, "u1", repository.ListQuery{Cursor: "garbage"}); !errors.Is(err, repository.ErrBadCursor) {
+			t.Errorf("bad cursor = %v, want ErrBadCursor", err)
+		}
+
+		n, err := repos.History.CountSince(ctx, "u1", start.Add(time.Minute))
+		must(t, err)
+		if n != 2 {
+			t.Errorf("CountSince = %d, want 2", n)
+		}
+		must(t, repos.History.ClearByUser(ctx, "u1"))
+		if n, _ := repos.History.CountSince(ctx, "u1", time.Time{}); n != 0 {
+			t.Errorf("%d scans left after ClearByUser", n)
+		}
+		if n, _ := repos.History.CountSince(ctx, "u2", time.Time{}); n != 1 {
+			t.Errorf("ClearByUser removed another user's scans")
+		}
+	})
+}
+
+func TestGoalsContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		goals := repos.Goals
+		g := &models.Goal{UserID: "u1", Type: "baskets", TargetValue: 3, Status: models.GoalActive, CreatedAt: time.Now()}
+		must(t, goals.Insert(ctx, g))
+		if g.ID.IsZero() {
+			t.Fatal("Insert did not assign an id")
+		}
+		if _, err := goals.FindByID(ctx, "u2", g.ID.Hex()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByID of another user's goal = %v, want ErrNotFound", err)
+		}
+
+		progress := *g
+		progress.Progress = 2
+		must(t, goals.SaveProgress(ctx, &progress))
+
+		done := *g
+		done.Progress = 2
+		done.Status = models.GoalCompleted
+		now := time.Now().Truncate(time.Millisecond)
+		done.CompletedAt = &now
+		must(t, goals.Update(ctx, &done))
+
+		// progress computed before the completion must not reopen the goal
+		stale := progress
+		stale.Progress = 1
+		must(t, goals.SaveProgress(ctx, &stale))
+		got, err := goals.FindByID(ctx, "u1", g.ID.Hex())
+		must(t, err)
+		if got.Status != models.GoalCompleted || got.Progress != 2 {
+			t.Errorf("goal = %+v, want completed with progress 2", got)
+		}
+
+		active, err := goals.ListActive(ctx, "u1")
+		must(t, err)
+		if len(active) != 0 {
+			t.Errorf("ListActive = %+v", active)
+		}
+		n, err := goals.CountCompletedSince(ctx, "u1", now.Add(-time.Second))
+		must(t, err)
+		if n != 1 {
+			t.Errorf("CountCompletedSince = %d", n)
+		}
+
+		if err := goals.Delete(ctx, "u2", g.ID.Hex()); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Delete of another user's goal = %v, want ErrNotFound", err)
+		}
+		must(t, goals.Delete(ctx, "u1", g.ID.Hex()))
+		if err := goals.Update(ctx, g); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Update of a deleted goal = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestBadgesAndImpactContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		badge := models.Badge{ID: 1, Name: "First Basket"}
+		if isNew, err := repos.Badges.Award(ctx, "u1", badge); err != nil || !isNew {
+			t.Errorf("first Award = %v, %v", isNew, err)
+		}
+		if isNew, err := repos.Badges.Award(ctx, "u1", badge); err != nil || isNew {
+			t.Errorf("second Award = %v, %v, want not new", isNew, err)
+		}
+		page, err := repos.Badges.ListByUser(ctx, "u1", repository.ListQuery{})
+		must(t, err)
+		if page.Total != 1 || page.Items[0].Badge.Name != "First Basket" {
+			t.Errorf("ListByUser = %+v", page)
+		}
+
+		zero, err := repos.Impact.Get(ctx, "u1")
+		must(t, err)
+		if zero.TotalBaskets != 0 {
+			t.Errorf("Get before any Add = %+v", zero)
+		}
+		_, err = repos.Impact.Add(ctx, "u1", 1.25, 1, 40)
+		must(t, err)
+		total, err := repos.Impact.Add(ctx, "u1", 0.5, 1, 60)
+		must(t, err)
+		if total.TotalBaskets != 2 || math.Abs(total.TotalCarbonSaved-1.75) > 1e-9 || total.TotalScore != 100 {
+			t.Errorf("Add returned %+v", total)
+		}
+	})
+}
+
+func TestSearchContract(t *testing.T) {
+	runContract(t, func(t *testing.T, repos *repository.Repos) {
+		ctx := context.Background()
+		for _, p := range []models.Product{
+			{Barcode: "00000000000001", Name: "Greek yogurt", Brand: "Dairy Co", Category: "yogurts", Labels: []string{"organic"}},
+			{Barcode: "00000000000002", Name: "Oat drink", Brand: "Oatly", Category: "plant-based-milks", Categories: []string{"beverages"}, Labels: []string{"vegan"}},
+			{Barcode: "00000000000003", Name: "Chocolate yogurt", Brand: "dairy co", Category: "yogurts", IngredientsText: "milk, sugar, cocoa"},
+			{Barcode: "00000000000004", Name: "apple juice", Brand: "Fruity", Category: "beverages", Description: "Pressed apples"},
+		} {
+			must(t, repos.Products.Upsert(ctx, p))
+		}
+
+		names := func(res repository.SearchResult) []string {
+			out := []string{}
+			for _, p := range res.Products {
+				out = append(out, p.Name)
+			}
+			return out
+		}
+		tests := []struct {
+			name  string
+			query repository.ProductQuery
+			want  []string // in order when the query sorts by name
+		}{
+			{"any term matches", repository.ProductQuery{Text: "yogurt oat", Sort: "name"}, []string{"Chocolate yogurt", "Greek yogurt", "Oat drink"}},
+			{"terms ignore case", repository.ProductQuery{Text: "YOGURT", Sort: "name"}, []string{"Chocolate yogurt", "Greek yogurt"}},
+			{"ingredients and description are searched", repository.ProductQuery{Text: "cocoa apples", Sort: "name"}, []string{"Chocolate yogurt", "apple juice"}},
+			{"no term matches", repository.ProductQuery{Text: "kombucha"}, []string{}},
+			{"brand is exact", repository.ProductQuery{Brand: "Dairy Co"}, []string{"Greek yogurt"}},
+			{"category matches the taxonomy", repository.ProductQuery{Category: "beverages", Sort: "name"}, []string{"Oat drink", "apple juice"}},
+			{"category is exact", repository.ProductQuery{Category: "Beverages"}, []string{}},
+			{"label is exact", repository.ProductQuery{Label: "Organic"}, []string{}},
+			{"label", repository.ProductQuery{Label: "organic"}, []string{"Greek yogurt"}},
+			{"text and filters combine", repository.ProductQuery{Text: "yogurt", Brand: "dairy co"}, []string{"Chocolate yogurt"}},
+			{"name sort is case-sensitive", repository.ProductQuery{Sort: "-name"}, []string{"apple juice", "Oat drink", "Greek yogurt", "Chocolate yogurt"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.query.Limit = 10
+				res, err := repos.Products.Search(ctx, tt.query)
+				must(t, err)
+				got := names(res)
+				if tt.query.Sort == "" {
+					sort.Strings(got)
+					sort.Strings(tt.want)
+				}
+				if !reflect.DeepEqual(got, tt.want) || res.Total != len(tt.want) {
+					t.Errorf("Search = %v (total %d), want %v", got, res.Total, tt.want)
+				}
+			})
+		}
+
+		// name weighs more than ingredients
+		res, err := repos.Products.Search(ctx, repository.ProductQuery{Text: "milk chocolate", Limit: 10})
+		must(t, err)
+		if got := names(res); len(got) != 1 || got[0] != "Chocolate yogurt" {
+			t.Errorf("relevance search = %v", got)
+		}
+
+		first, err := repos.Products.Search(ctx, repository.ProductQuery{Sort: "name", Limit: 3})
+		must(t, err)
+		if first.Total != 4 || len(first.Products) != 3 || first.NextCursor == "" {
+			t.Fatalf("first page = %v, total %d, cursor %q", names(first), first.Total, first.NextCursor)
+		}
+		next, err := repos.Products.Search(ctx, repository.ProductQuery{Sort: "name", Limit: 3, Cursor: first.NextCursor})
+		must(t, err)
+		if got := names(next); len(got) != 1 || got[0] != "apple juice" || next.NextCursor != "" {
+			t.Errorf("second page = %v, cursor %q", got, next.NextCursor)
+		}
+		brands := map[string]int{}
+		for _, f := range first.Facets["brand"] {
+			brands[f.Value] = f.Count
+		}
+		if len(brands) != 4 || brands["Dairy Co"] != 1 || brands["dairy co"] != 1 {
+			t.Errorf("brand facet = %v", first.Facets["brand"])
+		}
+
+		// without an emission factor the client's score is kept, and a product
+		// with none is in no eco band rather than in "e"
+		must(t, repos.Products.Upsert(ctx, models.Product{Barcode: "00000000000005", Name: "Mystery tea", Category: "mystery-things", EcoScore: 72}))
+		must(t, repos.Products.Upsert(ctx, models.Product{Barcode: "00000000000006", Name: "Mystery box", Category: "mystery-things"}))
+		for band, want := range map[string][]string{"b": {"Mystery tea"}, "e": {}} {
+			res, err := repos.Products.Search(ctx, repository.ProductQuery{Text: "mystery", EcoBand: band, Limit: 10})
+			must(t, err)
+			if got := names(res); !reflect.DeepEqual(got, want) {
+				t.Errorf("band %s = %v, want %v", band, got, want)
+			}
+		}
+		res, err = repos.Products.Search(ctx, repository.ProductQuery{Text: "mystery", Limit: 10})
+		must(t, err)
+		if res.Total != 2 || !reflect.DeepEqual(res.Facets["eco_band"], []repository.FacetCount{{Value: "b", Count: 1}}) {
+			t.Errorf("total %d, eco_band facet = %v", res.Total, res.Facets["eco_band"])
+		}
+	})
+}
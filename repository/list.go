@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ListQuery pages, orders and time-filters a list; the zero value returns everything in default order
+type ListQuery struct {
+	Limit  int    // 0 means no limit
+	Cursor string // opaque, from a previous Page.NextCursor
+	Sort   string // comma-separated fields, "-" prefix for descending, e.g. "-created_at,name"
+	Since  time.Time
+	Until  time.Time // exclusive; zero Since/Until are unbounded
+}
+
+// Page is one slice of a list plus what the client needs to fetch the next one
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// QueryError reports a list parameter the collection cannot honour
+type QueryError struct {
+	Param  string
+	Reason string
+}
+
+func (e *QueryError) Error() string { return e.Param + ": " + e.Reason }
+
+// listSpec describes how a collection can be listed
+type listSpec struct {
+	timeField   string   // field Since/Until apply to
+	defaultSort string   // used when ListQuery.Sort is empty
+	sortable    []string // json and bson names, which match in every model
+}
+
+var (
+	productList = listSpec{timeField: "created_at", sortable: []string{"name", "brand", "barcode", "ecoScore", "created_at"}}
+	basketList  = listSpec{timeField: "created_at", defaultSort: "-created_at", sortable: []string{"created_at", "total_carbon", "total_items", "avg_health_score"}}
+	historyList = listSpec{timeField: "time", defaultSort: "-time", sortable: []string{"time", "barcode"}}
+	goalList    = listSpec{timeField: "created_at", defaultSort: "-created_at", sortable: []string{"created_at", "type", "target_value", "progress"}}
+	badgeList   = listSpec{timeField: "earned_at", defaultSort: "badge_id", sortable: []string{"badge_id", "earned_at"}}
+)
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// sortKeys validates q.Sort against the spec, falling back to its default order
+func (s listSpec) sortKeys(q ListQuery) ([]sortKey, error) {
+	spec := q.Sort
+	if spec == "" {
+		spec = s.defaultSort
+	}
+	var keys []sortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k := sortKey{field: strings.TrimPrefix(part, "-"), desc: strings.HasPrefix(part, "-")}
+		if !contains(s.sortable, k.field) {
+			return nil, &QueryError{Param: "sort", Reason: fmt.Sprintf("unknown field %q, expected one of %s", k.field, strings.Join(s.sortable, ", "))}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (s listSpec) inRange(t time.Time, q ListQuery) bool {
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// mongoFilter adds the Since/Until bounds to filter
+func (s listSpec) mongoFilter(filter bson.M, q ListQuery) bson.M {
+	bounds := bson.M{}
+	if !q.Since.IsZero() {
+		bounds["$gte"] = q.Since
+	}
+	if !q.Until.IsZero() {
+		bounds["$lt"] = q.Until
+	}
+	if len(bounds) > 0 {
+		filter[s.timeField] = bounds
+	}
+	return filter
+}
+
+// mongoSort turns the sort keys into a sort document with _id as the tie-breaker, so pages are stable
+func mongoSort(keys []sortKey) bson.D {
+	d := bson.D{}
+	for _, k := range keys {
+		dir := 1
+		if k.desc {
+			dir = -1
+		}
+		d = append(d, bson.E{Key: k.field, Value: dir})
+	}
+	return append(d, bson.E{Key: "_id", Value: 1})
+}
+
+// pageSlice applies a ListQuery to items held in memory. field returns the
+// sortable value named by a sort key; stamp returns the time Since/Until apply to.
+func pageSlice[T any](items []T, q ListQuery, spec listSpec, field func(T, string) interface{}, stamp func(T) time.Time) (Page[T], error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	keys, err := spec.sortKeys(q)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	matched := []T{}
+	for _, it := range items {
+		if spec.inRange(stamp(it), q) {
+			matched = append(matched, it)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		for _, k := range keys {
+			c := compareValues(field(matched[i], k.field), field(matched[j], k.field))
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != k.desc
+		}
+		return false
+	})
+
+	page := Page[T]{Items: []T{}, Total: len(matched)}
+	if offset >= len(matched) {
+		return page, nil
+	}
+	end := len(matched)
+	if q.Limit > 0 && offset+q.Limit < end {
+		end = offset + q.Limit
+	}
+	page.Items = matched[offset:end]
+	if end < len(matched) {
+		page.NextCursor = encodeCursor(end)
+	}
+	return page, nil
+}
+
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case string:
+		return strings.Compare(av, b.(string))
+	case int:
+		return av - b.(int)
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
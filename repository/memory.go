@@ -0,0 +1,661 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/models"
+	"backend/scoring"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemory returns repositories that keep everything in process memory
+func NewMemory() *Repos {
+	baskets := &memBaskets{}
+	impact := &memImpact{totals: map[string]models.Impact{}}
+	return &Repos{
+		Products: NewMemoryProducts(),
+		Baskets:  baskets,
+		Saver:    &memBasketSaver{baskets: baskets, impact: impact},
+		Active:   &memActiveBaskets{baskets: map[string]models.ActiveBasket{}},
+		History:  &memHistory{},
+		Goals:    &memGoals{},
+		Badges:   &memBadges{},
+		Impact:   impact,
+		Users:    &memUsers{byID: map[string]*models.User{}},
+	}
+}
+
+// ---- products
+
+// MemoryProducts is an in-memory ProductRepo; Put is handy for seeding fixtures
+type MemoryProducts struct {
+	mu       sync.RWMutex
+	products map[string]models.Product
+	order    []string // barcodes in insertion order
+}
+
+func NewMemoryProducts(products ...models.Product) *MemoryProducts {
+	r := &MemoryProducts{products: map[string]models.Product{}}
+	for _, p := range products {
+		r.Put(p)
+	}
+	return r
+}
+
+func (r *MemoryProducts) Put(p models.Product) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.products[p.Barcode]; !ok {
+		r.order = append(r.order, p.Barcode)
+	}
+	if p.ID.IsZero() {
+		p.ID = r.products[p.Barcode].ID
+	}
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	r.products[p.Barcode] = p
+}
+
+func (r *MemoryProducts) List(ctx context.Context, q ListQuery) (Page[models.Product], error) {
+	return pageSlice(r.all(), q, productList, func(p models.Product, field string) interface{} {
+		switch field {
+		case "name":
+			return p.Name
+		case "brand":
+			return p.Brand
+		case "barcode":
+			return p.Barcode
+		case "ecoScore":
+			return p.EcoScore
+		}
+		return p.CreatedAt
+	}, func(p models.Product) time.Time { return p.CreatedAt })
+}
+
+// all returns every product in insertion order
+func (r *MemoryProducts) all() []models.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	products := make([]models.Product, 0, len(r.order))
+	for _, code := range r.order {
+		products = append(products, r.products[code])
+	}
+	return products
+}
+
+func (r *MemoryProducts) FindByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.products[barcode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+func (r *MemoryProducts) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	found := make(map[string]*models.Product, len(barcodes))
+	for _, code := range barcodes {
+		if p, ok := r.products[code]; ok {
+			found[code] = &p
+		}
+	}
+	return found, nil
+}
+
+func (r *MemoryProducts) Search(ctx context.Context, q ProductQuery) (SearchResult, error) {
+	return searchSlice(r.all(), q)
+}
+
+func (r *MemoryProducts) Upsert(ctx context.Context, p models.Product) error {
+	scoring.ScoreProduct(&p)
+	r.Put(p)
+	return nil
+}
+
+func (r *MemoryProducts) ChangeBarcode(ctx context.Context, from, to string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.products[from]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, taken := r.products[to]; taken {
+		return ErrDuplicate
+	}
+	delete(r.products, from)
+	p.Barcode = to
+	r.products[to] = p
+	for i, code := range r.order {
+		if code == from {
+			r.order[i] = to
+		}
+	}
+	return nil
+}
+
+// ---- baskets
+
+type memBaskets struct {
+	mu      sync.RWMutex
+	baskets []models.Basket
+}
+
+func (r *memBaskets) Insert(ctx context.Context, b *models.Basket) error {
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b.IdempotencyKey != "" {
+		for _, saved := range r.baskets {
+			if saved.UserID == b.UserID && saved.IdempotencyKey == b.IdempotencyKey {
+				return ErrDuplicate
+			}
+		}
+	}
+	r.baskets = append(r.baskets, *b)
+	return nil
+}
+
+func (r *memBaskets) FindByIdempotencyKey(ctx context.Context, userID, key string) (*models.Basket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, b := range r.baskets {
+		if b.UserID == userID && b.IdempotencyKey == key {
+			return &b, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memBaskets) FindByID(ctx context.Context, userID, id string) (*models.Basket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, b := range r.baskets {
+		if b.ID.Hex() == id && b.UserID == userID {
+			return &b, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memBaskets) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.Basket], error) {
+	r.mu.RLock()
+	out := []models.Basket{}
+	for _, b := range r.baskets {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	r.mu.RUnlock()
+	return pageSlice(out, q, basketList, func(b models.Basket, field string) interface{} {
+		switch field {
+		case "total_carbon":
+			return b.TotalCarbon
+		case "total_items":
+			return b.TotalItems
+		case "avg_health_score":
+			return b.AvgHealthScore
+		}
+		return b.CreatedAt
+	}, func(b models.Basket) time.Time { return b.CreatedAt })
+}
+
+func (r *memBaskets) SumCarbonSince(ctx context.Context, userID string, since time.Time) (float64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sum := 0.0
+	for _, b := range r.baskets {
+		if b.UserID == userID && !b.CreatedAt.Before(since) {
+			sum += b.TotalCarbon
+		}
+	}
+	return sum, nil
+}
+
+func (r *memBaskets) StatsSince(ctx context.Context, userID string, since time.Time) (BasketStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var st BasketStats
+	eco := 0.0
+	for _, b := range r.baskets {
+		if b.UserID == userID && !b.CreatedAt.Before(since) {
+			st.Baskets++
+			st.Items += b.TotalItems
+			st.Carbon += b.TotalCarbon
+			st.Score += float64(b.AvgHealthScore)
+			eco += float64(b.AvgHealthScore * b.TotalItems)
+		}
+	}
+	if st.Items > 0 {
+		st.AvgEcoScore = eco / float64(st.Items)
+	}
+	return st, nil
+}
+
+// memBasketSaver cannot fail between the two writes, so it needs no transaction
+type memBasketSaver struct {
+	baskets *memBaskets
+	impact  *memImpact
+}
+
+func (r *memBasketSaver) Save(ctx context.Context, b *models.Basket) (models.Impact, error) {
+	if err := r.baskets.Insert(ctx, b); err != nil {
+		return models.Impact{}, err
+	}
+	return r.impact.Add(ctx, b.UserID, b.TotalCarbon, 1, float64(b.AvgHealthScore))
+}
+
+// ---- active baskets
+
+type memActiveBaskets struct {
+	mu      sync.Mutex
+	baskets map[string]models.ActiveBasket
+}
+
+// get returns a copy of owner's basket; callers hold mu
+func (r *memActiveBaskets) get(owner string) models.ActiveBasket {
+	b, ok := r.baskets[owner]
+	if !ok {
+		return models.ActiveBasket{Owner: owner, Items: []models.BasketItem{}}
+	}
+	b.Items = append([]models.BasketItem{}, b.Items...)
+	return b
+}
+
+func (r *memActiveBaskets) put(b models.ActiveBasket) models.ActiveBasket {
+	b.UpdatedAt = time.Now()
+	r.baskets[b.Owner] = b
+	return r.get(b.Owner)
+}
+
+func (r *memActiveBaskets) Get(ctx context.Context, owner string) (models.ActiveBasket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(owner), nil
+}
+
+func (r *memActiveBaskets) AddItem(ctx context.Context, owner, productID string, quantity int) (models.ActiveBasket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.get(owner)
+	for i := range b.Items {
+		if b.Items[i].ProductID == productID {
+			b.Items[i].Quantity += quantity
+			return r.put(b), nil
+		}
+	}
+	b.Items = append(b.Items, models.BasketItem{ProductID: productID, Quantity: quantity})
+	return r.put(b), nil
+}
+
+func (r *memActiveBaskets) SetQuantity(ctx context.Context, owner, productID string, quantity int) (models.ActiveBasket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.get(owner)
+	for i := range b.Items {
+		if b.Items[i].ProductID == productID {
+			b.Items[i].Quantity = quantity
+			return r.put(b), nil
+		}
+	}
+	return b, ErrNotFound
+}
+
+func (r *memActiveBaskets) RemoveItem(ctx context.Context, owner, productID string) (models.ActiveBasket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.get(owner)
+	for i := range b.Items {
+		if b.Items[i].ProductID == productID {
+			b.Items = append(b.Items[:i], b.Items[i+1:]...)
+			return r.put(b), nil
+		}
+	}
+	return b, ErrNotFound
+}
+
+func (r *memActiveBaskets) Clear(ctx context.Context, owner string) error {
+	r.mu.Lock()
+	delete(r.baskets, owner)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *memActiveBaskets) Merge(ctx context.Context, from, to string) (models.ActiveBasket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	src, b := r.get(from), r.get(to)
+	if len(src.Items) == 0 {
+		return b, nil
+	}
+next:
+	for _, item := range src.Items {
+		for i := range b.Items {
+			if b.Items[i].ProductID == item.ProductID {
+				b.Items[i].Quantity += item.Quantity
+				continue next
+			}
+		}
+		b.Items = append(b.Items, item)
+	}
+	delete(r.baskets, from)
+	return r.put(b), nil
+}
+
+// ---- history
+
+type memHistory struct {
+	mu      sync.RWMutex
+	history []models.ScanHistory
+}
+
+func (r *memHistory) Add(ctx context.Context, h models.ScanHistory) error {
+	r.mu.Lock()
+	r.history = append(r.history, h)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *memHistory) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.ScanHistory], error) {
+	r.mu.RLock()
+	out := []models.ScanHistory{}
+	for _, h := range r.history {
+		if h.UserID == userID {
+			out = append(out, h)
+		}
+	}
+	r.mu.RUnlock()
+	return pageSlice(out, q, historyList, func(h models.ScanHistory, field string) interface{} {
+		if field == "barcode" {
+			return h.Barcode
+		}
+		return h.Time
+	}, func(h models.ScanHistory) time.Time { return h.Time })
+}
+
+func (r *memHistory) ClearByUser(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.history[:0]
+	for _, h := range r.history {
+		if h.UserID != userID {
+			kept = append(kept, h)
+		}
+	}
+	r.history = kept
+	return nil
+}
+
+func (r *memHistory) CountSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, h := range r.history {
+		if h.UserID == userID && !h.Time.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ---- goals
+
+type memGoals struct {
+	mu    sync.RWMutex
+	goals []models.Goal
+}
+
+func (r *memGoals) Insert(ctx context.Context, g *models.Goal) error {
+	if g.ID.IsZero() {
+		g.ID = primitive.NewObjectID()
+	}
+	r.mu.Lock()
+	r.goals = append(r.goals, *g)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *memGoals) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.Goal], error) {
+	r.mu.RLock()
+	out := []models.Goal{}
+	for _, g := range r.goals {
+		if g.UserID == userID {
+			out = append(out, g)
+		}
+	}
+	r.mu.RUnlock()
+	return pageSlice(out, q, goalList, func(g models.Goal, field string) interface{} {
+		switch field {
+		case "type":
+			return g.Type
+		case "target_value":
+			return g.TargetValue
+		case "progress":
+			return g.Progress
+		}
+		return g.CreatedAt
+	}, func(g models.Goal) time.Time { return g.CreatedAt })
+}
+
+func (r *memGoals) ListActive(ctx context.Context, userID string) ([]models.Goal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.Goal{}
+	for _, g := range r.goals {
+		if g.UserID == userID && g.Active() {
+			out = append(out, g)
+		}
+	}
+	return out, nil // goals are kept in insertion order
+}
+
+func (r *memGoals) CountCompletedSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, g := range r.goals {
+		if g.UserID == userID && g.CompletedAt != nil && !g.CompletedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memGoals) FindByID(ctx context.Context, userID, id string) (*models.Goal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, g := range r.goals {
+		if g.ID.Hex() == id && g.UserID == userID {
+			return &g, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memGoals) Update(ctx context.Context, g *models.Goal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, cur := range r.goals {
+		if cur.ID == g.ID && cur.UserID == g.UserID {
+			r.goals[i] = *g
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memGoals) SaveProgress(ctx context.Context, g *models.Goal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, cur := range r.goals {
+		if cur.ID == g.ID && cur.UserID == g.UserID && cur.Active() {
+			r.goals[i].Progress = g.Progress
+			r.goals[i].Status = g.Status
+			r.goals[i].CompletedAt = g.CompletedAt
+			r.goals[i].UpdatedAt = g.UpdatedAt
+			break
+		}
+	}
+	return nil
+}
+
+func (r *memGoals) Delete(ctx context.Context, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, g := range r.goals {
+		if g.ID.Hex() == id && g.UserID == userID {
+			r.goals = append(r.goals[:i], r.goals[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ---- badges
+
+type memBadges struct {
+	mu     sync.RWMutex
+	badges []models.UserBadge
+}
+
+func (r *memBadges) Award(ctx context.Context, userID string, badge models.Badge) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.badges {
+		if b.UserID == userID && b.BadgeID == badge.ID {
+			return false, nil
+		}
+	}
+	r.badges = append(r.badges, models.UserBadge{
+		ID:       primitive.NewObjectID(),
+		UserID:   userID,
+		BadgeID:  badge.ID,
+		Badge:    badge,
+		EarnedAt: time.Now(),
+	})
+	return true, nil
+}
+
+func (r *memBadges) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.UserBadge], error) {
+	r.mu.RLock()
+	out := []models.UserBadge{}
+	for _, b := range r.badges {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	r.mu.RUnlock()
+	return pageSlice(out, q, badgeList, func(b models.UserBadge, field string) interface{} {
+		if field == "badge_id" {
+			return b.BadgeID
+		}
+		return b.EarnedAt
+	}, func(b models.UserBadge) time.Time { return b.EarnedAt })
+}
+
+// ---- impact
+
+type memImpact struct {
+	mu     sync.Mutex
+	totals map[string]models.Impact
+}
+
+func (r *memImpact) Add(ctx context.Context, userID string, carbon float64, baskets int, score float64) (models.Impact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.totals[userID]
+	if !ok {
+		t = models.Impact{UserID: userID, CreatedAt: time.Now()}
+	}
+	t.TotalCarbonSaved += carbon
+	t.TotalBaskets += baskets
+	t.TotalScore += score
+	r.totals[userID] = t
+	return t, nil
+}
+
+func (r *memImpact) Get(ctx context.Context, userID string) (models.Impact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.totals[userID]; ok {
+		return t, nil
+	}
+	return models.Impact{UserID: userID}, nil
+}
+
+// ---- users
+
+type memUsers struct {
+	mu   sync.RWMutex
+	byID map[string]*models.User
+}
+
+func (r *memUsers) Create(ctx context.Context, u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.byID {
+		if strings.EqualFold(existing.Email, u.Email) {
+			return ErrDuplicate
+		}
+	}
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	cp := *u
+	r.byID[u.ID.Hex()] = &cp
+	return nil
+}
+
+func (r *memUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.byID {
+		if strings.EqualFold(u.Email, email) {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memUsers) FindByID(ctx context.Context, id string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.byID[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *memUsers) SetRole(ctx context.Context, id, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Role = role
+	return nil
+}
+
+func (r *memUsers) SetDietaryProfile(ctx context.Context, id string, profile models.DietaryProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.DietaryProfile = &profile
+	return nil
+}
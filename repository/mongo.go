@@ -0,0 +1,829 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"backend/models"
+	"backend/scoring"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongo returns repositories backed by the given database
+func NewMongo(db *mongo.Database) *Repos {
+	baskets := &mongoBaskets{coll: db.Collection("baskets")}
+	impact := &mongoImpact{coll: db.Collection("impact")}
+	return &Repos{
+		Products: &mongoProducts{coll: db.Collection("products")},
+		Baskets:  baskets,
+		Saver:    &mongoBasketSaver{client: db.Client(), baskets: baskets, impact: impact},
+		Active:   &mongoActiveBaskets{client: db.Client(), coll: db.Collection("active_baskets")},
+		History:  &mongoHistory{coll: db.Collection("history")},
+		Goals:    &mongoGoals{coll: db.Collection("goals")},
+		Badges:   &mongoBadges{coll: db.Collection("user_badges")},
+		Impact:   impact,
+		Users:    &mongoUsers{coll: db.Collection("users")},
+	}
+}
+
+// findPage runs one page of a list query plus a count of everything matching the filter
+func findPage[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, q ListQuery, spec listSpec) (Page[T], error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	keys, err := spec.sortKeys(q)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	filter = spec.mongoFilter(filter, q)
+
+	opts := options.Find().SetSort(mongoSort(keys)).SetSkip(int64(offset))
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	page := Page[T]{Items: []T{}}
+	if err := cursor.All(ctx, &page.Items); err != nil {
+		return Page[T]{}, err
+	}
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	page.Total = int(total)
+	if next := offset + len(page.Items); q.Limit > 0 && next < page.Total {
+		page.NextCursor = encodeCursor(next)
+	}
+	return page, nil
+}
+
+// ---- products
+
+type mongoProducts struct{ coll *mongo.Collection }
+
+// productDoc tolerates the loosely typed documents older clients wrote under "Name"/"EcoScore"
+type productDoc struct {
+	models.Product `bson:",inline"`
+	LegacyName     string   `bson:"Name,omitempty"`
+	LegacyEcoScore *float64 `bson:"EcoScore,omitempty"`
+}
+
+func (d productDoc) product() models.Product {
+	p := d.Product
+	if p.Name == "" {
+		p.Name = d.LegacyName
+	}
+	if p.EcoScore == 0 && d.LegacyEcoScore != nil {
+		p.EcoScore = int(*d.LegacyEcoScore)
+	}
+	return p
+}
+
+func (r *mongoProducts) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Product, error) {
+	cursor, err := r.coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var docs []productDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	products := make([]models.Product, len(docs))
+	for i, d := range docs {
+		products[i] = d.product()
+	}
+	return products, nil
+}
+
+func (r *mongoProducts) List(ctx context.Context, q ListQuery) (Page[models.Product], error) {
+	docs, err := findPage[productDoc](ctx, r.coll, bson.M{}, q, productList)
+	if err != nil {
+		return Page[models.Product]{}, err
+	}
+	page := Page[models.Product]{Items: make([]models.Product, len(docs.Items)), Total: docs.Total, NextCursor: docs.NextCursor}
+	for i, d := range docs.Items {
+		page.Items[i] = d.product()
+	}
+	return page, nil
+}
+
+func (r *mongoProducts) FindByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	var d productDoc
+	err := r.coll.FindOne(ctx, bson.M{"barcode": barcode}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := d.product()
+	return &p, nil
+}
+
+// FindByBarcodes resolves all barcodes with one `$in` query
+func (r *mongoProducts) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	found := make(map[string]*models.Product, len(barcodes))
+	if len(barcodes) == 0 {
+		return found, nil
+	}
+	products, err := r.find(ctx, bson.M{"barcode": bson.M{"$in": barcodes}})
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		if _, dup := found[products[i].Barcode]; !dup {
+			found[products[i].Barcode] = &products[i]
+		}
+	}
+	return found, nil
+}
+
+func (r *mongoProducts) Upsert(ctx context.Context, p models.Product) error {
+	scoring.ScoreProduct(&p)
+	_, err := r.coll.UpdateOne(ctx, bson.M{"barcode": p.Barcode}, bson.M{"$set": p}, options.Update().SetUpsert(true))
+	return err
+}
+
+// hasEcoScore matches the products models.Product.HasEcoScore accepts
+var hasEcoScore = bson.M{"$or": bson.A{
+	bson.M{"ecoScore": bson.M{"$gt": 0}},
+	bson.M{"ecoscore_grade": bson.M{"$in": bson.A{"a", "b", "c", "d", "e"}}},
+}}
+
+// Search uses the products text index and a single $facet aggregation for results, total and facet counts
+func (r *mongoProducts) Search(ctx context.Context, q ProductQuery) (SearchResult, error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	match := bson.M{}
+	if q.Text != "" {
+		match["$text"] = bson.M{"$search": q.Text}
+	}
+	if q.Brand != "" {
+		match["brand"] = q.Brand
+	}
+	if q.Category != "" {
+		match["$or"] = bson.A{bson.M{"category": q.Category}, bson.M{"categories": q.Category}}
+	}
+	if q.Label != "" {
+		match["labels"] = q.Label
+	}
+	if q.EcoBand != "" {
+		min, max, ok := ecoBandRange(q.EcoBand)
+		if !ok {
+			return SearchResult{}, errors.New("unknown eco band " + q.EcoBand)
+		}
+		match["ecoScore"] = bson.M{"$gte": min, "$lte": max}
+		match["$and"] = bson.A{hasEcoScore}
+	}
+
+	sortSpec := bson.D{{Key: "_id", Value: 1}}
+	switch q.Sort {
+	case "ecoScore":
+		sortSpec = bson.D{{Key: "ecoScore", Value: 1}, {Key: "_id", Value: 1}}
+	case "-ecoScore":
+		sortSpec = bson.D{{Key: "ecoScore", Value: -1}, {Key: "_id", Value: 1}}
+	case "name":
+		sortSpec = bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+	case "-name":
+		sortSpec = bson.D{{Key: "name", Value: -1}, {Key: "_id", Value: 1}}
+	default:
+		if q.Text != "" {
+			sortSpec = bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}
+		}
+	}
+
+	countBy := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": facetLimit},
+		}
+	}
+	bandBranches := bson.A{}
+	for _, b := range ecoBands {
+		bandBranches = append(bandBranches, bson.M{"case": bson.M{"$gte": bson.A{"$ecoScore", b.Min}}, "then": b.Band})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$facet", Value: bson.M{
+			"results":  bson.A{bson.M{"$sort": sortSpec}, bson.M{"$skip": offset}, bson.M{"$limit": q.Limit}},
+			"total":    bson.A{bson.M{"$count": "n"}},
+			"brand":    countBy("brand"),
+			"category": countBy("category"),
+			"labels":   append(bson.A{bson.M{"$unwind": "$labels"}}, countBy("labels")...),
+			"eco_band": bson.A{
+				bson.M{"$match": hasEcoScore},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$switch": bson.M{"branches": bandBranches, "default": "e"}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.D{{Key: "_id", Value: 1}}},
+			},
+		}}},
+	}
+
+	cursor, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	var out []struct {
+		Results []productDoc `bson:"results"`
+		Total   []struct {
+			N int `bson:"n"`
+		} `bson:"total"`
+		Brand    []FacetCount `bson:"brand"`
+		Category []FacetCount `bson:"category"`
+		Labels   []FacetCount `bson:"labels"`
+		EcoBand  []FacetCount `bson:"eco_band"`
+	}
+	if err := cursor.All(ctx, &out); err != nil {
+		return SearchResult{}, err
+	}
+
+	res := SearchResult{Products: []models.Product{}, Facets: map[string][]FacetCount{}}
+	if len(out) == 0 {
+		return res, nil
+	}
+	for _, d := range out[0].Results {
+		res.Products = append(res.Products, d.product())
+	}
+	if len(out[0].Total) > 0 {
+		res.Total = out[0].Total[0].N
+	}
+	res.Facets["brand"] = out[0].Brand
+	res.Facets["category"] = out[0].Category
+	res.Facets["labels"] = out[0].Labels
+	res.Facets["eco_band"] = out[0].EcoBand
+	if next := offset + len(res.Products); next < res.Total {
+		res.NextCursor = encodeCursor(next)
+	}
+	return res, nil
+}
+
+func (r *mongoProducts) ChangeBarcode(ctx context.Context, from, to string) error {
+	n, err := r.coll.CountDocuments(ctx, bson.M{"barcode": to})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrDuplicate
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"barcode": from}, bson.M{"$set": bson.M{"barcode": to}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ---- baskets
+
+type mongoBaskets struct{ coll *mongo.Collection }
+
+func (r *mongoBaskets) Insert(ctx context.Context, b *models.Basket) error {
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, b)
+	return err
+}
+
+func (r *mongoBaskets) FindByIdempotencyKey(ctx context.Context, userID, key string) (*models.Basket, error) {
+	var b models.Basket
+	err := r.coll.FindOne(ctx, bson.M{"user_id": userID, "idempotency_key": key}).Decode(&b)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *mongoBaskets) FindByID(ctx context.Context, userID, id string) (*models.Basket, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var b models.Basket
+	err = r.coll.FindOne(ctx, bson.M{"_id": oid, "user_id": userID}).Decode(&b)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *mongoBaskets) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.Basket], error) {
+	return findPage[models.Basket](ctx, r.coll, bson.M{"user_id": userID}, q, basketList)
+}
+
+func (r *mongoBaskets) SumCarbonSince(ctx context.Context, userID string, since time.Time) (float64, error) {
+	cursor, err := r.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "created_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "sum": bson.M{"$sum": "$total_carbon"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var out []struct {
+		Sum float64 `bson:"sum"`
+	}
+	if err := cursor.All(ctx, &out); err != nil || len(out) == 0 {
+		return 0, err
+	}
+	return out[0].Sum, nil
+}
+
+func (r *mongoBaskets) StatsSince(ctx context.Context, userID string, since time.Time) (BasketStats, error) {
+	cursor, err := r.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "created_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"baskets": bson.M{"$sum": 1},
+			"items":   bson.M{"$sum": "$total_items"},
+			"carbon":  bson.M{"$sum": "$total_carbon"},
+			"score":   bson.M{"$sum": "$avg_health_score"},
+			"eco":     bson.M{"$sum": bson.M{"$multiply": bson.A{"$avg_health_score", "$total_items"}}},
+		}}},
+	})
+	if err != nil {
+		return BasketStats{}, err
+	}
+	var out []struct {
+		Baskets int     `bson:"baskets"`
+		Items   int     `bson:"items"`
+		Carbon  float64 `bson:"carbon"`
+		Score   float64 `bson:"score"`
+		Eco     float64 `bson:"eco"`
+	}
+	if err := cursor.All(ctx, &out); err != nil || len(out) == 0 {
+		return BasketStats{}, err
+	}
+	st := BasketStats{Baskets: out[0].Baskets, Items: out[0].Items, Carbon: out[0].Carbon, Score: out[0].Score}
+	if st.Items > 0 {
+		st.AvgEcoScore = out[0].Eco / float64(st.Items)
+	}
+	return st, nil
+}
+
+// mongoBasketSaver writes a basket and its impact increment in one transaction.
+// Transactions need a replica set; against a standalone server it falls back
+// to writing the basket first and deleting it again if the increment fails.
+type mongoBasketSaver struct {
+	client  *mongo.Client
+	baskets *mongoBaskets
+	impact  *mongoImpact
+	noTxn   atomic.Bool // set once the server has refused a transaction
+}
+
+func (r *mongoBasketSaver) Save(ctx context.Context, b *models.Basket) (models.Impact, error) {
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+	if !r.noTxn.Load() {
+		impact, err := r.saveInTransaction(ctx, b)
+		if !transactionsUnsupported(err) {
+			return impact, duplicateErr(err)
+		}
+		r.noTxn.Store(true)
+	}
+	return r.saveCompensated(ctx, b)
+}
+
+func (r *mongoBasketSaver) saveInTransaction(ctx context.Context, b *models.Basket) (models.Impact, error) {
+	session, err := r.client.StartSession()
+	if err != nil {
+		return models.Impact{}, err
+	}
+	defer session.EndSession(ctx)
+	impact, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := r.baskets.coll.InsertOne(sc, b); err != nil {
+			return nil, err
+		}
+		return r.impact.Add(sc, b.UserID, b.TotalCarbon, 1, float64(b.AvgHealthScore))
+	})
+	if err != nil {
+		return models.Impact{}, err
+	}
+	return impact.(models.Impact), nil
+}
+
+func (r *mongoBasketSaver) saveCompensated(ctx context.Context, b *models.Basket) (models.Impact, error) {
+	if _, err := r.baskets.coll.InsertOne(ctx, b); err != nil {
+		return models.Impact{}, duplicateErr(err)
+	}
+	impact, err := r.impact.Add(ctx, b.UserID, b.TotalCarbon, 1, float64(b.AvgHealthScore))
+	if err != nil {
+		// undo the insert even if the request was cancelled, so a retry is not double-counted
+		_, _ = r.baskets.coll.DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": b.ID})
+		return models.Impact{}, err
+	}
+	return impact, nil
+}
+
+// transactionsUnsupported reports whether err is a standalone server refusing a transaction
+func transactionsUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 20 // IllegalOperation
+}
+
+func duplicateErr(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// ---- active baskets
+
+type mongoActiveBaskets struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+	noTxn  atomic.Bool // set once the server has refused a transaction
+}
+
+// decode reads the result of a FindOneAndUpdate returning the updated basket
+func (r *mongoActiveBaskets) decode(res *mongo.SingleResult) (models.ActiveBasket, error) {
+	var b models.ActiveBasket
+	err := res.Decode(&b)
+	if err == mongo.ErrNoDocuments {
+		return b, ErrNotFound
+	}
+	if b.Items == nil {
+		b.Items = []models.BasketItem{}
+	}
+	return b, err
+}
+
+func (r *mongoActiveBaskets) Get(ctx context.Context, owner string) (models.ActiveBasket, error) {
+	b, err := r.decode(r.coll.FindOne(ctx, bson.M{"_id": owner}))
+	if err == ErrNotFound {
+		return models.ActiveBasket{Owner: owner, Items: []models.BasketItem{}}, nil
+	}
+	return b, err
+}
+
+// AddItem first increments an existing line, then pushes a new one, upserting the
+// basket. Both steps are single atomic updates; when a concurrent add of the same
+// product wins the race the upsert hits the _id index and the increment is retried.
+func (r *mongoActiveBaskets) AddItem(ctx context.Context, owner, productID string, quantity int) (models.ActiveBasket, error) {
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	for attempt := 0; attempt < 3; attempt++ {
+		b, err := r.decode(r.coll.FindOneAndUpdate(ctx,
+			bson.M{"_id": owner, "items.product_id": productID},
+			bson.M{"$inc": bson.M{"items.$.quantity": quantity}, "$set": bson.M{"updated_at": time.Now()}},
+			after))
+		if err != ErrNotFound {
+			return b, err
+		}
+		b, err = r.decode(r.coll.FindOneAndUpdate(ctx,
+			bson.M{"_id": owner, "items.product_id": bson.M{"$ne": productID}},
+			bson.M{
+				"$push": bson.M{"items": models.BasketItem{ProductID: productID, Quantity: quantity}},
+				"$set":  bson.M{"updated_at": time.Now()},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true)))
+		if !mongo.IsDuplicateKeyError(err) {
+			return b, err
+		}
+	}
+	return models.ActiveBasket{}, errors.New("active basket: too much contention adding " + productID)
+}
+
+func (r *mongoActiveBaskets) SetQuantity(ctx context.Context, owner, productID string, quantity int) (models.ActiveBasket, error) {
+	return r.decode(r.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": owner, "items.product_id": productID},
+		bson.M{"$set": bson.M{"items.$.quantity": quantity, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)))
+}
+
+func (r *mongoActiveBaskets) RemoveItem(ctx context.Context, owner, productID string) (models.ActiveBasket, error) {
+	return r.decode(r.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": owner, "items.product_id": productID},
+		bson.M{"$pull": bson.M{"items": bson.M{"product_id": productID}}, "$set": bson.M{"updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)))
+}
+
+func (r *mongoActiveBaskets) Clear(ctx context.Context, owner string) error {
+	_, err := r.coll.DeleteOne(ctx, bson.M{"_id": owner})
+	return err
+}
+
+// Merge takes from's basket and adds its lines to to's in one transaction. Like
+// mongoBasketSaver it falls back on a standalone server: the basket is taken
+// first, and the lines not yet added are put back if an add fails.
+func (r *mongoActiveBaskets) Merge(ctx context.Context, from, to string) (models.ActiveBasket, error) {
+	if !r.noTxn.Load() {
+		b, err := r.mergeInTransaction(ctx, from, to)
+		if !transactionsUnsupported(err) {
+			return b, err
+		}
+		r.noTxn.Store(true)
+	}
+	return r.mergeCompensated(ctx, from, to)
+}
+
+func (r *mongoActiveBaskets) mergeInTransaction(ctx context.Context, from, to string) (models.ActiveBasket, error) {
+	session, err := r.client.StartSession()
+	if err != nil {
+		return models.ActiveBasket{}, err
+	}
+	defer session.EndSession(ctx)
+	b, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		src, err := r.decode(r.coll.FindOneAndDelete(sc, bson.M{"_id": from}))
+		if err == ErrNotFound {
+			return r.Get(sc, to)
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.Get(sc, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range src.Items {
+			if b, err = r.AddItem(sc, to, item.ProductID, item.Quantity); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	})
+	if err != nil {
+		return models.ActiveBasket{}, err
+	}
+	return b.(models.ActiveBasket), nil
+}
+
+func (r *mongoActiveBaskets) mergeCompensated(ctx context.Context, from, to string) (models.ActiveBasket, error) {
+	src, err := r.decode(r.coll.FindOneAndDelete(ctx, bson.M{"_id": from}))
+	if err == ErrNotFound {
+		return r.Get(ctx, to)
+	}
+	if err != nil {
+		return models.ActiveBasket{}, err
+	}
+	for i, item := range src.Items {
+		if _, err := r.AddItem(ctx, to, item.ProductID, item.Quantity); err != nil {
+			// put the rest back even if the request was cancelled, so it is not lost
+			for _, rest := range src.Items[i:] {
+				_, _ = r.AddItem(context.WithoutCancel(ctx), from, rest.ProductID, rest.Quantity)
+			}
+			return models.ActiveBasket{}, err
+		}
+	}
+	return r.Get(ctx, to)
+}
+
+// ---- history
+
+type mongoHistory struct{ coll *mongo.Collection }
+
+func (r *mongoHistory) Add(ctx context.Context, h models.ScanHistory) error {
+	_, err := r.coll.InsertOne(ctx, h)
+	return err
+}
+
+func (r *mongoHistory) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.ScanHistory], error) {
+	return findPage[models.ScanHistory](ctx, r.coll, bson.M{"user_id": userID}, q, historyList)
+}
+
+func (r *mongoHistory) ClearByUser(ctx context.Context, userID string) error {
+	_, err := r.coll.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *mongoHistory) CountSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	n, err := r.coll.CountDocuments(ctx, bson.M{"user_id": userID, "time": bson.M{"$gte": since}})
+	return int(n), err
+}
+
+// ---- goals
+
+type mongoGoals struct{ coll *mongo.Collection }
+
+func (r *mongoGoals) Insert(ctx context.Context, g *models.Goal) error {
+	if g.ID.IsZero() {
+		g.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, g)
+	return err
+}
+
+func (r *mongoGoals) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.Goal], error) {
+	return findPage[models.Goal](ctx, r.coll, bson.M{"user_id": userID}, q, goalList)
+}
+
+func (r *mongoGoals) ListActive(ctx context.Context, userID string) ([]models.Goal, error) {
+	cursor, err := r.coll.Find(ctx,
+		bson.M{"user_id": userID, "status": bson.M{"$ne": models.GoalCompleted}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	out := []models.Goal{}
+	if err := cursor.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *mongoGoals) CountCompletedSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	n, err := r.coll.CountDocuments(ctx, bson.M{
+		"user_id":      userID,
+		"status":       models.GoalCompleted,
+		"completed_at": bson.M{"$gte": since},
+	})
+	return int(n), err
+}
+
+func (r *mongoGoals) FindByID(ctx context.Context, userID, id string) (*models.Goal, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var g models.Goal
+	err = r.coll.FindOne(ctx, bson.M{"_id": oid, "user_id": userID}).Decode(&g)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *mongoGoals) Update(ctx context.Context, g *models.Goal) error {
+	res, err := r.coll.ReplaceOne(ctx, bson.M{"_id": g.ID, "user_id": g.UserID}, g)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoGoals) SaveProgress(ctx context.Context, g *models.Goal) error {
+	set := bson.M{"progress": g.Progress, "status": g.Status, "updated_at": g.UpdatedAt}
+	if g.CompletedAt != nil {
+		set["completed_at"] = g.CompletedAt
+	}
+	_, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": g.ID, "user_id": g.UserID, "status": bson.M{"$ne": models.GoalCompleted}},
+		bson.M{"$set": set})
+	return err
+}
+
+func (r *mongoGoals) Delete(ctx context.Context, userID, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": oid, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ---- badges
+
+type mongoBadges struct{ coll *mongo.Collection }
+
+// Award upserts on (user_id, badge_id) so concurrent awards cannot duplicate a badge
+func (r *mongoBadges) Award(ctx context.Context, userID string, badge models.Badge) (bool, error) {
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"user_id": userID, "badge_id": badge.ID},
+		bson.M{"$setOnInsert": bson.M{"badge": badge, "earned_at": time.Now()}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+func (r *mongoBadges) ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.UserBadge], error) {
+	return findPage[models.UserBadge](ctx, r.coll, bson.M{"user_id": userID}, q, badgeList)
+}
+
+// ---- impact
+
+type mongoImpact struct{ coll *mongo.Collection }
+
+func (r *mongoImpact) Add(ctx context.Context, userID string, carbon float64, baskets int, score float64) (models.Impact, error) {
+	update := bson.M{
+		"$inc":         bson.M{"total_carbon_saved": carbon, "total_baskets": baskets, "total_score": score},
+		"$setOnInsert": bson.M{"created_at": time.Now()},
+	}
+	var impact models.Impact
+	err := r.coll.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&impact)
+	return impact, err
+}
+
+func (r *mongoImpact) Get(ctx context.Context, userID string) (models.Impact, error) {
+	impact := models.Impact{UserID: userID}
+	err := r.coll.FindOne(ctx, bson.M{"_id": userID}).Decode(&impact)
+	if err == mongo.ErrNoDocuments {
+		return impact, nil
+	}
+	return impact, err
+}
+
+// ---- users
+
+type mongoUsers struct{ coll *mongo.Collection }
+
+func (r *mongoUsers) Create(ctx context.Context, u *models.User) error {
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	_, err := r.coll.InsertOne(ctx, u)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *mongoUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *mongoUsers) FindByID(ctx context.Context, id string) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *mongoUsers) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var u models.User
+	err := r.coll.FindOne(ctx, filter).Decode(&u)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *mongoUsers) SetRole(ctx context.Context, id, role string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUsers) SetDietaryProfile(ctx context.Context, id string, profile models.DietaryProfile) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"dietary_profile": profile}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package repository defines typed access to the application's collections,
+// with a Mongo implementation for production and an in-memory one for tests and tools.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend/models"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate key")
+)
+
+type ProductRepo interface {
+	List(ctx context.Context, q ListQuery) (Page[models.Product], error)
+	// FindByBarcode returns ErrNotFound when no product has the barcode
+	FindByBarcode(ctx context.Context, barcode string) (*models.Product, error)
+	// FindByBarcodes resolves many barcodes at once; missing ones are absent from the map
+	FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error)
+	// Upsert inserts or replaces the product's fields, matching on barcode.
+	// Nutri-Score and Eco-Score are recomputed first, see scoring.ScoreProduct.
+	Upsert(ctx context.Context, p models.Product) error
+	// Search runs a full-text, faceted, paginated product query
+	Search(ctx context.Context, q ProductQuery) (SearchResult, error)
+	// ChangeBarcode renames a product's barcode; ErrDuplicate if to is taken, ErrNotFound if from is missing
+	ChangeBarcode(ctx context.Context, from, to string) error
+}
+
+type BasketRepo interface {
+	Insert(ctx context.Context, b *models.Basket) error
+	// FindByID returns ErrNotFound when the basket does not exist or belongs to another user
+	FindByID(ctx context.Context, userID, id string) (*models.Basket, error)
+	// FindByIdempotencyKey returns the user's basket saved under key, ErrNotFound if none
+	FindByIdempotencyKey(ctx context.Context, userID, key string) (*models.Basket, error)
+	// ListByUser defaults to most recent first
+	ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.Basket], error)
+	// SumCarbonSince totals total_carbon of the user's baskets created at or after since
+	SumCarbonSince(ctx context.Context, userID string, since time.Time) (float64, error)
+	// StatsSince aggregates the user's baskets created at or after since
+	StatsSince(ctx context.Context, userID string, since time.Time) (BasketStats, error)
+}
+
+// BasketSaver records a saved basket together with its effect on the owner's impact totals
+type BasketSaver interface {
+	// Save inserts b and adds it to the impact totals of b.UserID as one unit:
+	// both writes happen or neither does. A non-empty b.IdempotencyKey already
+	// used by the same user fails with ErrDuplicate and writes nothing.
+	Save(ctx context.Context, b *models.Basket) (models.Impact, error)
+}
+
+// BasketStats aggregates a set of saved baskets
+type BasketStats struct {
+	Baskets     int
+	Items       int
+	Carbon      float64
+	Score       float64 // sum of the baskets' avg_health_score
+	AvgEcoScore float64 // item-weighted average of the baskets' avg_health_score
+}
+
+// ActiveBasketRepo keeps one basket in progress per owner, a user or an anonymous
+// session. A missing basket reads as empty; every change returns the basket as it now is.
+type ActiveBasketRepo interface {
+	Get(ctx context.Context, owner string) (models.ActiveBasket, error)
+	// AddItem adds quantity of the product, merging with its line if it is already in the basket
+	AddItem(ctx context.Context, owner, productID string, quantity int) (models.ActiveBasket, error)
+	// SetQuantity and RemoveItem return ErrNotFound when the product is not in the basket
+	SetQuantity(ctx context.Context, owner, productID string, quantity int) (models.ActiveBasket, error)
+	RemoveItem(ctx context.Context, owner, productID string) (models.ActiveBasket, error)
+	Clear(ctx context.Context, owner string) error
+	// Merge moves every line of from's basket into to's, adding quantities as
+	// AddItem does, and deletes from's basket. A line is never moved twice: after
+	// a failure a retry moves only what is still in from's basket.
+	Merge(ctx context.Context, from, to string) (models.ActiveBasket, error)
+}
+
+type HistoryRepo interface {
+	Add(ctx context.Context, h models.ScanHistory) error
+	// ListByUser defaults to most recent first
+	ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.ScanHistory], error)
+	ClearByUser(ctx context.Context, userID string) error
+	// CountSince counts the user's scans at or after since
+	CountSince(ctx context.Context, userID string, since time.Time) (int, error)
+}
+
+type GoalRepo interface {
+	Insert(ctx context.Context, g *models.Goal) error
+	// ListByUser defaults to most recent first
+	ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.Goal], error)
+	// ListActive returns every goal of the user that is not completed, oldest first
+	ListActive(ctx context.Context, userID string) ([]models.Goal, error)
+	// CountCompletedSince counts the user's goals completed at or after since
+	CountCompletedSince(ctx context.Context, userID string, since time.Time) (int, error)
+	// FindByID, Update and Delete return ErrNotFound when the goal does not exist or belongs to another user
+	FindByID(ctx context.Context, userID, id string) (*models.Goal, error)
+	Update(ctx context.Context, g *models.Goal) error
+	// SaveProgress writes only g's progress, status and completion time, and only
+	// while the stored goal is still active, so it cannot undo a concurrent edit
+	// or completion. A goal that is gone or completed is left alone without error.
+	SaveProgress(ctx context.Context, g *models.Goal) error
+	Delete(ctx context.Context, userID, id string) error
+}
+
+type BadgeRepo interface {
+	// Award records the badge for the user unless it was already earned, reporting whether it was new
+	Award(ctx context.Context, userID string, badge models.Badge) (bool, error)
+	// ListByUser defaults to badge id order
+	ListByUser(ctx context.Context, userID string, q ListQuery) (Page[models.UserBadge], error)
+}
+
+type ImpactRepo interface {
+	// Add atomically increments the user's totals, creating the document on first
+	// use, and returns the totals as they are right after this increment
+	Add(ctx context.Context, userID string, carbon float64, baskets int, score float64) (models.Impact, error)
+	// Get returns the user's totals, or zero totals if nothing was recorded yet
+	Get(ctx context.Context, userID string) (models.Impact, error)
+}
+
+type UserRepo interface {
+	// Create returns ErrDuplicate when the email is taken
+	Create(ctx context.Context, u *models.User) error
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	SetRole(ctx context.Context, id, role string) error
+	SetDietaryProfile(ctx context.Context, id string, profile models.DietaryProfile) error
+}
+
+// Repos bundles every repository the handlers need
+type Repos struct {
+	Products ProductRepo
+	Baskets  BasketRepo
+	Saver    BasketSaver
+	Active   ActiveBasketRepo
+	History  HistoryRepo
+	Goals    GoalRepo
+	Badges   BadgeRepo
+	Impact   ImpactRepo
+	Users    UserRepo
+}
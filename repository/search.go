@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"backend/models"
+)
+
+// ProductQuery is a full-text and faceted product search
+type ProductQuery struct {
+	Text     string // matched against name, brand, description and ingredients
+	Brand    string
+	Category string
+	Label    string // e.g. "organic", "vegan"
+	EcoBand  string // "a".."e", see EcoBand
+	Sort     string // "relevance" (default with Text), "ecoScore", "-ecoScore", "name", "-name"
+	Limit    int
+	Cursor   string // opaque, from a previous SearchResult.NextCursor
+}
+
+type FacetCount struct {
+	Value string `json:"value" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+type SearchResult struct {
+	Products   []models.Product        `json:"products"`
+	Total      int                     `json:"total"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	Facets     map[string][]FacetCount `json:"facets"`
+}
+
+var ErrBadCursor = errors.New("invalid cursor")
+
+// facetLimit caps how many values are returned per facet
+const facetLimit = 20
+
+// ecoBands are the ecoScore ranges behind the eco_band facet, best first.
+// Unscored products (see models.Product.HasEcoScore) are in no band.
+var ecoBands = []struct {
+	Band     string
+	Min, Max int // inclusive
+}{
+	{"a", 80, 100},
+	{"b", 60, 79},
+	{"c", 40, 59},
+	{"d", 20, 39},
+	{"e", 0, 19},
+}
+
+// EcoBand maps an ecoScore (0-100) to its letter band
+func EcoBand(score int) string {
+	for _, b := range ecoBands {
+		if score >= b.Min {
+			return b.Band
+		}
+	}
+	return "e"
+}
+
+// IsEcoBand reports whether band is one of "a".."e"
+func IsEcoBand(band string) bool {
+	_, _, ok := ecoBandRange(band)
+	return ok
+}
+
+func ecoBandRange(band string) (int, int, bool) {
+	for _, b := range ecoBands {
+		if b.Band == strings.ToLower(band) {
+			return b.Min, b.Max, true
+		}
+	}
+	return 0, 0, false
+}
+
+type searchCursor struct {
+	Offset int `json:"o"`
+}
+
+func encodeCursor(offset int) string {
+	raw, _ := json.Marshal(searchCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrBadCursor
+	}
+	var c searchCursor
+	if json.Unmarshal(raw, &c) != nil || c.Offset < 0 {
+		return 0, ErrBadCursor
+	}
+	return c.Offset, nil
+}
+
+// ---- in-memory search, used by MemoryProducts
+//
+// It follows the Mongo search: a product matches the text when any term is in
+// one of its indexed fields, ignoring case; brand, category and label filters
+// and the name sort are exact and case-sensitive, like the field matches and
+// default collation in Mongo.
+
+// searchTerms splits text into lower-cased words
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// textScore weights matches like the Mongo text index: name 10, brand 5,
+// ingredients 2, description 1. It is 0 when no term matches anywhere.
+func textScore(p models.Product, terms []string) float64 {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{searchTerms(p.Name), 10},
+		{searchTerms(p.Brand), 5},
+		{searchTerms(p.IngredientsText), 2},
+		{searchTerms(p.Description), 1},
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		for _, f := range fields {
+			for _, w := range f.words {
+				if w == term {
+					score += f.weight
+				}
+			}
+		}
+	}
+	return score
+}
+
+func matchesFilters(p models.Product, q ProductQuery) bool {
+	if q.Brand != "" && p.Brand != q.Brand {
+		return false
+	}
+	if q.Category != "" && p.Category != q.Category && !contains(p.Categories, q.Category) {
+		return false
+	}
+	if q.Label != "" && !contains(p.Labels, q.Label) {
+		return false
+	}
+	if q.EcoBand != "" {
+		min, max, _ := ecoBandRange(q.EcoBand)
+		if !p.HasEcoScore() || p.EcoScore < min || p.EcoScore > max {
+			return false
+		}
+	}
+	return true
+}
+
+// searchSlice runs a ProductQuery over products entirely in Go
+func searchSlice(products []models.Product, q ProductQuery) (SearchResult, error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	if q.EcoBand != "" {
+		if _, _, ok := ecoBandRange(q.EcoBand); !ok {
+			return SearchResult{}, errors.New("unknown eco band " + q.EcoBand)
+		}
+	}
+
+	terms := searchTerms(q.Text)
+	type hit struct {
+		p     models.Product
+		score float64
+	}
+	var hits []hit
+	for _, p := range products {
+		score := 0.0
+		if len(terms) > 0 {
+			if score = textScore(p, terms); score == 0 {
+				continue
+			}
+		}
+		if matchesFilters(p, q) {
+			hits = append(hits, hit{p, score})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i].p, hits[j].p
+		switch q.Sort {
+		case "ecoScore":
+			return a.EcoScore < b.EcoScore
+		case "-ecoScore":
+			return a.EcoScore > b.EcoScore
+		case "name":
+			return a.Name < b.Name
+		case "-name":
+			return a.Name > b.Name
+		default:
+			return hits[i].score > hits[j].score
+		}
+	})
+
+	counts := map[string]map[string]int{"brand": {}, "category": {}, "labels": {}, "eco_band": {}}
+	for _, h := range hits {
+		if h.p.Brand != "" {
+			counts["brand"][h.p.Brand]++
+		}
+		if h.p.Category != "" {
+			counts["category"][h.p.Category]++
+		}
+		for _, l := range h.p.Labels {
+			counts["labels"][l]++
+		}
+		if h.p.HasEcoScore() {
+			counts["eco_band"][EcoBand(h.p.EcoScore)]++
+		}
+	}
+
+	res := SearchResult{Total: len(hits), Products: []models.Product{}, Facets: map[string][]FacetCount{}}
+	for name, values := range counts {
+		res.Facets[name] = topFacets(values)
+	}
+	for i := offset; i < len(hits) && len(res.Products) < q.Limit; i++ {
+		res.Products = append(res.Products, hits[i].p)
+	}
+	if next := offset + len(res.Products); next < len(hits) {
+		res.NextCursor = encodeCursor(next)
+	}
+	return res, nil
+}
+
+func topFacets(values map[string]int) []FacetCount {
+	out := make([]FacetCount, 0, len(values))
+	for v, n := range values {
+		out = append(out, FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	if len(out) > facetLimit {
+		out = out[:facetLimit]
+	}
+	return out
+}
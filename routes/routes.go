@@ -3,46 +3,154 @@ package routes
 import (
 	"net/http"
 
+	"backend/auth"
 	"backend/handlers"
+	"backend/utils"
 )
 
-func RegisterRoutes() http.Handler {
-	mux := http.NewServeMux()
+// Access says who may call a route
+type Access int
+
+const (
+	Public     Access = iota // anonymous clients too; a valid token still identifies the user
+	Protected                // requires a valid bearer token
+	Restricted               // requires a valid bearer token and at least Route.Role
+)
+
+// Route is one endpoint. Pattern is a method-qualified ServeMux pattern such as
+// "GET /api/product/{barcode}", so the mux answers other methods with a 405
+// and an Allow header.
+type Route struct {
+	Pattern string
+	Access  Access
+	Role    auth.Role // minimum role for Restricted routes
+	Handler http.HandlerFunc
+}
+
+// Routes lists every endpoint served by h
+func Routes(h *handlers.Handler) []Route {
+	return []Route{
+		{"GET /products", Public, "", h.GetProducts},
+		{"GET /product/barcode", Public, "", h.GetProductByBarcode},
 
-	mux.HandleFunc("/products", handlers.GetProducts)
-	mux.HandleFunc("/product/barcode", handlers.GetProductByBarcode)
+		// API routes expected by the frontend
+		{"GET /api/products", Public, "", h.GetProductsAPI},
+		{"GET /api/products/search", Public, "", h.SearchProducts},
+		{"POST /api/products/add", Restricted, auth.RoleContributor, h.AddProductAPI},
+		// a single product lives under /api/product/, so /api/products/ only has
+		// fixed paths and GET /api/products/add is a 405 rather than a barcode
+		{"GET /api/product/{barcode}", Public, "", h.GetProductAPI},
+		{"GET /api/product/{barcode}/macros", Public, "", h.GetProductMacros},
+		{"GET /api/product/{barcode}/recommendations", Public, "", h.GetProductRecommendations},
+		{"GET /api/product/{barcode}/recipes", Public, "", h.GetProductRecipes},
+		{"GET /api/product/{barcode}/allergens", Public, "", h.GetProductAllergens},
 
-	// API routes expected by the frontend
-	mux.HandleFunc("/api/products", handlers.GetProductsAPI)
-	mux.HandleFunc("/api/product/", handlers.ProductAPIHandler) // will parse path after this prefix and handle subpaths
-	mux.HandleFunc("/api/products/add", handlers.AddProductAPI)
-	mux.HandleFunc("/api/basket", handlers.AnalyzeBasketAPI)
-	mux.HandleFunc("/api/basket/save", handlers.SaveBasketAPI)
-	mux.HandleFunc("/api/baskets", handlers.GetBasketsAPI)
+		{"POST /api/basket", Public, "", h.AnalyzeBasketAPI},
+		{"POST /api/basket/save", Protected, "", h.SaveBasketAPI},
+		{"GET /api/baskets", Protected, "", h.GetBasketsAPI},
+		{"GET /api/basket/{id}/recipes", Protected, "", h.GetBasketRecipes},
+		{"GET /api/recipes", Public, "", h.GetRecipes},
 
-	mux.HandleFunc("/basket", handlers.GetBasket)
-	mux.HandleFunc("/basket/add", handlers.AddToBasket)
+		// the active basket belongs to the signed-in user, else to the X-Session-ID session
+		{"GET /api/basket/active", Public, "", h.GetActiveBasket},
+		{"DELETE /api/basket/active", Public, "", h.ClearActiveBasket},
+		{"POST /api/basket/active/items", Public, "", h.AddActiveBasketItem},
+		{"PUT /api/basket/active/items/{barcode}", Public, "", h.SetActiveBasketItem},
+		{"DELETE /api/basket/active/items/{barcode}", Public, "", h.RemoveActiveBasketItem},
+		{"POST /api/basket/active/checkout", Protected, "", h.CheckoutActiveBasket},
+		{"GET /basket", Public, "", h.GetBasket},
+		{"POST /basket/add", Public, "", h.AddToBasket},
 
-	mux.HandleFunc("/history", handlers.GetHistory)
-	mux.HandleFunc("/history/clear", handlers.ClearHistory)
-	mux.HandleFunc("/history/add", handlers.AddHistory)
+		{"GET /history", Protected, "", h.GetHistory},
+		{"DELETE /history", Protected, "", h.ClearHistory},
+		{"DELETE /history/clear", Protected, "", h.ClearHistory},
+		{"POST /history/add", Protected, "", h.AddHistory},
 
-	// Impact API endpoints
-	mux.HandleFunc("/api/impact/stats", handlers.GetImpactStats)
-	mux.HandleFunc("/api/badges", handlers.GetBadges)
-	mux.HandleFunc("/api/goals", handlers.GoalsHandler)
+		// Accounts
+		{"POST /api/auth/signup", Public, "", h.Signup},
+		{"POST /api/auth/login", Public, "", h.Login},
+		{"GET /api/auth/me", Protected, "", h.GetMe},
+		{"GET /api/auth/me/diet", Protected, "", h.GetDietaryProfile},
+		{"PUT /api/auth/me/diet", Protected, "", h.SetDietaryProfile},
+		{"POST /api/admin/users/role", Restricted, auth.RoleAdmin, h.SetUserRole},
+
+		// Impact API endpoints
+		{"GET /api/impact/stats", Protected, "", h.GetImpactStats},
+		{"GET /api/badges", Protected, "", h.GetBadges},
+		{"GET /api/goals", Protected, "", h.GetGoals},
+		{"POST /api/goals", Protected, "", h.CreateGoal},
+		{"GET /api/goals/{id}", Protected, "", h.GetGoal},
+		{"PATCH /api/goals/{id}", Protected, "", h.UpdateGoal},
+		{"DELETE /api/goals/{id}", Protected, "", h.DeleteGoal},
+		{"POST /api/goals/{id}/complete", Protected, "", h.CompleteGoal},
+	}
+}
+
+// RegisterRoutes mounts every endpoint on h behind the auth middleware and CORS wrapper
+func RegisterRoutes(h *handlers.Handler) http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range Routes(h) {
+		switch rt.Access {
+		case Public:
+			mux.Handle(rt.Pattern, auth.Public(rt.Handler))
+		case Protected:
+			mux.Handle(rt.Pattern, auth.Protected(h.UserRole, rt.Handler))
+		case Restricted:
+			mux.Handle(rt.Pattern, auth.Protected(h.UserRole, auth.RequireRole(rt.Role, rt.Handler)))
+		}
+	}
 
 	// CORS wrapper to allow frontend dev server access
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handlers.SessionHeader+", "+handlers.IdempotencyKeyHeader)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, X-Next-Cursor, "+utils.RequestIDHeader+", "+handlers.SessionHeader+", "+handlers.ReplayedHeader)
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if _, pattern := mux.Handler(r); pattern == "" {
+			serveMuxError(mux, w, r)
+			return
+		}
 		mux.ServeHTTP(w, r)
 	})
 
-	return handler
+	return utils.WithRequestID(handler)
+}
+
+// serveMuxError answers a request no route matched. The mux's own plain-text
+// 404 and 405 are replaced by the JSON error envelope; its Allow header and
+// redirects to canonical paths are kept.
+func serveMuxError(mux *http.ServeMux, w http.ResponseWriter, r *http.Request) {
+	rec := &muxErrorWriter{ResponseWriter: w}
+	mux.ServeHTTP(rec, r)
+	switch rec.status {
+	case http.StatusNotFound:
+		utils.Error(w, utils.NotFound("No route for "+r.URL.Path))
+	case http.StatusMethodNotAllowed:
+		utils.Error(w, utils.NewError(http.StatusMethodNotAllowed, utils.CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path))
+	}
+}
+
+// muxErrorWriter swallows a 404 or 405 written by the mux and passes anything else through
+type muxErrorWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (m *muxErrorWriter) WriteHeader(status int) {
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		m.status = status
+		return
+	}
+	m.ResponseWriter.WriteHeader(status)
+}
+
+func (m *muxErrorWriter) Write(b []byte) (int, error) {
+	if m.status != 0 {
+		return len(b), nil
+	}
+	return m.ResponseWriter.Write(b)
 }
@@ -0,0 +1,138 @@
+package routes_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"backend/auth"
+	"backend/handlers"
+	"backend/models"
+	"backend/repository"
+	"backend/routes"
+)
+
+// pathFor fills a route pattern's wildcards with plausible values
+var pathFor = strings.NewReplacer("{barcode}", "04006381333931", "{id}", "64b000000000000000000001")
+
+func TestRoutes(t *testing.T) {
+	repos := repository.NewMemory()
+	h := handlers.New(repos)
+	srv := httptest.NewServer(routes.RegisterRoutes(h))
+	t.Cleanup(srv.Close)
+
+	admin := &models.User{Email: "admin@example.com", Role: string(auth.RoleAdmin)}
+	if err := repos.Users.Create(context.Background(), admin); err != nil {
+		t.Fatal(err)
+	}
+	token := func(userID string) string {
+		tok, err := auth.IssueToken(userID, auth.RoleViewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	viewerToken, adminToken := token("64b0000000000000000000ff"), token(admin.ID.Hex())
+
+	do := func(method, path, token string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return res
+	}
+
+	all := routes.Routes(h)
+	methods := map[string][]string{} // path -> methods routed on it
+	for _, rt := range all {
+		method, path, _ := strings.Cut(rt.Pattern, " ")
+		methods[path] = append(methods[path], method)
+	}
+
+	for _, rt := range all {
+		t.Run(rt.Pattern, func(t *testing.T) {
+			method, pattern, _ := strings.Cut(rt.Pattern, " ")
+			path := pathFor.Replace(pattern)
+
+			anon := do(method, path, "").StatusCode
+			switch rt.Access {
+			case routes.Public:
+				if anon == http.StatusUnauthorized || anon == http.StatusForbidden || anon == http.StatusMethodNotAllowed {
+					t.Errorf("anonymous %s = %d", method, anon)
+				}
+			case routes.Protected, routes.Restricted:
+				if anon != http.StatusUnauthorized {
+					t.Errorf("anonymous %s = %d, want 401", method, anon)
+				}
+				if got := do(method, path, "not-a-token").StatusCode; got != http.StatusUnauthorized {
+					t.Errorf("%s with a bad token = %d, want 401", method, got)
+				}
+			}
+
+			viewer := do(method, path, viewerToken).StatusCode
+			if rt.Access == routes.Restricted && !auth.RoleViewer.AtLeast(rt.Role) {
+				if viewer != http.StatusForbidden {
+					t.Errorf("viewer %s = %d, want 403", method, viewer)
+				}
+				if got := do(method, path, adminToken).StatusCode; got == http.StatusUnauthorized || got == http.StatusForbidden || got == http.StatusMethodNotAllowed {
+					t.Errorf("admin %s = %d", method, got)
+				}
+			} else if viewer == http.StatusUnauthorized || viewer == http.StatusForbidden || viewer == http.StatusMethodNotAllowed {
+				t.Errorf("viewer %s = %d", method, viewer)
+			}
+
+			var other string
+			for _, m := range []string{"PUT", "PATCH", "DELETE", "POST", "GET"} {
+				if !slices.Contains(methods[pattern], m) {
+					other = m
+					break
+				}
+			}
+			res := do(other, path, adminToken)
+			if res.StatusCode != http.StatusMethodNotAllowed {
+				t.Fatalf("%s = %d, want 405", other, res.StatusCode)
+			}
+			if allow := res.Header.Get("Allow"); !strings.Contains(allow, method) {
+				t.Errorf("%s Allow = %q, want %s in it", other, allow, method)
+			}
+		})
+	}
+}
+
+// TestProductsSubpaths guards the fixed paths under /api/products/ against
+// being read as a barcode by another method's route
+func TestProductsSubpaths(t *testing.T) {
+	srv := httptest.NewServer(routes.RegisterRoutes(handlers.New(repository.NewMemory())))
+	t.Cleanup(srv.Close)
+
+	for path, allow := range map[string]string{"/api/products/add": "POST", "/api/products/search": "GET"} {
+		method := "GET"
+		if allow == "GET" {
+			method = "POST"
+		}
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusMethodNotAllowed || !strings.Contains(res.Header.Get("Allow"), allow) {
+			t.Errorf("%s %s = %d, Allow %q; want 405 allowing %s", method, path, res.StatusCode, res.Header.Get("Allow"), allow)
+		}
+	}
+}
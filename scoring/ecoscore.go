@@ -0,0 +1,223 @@
+package scoring
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"backend/models"
+)
+
+// CategoryFactor returns the default model's emission factor (kg CO2e per kg)
+// for a product's categories. ok is false when no category has a factor.
+func CategoryFactor(category string, categories []string) (key string, factor float64, ok bool) {
+	est, ok := Default().Estimate(Item{Category: category, Categories: categories, NetWeightKg: 1, Units: 1})
+	if !ok || est.Model != factorModelName {
+		return "", 0, false
+	}
+	return est.FactorCategory, est.Factor, true
+}
+
+// EcoBaseline maps an emission factor to the 0-100 life-cycle baseline of the
+// Eco-Score: 100 - 15·log2(1 + kg CO2e/kg), so beef (60) scores about 11 and vegetables (0.5) about 91
+func EcoBaseline(factor float64) int {
+	return clamp(int(math.Round(100-15*math.Log2(1+factor))), 0, 100)
+}
+
+// Bonus and malus caps, in Eco-Score points
+const (
+	maxLabelBonus      = 20
+	unknownOriginMalus = 5
+	unknownPackMalus   = 5
+	farOriginMalus     = 10
+	palmOilMalus       = 10
+	certifiedPalmMalus = 5
+)
+
+// labelBonus are production-system labels and what each is worth
+var labelBonus = map[string]int{
+	"organic":                 15,
+	"eu-organic":              15,
+	"bio":                     15,
+	"demeter":                 20,
+	"fair-trade":              10,
+	"fairtrade-international": 10,
+	"rainforest-alliance":     10,
+	"msc-sustainable-seafood": 10,
+	"asc":                     10,
+	"label-rouge":             10,
+}
+
+// packagingMalus is matched against each packaging entry by substring, first match wins.
+// Entries often describe the same component ("can", "aluminium"), so only the worst counts.
+var packagingMalus = []struct {
+	material string
+	malus    int
+}{
+	{"polystyrene", 10},
+	{"plastic", 8},
+	{"pet", 8},
+	{"tetra", 6},
+	{"composite", 6},
+	{"aluminium", 5},
+	{"can", 5},
+	{"metal", 5},
+	{"steel", 5},
+	{"glass", 4},
+	{"cardboard", 2},
+	{"paper", 2},
+}
+
+// nearOrigins are origins that count as short-haul; any other named origin is long-haul
+var nearOrigins = map[string]bool{
+	"european-union": true, "eu": true, "europe": true,
+	"austria": true, "belgium": true, "bulgaria": true, "croatia": true, "cyprus": true, "czech-republic": true,
+	"denmark": true, "estonia": true, "finland": true, "france": true, "germany": true, "greece": true,
+	"hungary": true, "ireland": true, "italy": true, "latvia": true, "lithuania": true, "luxembourg": true,
+	"malta": true, "netherlands": true, "poland": true, "portugal": true, "romania": true, "slovakia": true,
+	"slovenia": true, "spain": true, "sweden": true, "united-kingdom": true, "switzerland": true, "norway": true,
+}
+
+// sustainablePalmLabels soften the palm oil malus
+var sustainablePalmLabels = []string{"sustainable-palm-oil", "roundtable-on-sustainable-palm-oil", "rspo"}
+
+// EcoScore computes an Eco-Score from the life-cycle baseline of the product's
+// category plus bonuses for labels and maluses for origin, packaging and
+// threatened species. ok is false when the category has no emission factor.
+func EcoScore(p models.Product) (models.EcoScoreBreakdown, bool) {
+	es := models.EcoScoreBreakdown{Components: []models.ScoreComponent{}}
+	key, factor, ok := CategoryFactor(p.Category, p.Categories)
+	if !ok {
+		es.Missing = []string{"category"}
+		return es, false
+	}
+	es.Category = key
+	es.Factor = factor
+	es.Baseline = EcoBaseline(factor)
+
+	adjust := func(c models.ScoreComponent) {
+		es.Adjustment += c.Points
+		es.Components = append(es.Components, c)
+	}
+
+	// production labels
+	bonus, matched := 0, []string{}
+	for _, l := range p.Labels {
+		if b, ok := labelBonus[NormalizeCategory(l)]; ok {
+			bonus += b
+			matched = append(matched, NormalizeCategory(l))
+		}
+	}
+	adjust(models.ScoreComponent{Name: "labels", Points: min(bonus, maxLabelBonus), Max: maxLabelBonus, Note: strings.Join(matched, ", ")})
+
+	// origin of ingredients
+	origin := models.ScoreComponent{Name: "origins"}
+	switch {
+	case len(p.Origins) == 0:
+		origin.Points, origin.Note = -unknownOriginMalus, "unknown"
+	default:
+		for _, o := range p.Origins {
+			if !nearOrigins[NormalizeCategory(o)] {
+				origin.Points, origin.Note = -farOriginMalus, "long-haul: "+NormalizeCategory(o)
+				break
+			}
+		}
+	}
+	adjust(origin)
+
+	// packaging
+	packaging := models.ScoreComponent{Name: "packaging"}
+	if len(p.Packaging) == 0 {
+		packaging.Points, packaging.Note = -unknownPackMalus, "unknown"
+	}
+	for _, pkg := range p.Packaging {
+		pkg = NormalizeCategory(pkg)
+		for _, m := range packagingMalus {
+			if strings.Contains(pkg, m.material) {
+				if -m.malus < packaging.Points {
+					packaging.Points, packaging.Note = -m.malus, m.material
+				}
+				break
+			}
+		}
+	}
+	adjust(packaging)
+
+	// threatened species
+	species := models.ScoreComponent{Name: "threatened_species"}
+	if HasPalmOil(p) {
+		species.Points, species.Note = -palmOilMalus, "palm oil"
+		for _, l := range p.Labels {
+			for _, s := range sustainablePalmLabels {
+				if NormalizeCategory(l) == s {
+					species.Points, species.Note = -certifiedPalmMalus, "certified sustainable palm oil"
+				}
+			}
+		}
+	}
+	adjust(species)
+
+	es.Score = clamp(es.Baseline+es.Adjustment, 0, 100)
+	es.Grade = EcoGrade(es.Score)
+	return es, true
+}
+
+// EcoGrade maps a 0-100 Eco-Score to its letter; the bands match the eco_band search facet
+func EcoGrade(score int) string {
+	switch {
+	case score >= 80:
+		return "a"
+	case score >= 60:
+		return "b"
+	case score >= 40:
+		return "c"
+	case score >= 20:
+		return "d"
+	}
+	return "e"
+}
+
+// palmOilRe matches palm oil and its fractions as whole words in English, French
+// and German, so "palm oil", "huile de palme" and "Palmöl" count but
+// "ascorbyl palmitate" or "hearts of palm" do not
+var palmOilRe = regexp.MustCompile(`(?i)\bpalm(?:[\s-]+(?:oil|fat|kernel|olein|stearin)|e|iste|öl|oel|fett|kern\w*|olein|stearin)\b`)
+
+// HasPalmOil reports whether the ingredients mention palm oil or palm fat
+func HasPalmOil(p models.Product) bool {
+	if palmOilRe.MatchString(p.IngredientsText) {
+		return true
+	}
+	for _, i := range p.Ingredients {
+		if palmOilRe.MatchString(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// ScoreProduct recomputes the product's Nutri-Score and Eco-Score in place.
+// When the category has no emission factor the Eco-Score cannot be computed: the
+// score the client or Open Food Facts supplied is kept, with its grade made to
+// match, and the breakdown records what was missing. A product without one stays
+// unscored (see models.Product.HasEcoScore) rather than scoring 0.
+func ScoreProduct(p *models.Product) {
+	ns, _ := NutriScore(p.Nutrition, append([]string{p.Category}, p.Categories...))
+	p.NutriScore = &ns
+
+	es, ok := EcoScore(*p)
+	p.EcoScoreBreakdown = &es
+	switch {
+	case ok:
+		p.EcoScore = es.Score
+		p.EcoscoreGrade = es.Grade
+	case p.EcoScore > 0:
+		p.EcoScore = clamp(p.EcoScore, 0, 100)
+		p.EcoscoreGrade = EcoGrade(p.EcoScore)
+	case !models.IsEcoGrade(p.EcoscoreGrade):
+		p.EcoscoreGrade = ""
+	}
+}
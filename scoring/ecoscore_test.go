@@ -0,0 +1,66 @@
+package scoring
+
+import (
+	"testing"
+
+	"backend/models"
+)
+
+func TestHasPalmOil(t *testing.T) {
+	for text, want := range map[string]bool{
+		"sugar, palm oil, hazelnuts":       true,
+		"palm kernel fat":                  true,
+		"palm-fat":                         true,
+		"sucre, huile de palme, noisettes": true,
+		"Zucker, Palmöl, Haselnüsse":       true,
+		"Palmfett":                         true,
+		"antioxidant: ascorbyl palmitate":  false,
+		"hearts of palm":                   false,
+		"":                                 false,
+	} {
+		if got := HasPalmOil(models.Product{IngredientsText: text}); got != want {
+			t.Errorf("HasPalmOil(%q) = %v, want %v", text, got, want)
+		}
+	}
+	if !HasPalmOil(models.Product{Ingredients: []string{"Palm Oil"}}) {
+		t.Error("HasPalmOil missed an ingredient list entry")
+	}
+}
+
+func TestScoreProductUncomputableEcoScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		score     int
+		grade     string
+		wantScore int
+		wantGrade string
+	}{
+		{"client score kept", 72, "", 72, "b"},
+		{"grade follows the kept score", 72, "a", 72, "b"},
+		{"OFF score of 0", 0, "e", 0, "e"},
+		{"OFF could not score it", 0, "unknown", 0, ""},
+		{"no score at all", 0, "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := models.Product{Name: "Mystery", EcoScore: tt.score, EcoscoreGrade: tt.grade}
+			ScoreProduct(&p)
+			if p.EcoScore != tt.wantScore || p.EcoscoreGrade != tt.wantGrade {
+				t.Errorf("ecoScore %d (%q), want %d (%q)", p.EcoScore, p.EcoscoreGrade, tt.wantScore, tt.wantGrade)
+			}
+			if p.HasEcoScore() != (tt.wantGrade != "") {
+				t.Errorf("HasEcoScore = %v", p.HasEcoScore())
+			}
+			if p.EcoScoreBreakdown == nil || len(p.EcoScoreBreakdown.Missing) == 0 || p.EcoScoreBreakdown.Missing[0] != "category" {
+				t.Errorf("breakdown = %+v, want category missing", p.EcoScoreBreakdown)
+			}
+		})
+	}
+
+	// a computed score replaces whatever was sent
+	p := models.Product{Category: "yogurts", EcoScore: 3, EcoscoreGrade: "e"}
+	ScoreProduct(&p)
+	if p.EcoScoreBreakdown.Grade == "" || p.EcoScore != p.EcoScoreBreakdown.Score || p.EcoscoreGrade != p.EcoScoreBreakdown.Grade {
+		t.Errorf("computed ecoScore %d (%q), breakdown %+v", p.EcoScore, p.EcoscoreGrade, p.EcoScoreBreakdown)
+	}
+}
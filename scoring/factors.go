@@ -0,0 +1,146 @@
+package scoring
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//go:embed emission_factors.json
+var builtinFactorsJSON []byte
+
+// FactorTable maps a normalized category to kg CO2e per kg of product
+type FactorTable map[string]float64
+
+// FactorModel multiplies a per-category emission factor by the item's net weight.
+// It does not apply when the category is unknown or the weight is missing.
+type FactorModel struct {
+	table FactorTable
+}
+
+func NewFactorModel(table FactorTable) *FactorModel {
+	return &FactorModel{table: table}
+}
+
+// factorModelName is the Estimate.Model of category factor estimates
+const factorModelName = "category_factor"
+
+func (m *FactorModel) Name() string { return factorModelName }
+
+func (m *FactorModel) Estimate(item Item) (Estimate, bool) {
+	if item.NetWeightKg <= 0 {
+		return Estimate{}, false
+	}
+	key, factor, ok := m.lookup(item)
+	if !ok {
+		return Estimate{}, false
+	}
+	return Estimate{
+		Model:          m.Name(),
+		CarbonKg:       factor * item.NetWeightKg * units(item),
+		Factor:         factor,
+		FactorUnit:     "kgCO2e/kg",
+		FactorCategory: key,
+	}, true
+}
+
+// lookup tries the item's category, then its taxonomy from most to least specific
+func (m *FactorModel) lookup(item Item) (string, float64, bool) {
+	candidates := []string{item.Category}
+	for i := len(item.Categories) - 1; i >= 0; i-- {
+		candidates = append(candidates, item.Categories[i])
+	}
+	for _, c := range candidates {
+		key := NormalizeCategory(c)
+		if factor, ok := m.table[key]; ok {
+			return key, factor, true
+		}
+	}
+	return "", 0, false
+}
+
+// NormalizeCategory lower-cases a category and strips an Open Food Facts language prefix ("en:")
+func NormalizeCategory(c string) string {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if i := strings.Index(c, ":"); i == 2 {
+		c = c[i+1:]
+	}
+	return strings.ReplaceAll(c, " ", "-")
+}
+
+// LoadFactorTable reads a factor table from a .json object ({"category": factor})
+// or a .csv file with "category,factor" rows (a header row is allowed)
+func LoadFactorTable(path string) (FactorTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseFactorJSON(f)
+	case ".csv":
+		return parseFactorCSV(f)
+	default:
+		return nil, fmt.Errorf("emission factors: unsupported file type %q", filepath.Ext(path))
+	}
+}
+
+func builtinFactors() FactorTable {
+	table, err := parseFactorJSON(strings.NewReader(string(builtinFactorsJSON)))
+	if err != nil {
+		panic("scoring: bad embedded emission_factors.json: " + err.Error())
+	}
+	return table
+}
+
+func parseFactorJSON(r io.Reader) (FactorTable, error) {
+	var raw map[string]float64
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	table := FactorTable{}
+	for k, v := range raw {
+		if v < 0 {
+			return nil, fmt.Errorf("emission factors: negative factor for %q", k)
+		}
+		table[NormalizeCategory(k)] = v
+	}
+	return table, nil
+}
+
+func parseFactorCSV(r io.Reader) (FactorTable, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	table := FactorTable{}
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("emission factors: line %d: expected category,factor", i+1)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("emission factors: line %d: %v", i+1, err)
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("emission factors: line %d: negative factor", i+1)
+		}
+		table[NormalizeCategory(row[0])] = v
+	}
+	if len(table) == 0 {
+		return nil, errors.New("emission factors: no rows")
+	}
+	return table, nil
+}
@@ -0,0 +1,22 @@
+package scoring
+
+// LinearFactor is the kg CO2e charged per eco-score point below 100
+const LinearFactor = 0.05
+
+// Linear is the original eco-score based formula: (100 - ecoScore) * 0.05 per package.
+// It applies to every scored item, so it is used as the last model in a chain.
+type Linear struct{}
+
+func (Linear) Name() string { return "linear" }
+
+func (Linear) Estimate(item Item) (Estimate, bool) {
+	if item.Unscored {
+		return Estimate{}, false
+	}
+	return Estimate{
+		Model:      "linear",
+		CarbonKg:   (100 - float64(item.EcoScore)) * LinearFactor * units(item),
+		Factor:     LinearFactor,
+		FactorUnit: "kgCO2e/eco-point",
+	}, true
+}
@@ -0,0 +1,89 @@
+package scoring
+
+import (
+	"strings"
+	"sync"
+)
+
+// Item is what a carbon model needs to know about one basket line
+type Item struct {
+	Barcode     string
+	Category    string
+	Categories  []string // broader taxonomy, general to specific; tried when Category has no factor
+	EcoScore    int
+	Unscored    bool    // the product has no Eco-Score, so EcoScore is not a basis for an estimate
+	NetWeightKg float64 // 0 when unknown
+	Units       int     // number of packages, 0 is treated as 1
+}
+
+// Estimate is a model's carbon figure for an item and how it was obtained
+type Estimate struct {
+	Model          string  `json:"model"`
+	CarbonKg       float64 `json:"carbon"`
+	Factor         float64 `json:"factor"`
+	FactorUnit     string  `json:"factor_unit"`
+	FactorCategory string  `json:"factor_category,omitempty"`
+}
+
+// CarbonModel estimates the footprint of a basket item.
+// ok is false when the model has no data for the item and the next model should be tried.
+type CarbonModel interface {
+	Name() string
+	Estimate(item Item) (est Estimate, ok bool)
+}
+
+// Chain tries each model in order and returns the first estimate that applies
+type Chain []CarbonModel
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, m := range c {
+		names[i] = m.Name()
+	}
+	return strings.Join(names, ">")
+}
+
+func (c Chain) Estimate(item Item) (Estimate, bool) {
+	for _, m := range c {
+		if est, ok := m.Estimate(item); ok {
+			return est, true
+		}
+	}
+	return Estimate{}, false
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultModel CarbonModel = Chain{NewFactorModel(builtinFactors()), Linear{}}
+)
+
+// Default returns the model used by the basket handlers
+func Default() CarbonModel {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultModel
+}
+
+// SetDefault replaces the model used by the basket handlers
+func SetDefault(m CarbonModel) {
+	defaultMu.Lock()
+	defaultModel = m
+	defaultMu.Unlock()
+}
+
+// EstimateItem runs the default model, falling back to the linear formula if nothing applies.
+// An unscored item that no model covers gets an empty estimate.
+func EstimateItem(item Item) Estimate {
+	if est, ok := Default().Estimate(item); ok {
+		return est
+	}
+	est, _ := Linear{}.Estimate(item)
+	return est
+}
+
+func units(item Item) float64 {
+	if item.Units <= 0 {
+		return 1
+	}
+	return float64(item.Units)
+}
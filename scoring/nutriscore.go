@@ -0,0 +1,218 @@
+package scoring
+
+import (
+	"math"
+
+	"backend/models"
+)
+
+// Nutri-Score point thresholds (2017 algorithm). A value strictly above the
+// i-th threshold earns i+1 points.
+var (
+	energyKJ       = []float64{335, 670, 1005, 1340, 1675, 2010, 2345, 2680, 3015, 3350}
+	sugarsG        = []float64{4.5, 9, 13.5, 18, 22.5, 27, 31, 36, 40, 45}
+	saturatedFatG  = []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	sodiumMg       = []float64{90, 180, 270, 360, 450, 540, 630, 720, 810, 900}
+	fiberG         = []float64{0.9, 1.9, 2.8, 3.7, 4.7}
+	proteinsG      = []float64{1.6, 3.2, 4.8, 6.4, 8.0}
+	beverageKJ     = []float64{0, 30, 60, 90, 120, 150, 180, 210, 240, 270}
+	beverageSugars = []float64{0, 1.5, 3, 4.5, 6, 7.5, 9, 10.5, 12, 13.5}
+	saturatedRatio = []float64{10, 16, 22, 28, 34, 40, 46, 52, 58, 64} // % of fat, "fat" profile; the lower bounds are inclusive
+)
+
+// Nutri-Score profiles, chosen from the product's categories
+const (
+	ProfileGeneral  = "general"
+	ProfileBeverage = "beverage"
+	ProfileWater    = "water"
+	ProfileCheese   = "cheese"
+	ProfileFat      = "fat"
+)
+
+var profileCategories = []struct {
+	profile    string
+	categories []string
+}{
+	{ProfileWater, []string{"waters", "mineral-waters", "spring-waters"}},
+	{ProfileCheese, []string{"cheeses"}},
+	{ProfileFat, []string{"fats", "vegetable-oils", "olive-oils", "butters", "margarines"}},
+	{ProfileBeverage, []string{"beverages", "sodas", "juices", "fruit-juices", "soft-drinks", "carbonated-drinks", "teas", "coffees"}},
+}
+
+// nonBeverages are drinks the algorithm scores as general foods
+var nonBeverages = []string{"milks", "dairies", "plant-based-milks", "dairy-drinks", "yogurt-drinks"}
+
+// NutriProfile picks the Nutri-Score profile for a product's categories
+func NutriProfile(categories []string) string {
+	has := func(list []string) bool {
+		for _, c := range categories {
+			c = NormalizeCategory(c)
+			for _, want := range list {
+				if c == want {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, pc := range profileCategories {
+		if pc.profile == ProfileBeverage && has(nonBeverages) {
+			continue
+		}
+		if has(pc.categories) {
+			return pc.profile
+		}
+	}
+	return ProfileGeneral
+}
+
+// NutriScore grades per-100g nutrition facts with the 2017 Nutri-Score algorithm.
+// ok is false when a required nutrient is unknown; those are listed in Missing
+// and Grade is empty. Unknown fibre and fruit/vegetable content count as 0 points.
+func NutriScore(n *models.Nutrition, categories []string) (models.NutriScore, bool) {
+	profile := NutriProfile(categories)
+	ns := models.NutriScore{Profile: profile, Components: []models.ScoreComponent{}}
+	if profile == ProfileWater {
+		ns.Grade = "a"
+		return ns, true
+	}
+	if n == nil {
+		n = &models.Nutrition{}
+	}
+
+	require := func(name string, v *float64) {
+		if v == nil {
+			ns.Missing = append(ns.Missing, name)
+		}
+	}
+	require("energy", n.EnergyKJ)
+	require("sugars", n.Sugars)
+	require("saturated_fat", n.SaturatedFat)
+	require("sodium", n.Sodium)
+	require("proteins", n.Proteins)
+	if profile == ProfileFat {
+		require("fat", n.Fat)
+	}
+	if len(ns.Missing) > 0 {
+		return ns, false
+	}
+
+	add := func(name string, value *float64, unit string, max int, negative bool, score func(float64) int) int {
+		c := models.ScoreComponent{Name: name, Value: value, Unit: unit, Max: max}
+		if value == nil {
+			c.Note = "unknown, counted as 0"
+		} else {
+			c.Points = score(*value)
+		}
+		if negative {
+			ns.Negative += c.Points
+		} else {
+			ns.Positive += c.Points
+		}
+		ns.Components = append(ns.Components, c)
+		return c.Points
+	}
+	over := func(thresholds []float64) func(float64) int {
+		return func(v float64) int { return points(v, thresholds) }
+	}
+
+	// negative points
+	sodium := math.Round(*n.Sodium*1e6) / 1000
+	if profile == ProfileBeverage {
+		add("energy", n.EnergyKJ, "kJ", 10, true, over(beverageKJ))
+		add("sugars", n.Sugars, "g", 10, true, over(beverageSugars))
+	} else {
+		add("energy", n.EnergyKJ, "kJ", 10, true, over(energyKJ))
+		add("sugars", n.Sugars, "g", 10, true, over(sugarsG))
+	}
+	if profile == ProfileFat {
+		ratio := 0.0
+		if *n.Fat > 0 {
+			ratio = *n.SaturatedFat / *n.Fat * 100
+		}
+		add("saturated_fat_ratio", &ratio, "%", 10, true, ratioPoints)
+	} else {
+		add("saturated_fat", n.SaturatedFat, "g", 10, true, over(saturatedFatG))
+	}
+	add("sodium", &sodium, "mg", 10, true, over(sodiumMg))
+
+	// positive points
+	fvnMax := 5
+	fvnScore := func(pct float64) int { return fvnPoints(pct, 1, 2, 5) }
+	if profile == ProfileBeverage {
+		fvnMax = 10
+		fvnScore = func(pct float64) int { return fvnPoints(pct, 2, 4, 10) }
+	}
+	fvn := add("fruits_vegetables_nuts", n.FruitsVegNuts, "%", fvnMax, false, fvnScore)
+	add("fiber", n.Fiber, "g", 5, false, over(fiberG))
+	proteins := add("proteins", n.Proteins, "g", 5, false, over(proteinsG))
+
+	// proteins only count when the negatives are low, the fruit share is at its maximum, or for cheese
+	if ns.Negative >= 11 && fvn < fvnMax && profile != ProfileCheese {
+		ns.Positive -= proteins
+		last := &ns.Components[len(ns.Components)-1]
+		last.Points = 0
+		last.Note = "not counted: negative points are 11 or more"
+	}
+
+	ns.Score = ns.Negative - ns.Positive
+	ns.Grade = nutriGrade(ns.Score, profile)
+	return ns, true
+}
+
+func points(v float64, thresholds []float64) int {
+	p := 0
+	for _, t := range thresholds {
+		if v > t {
+			p++
+		}
+	}
+	return p
+}
+
+func ratioPoints(ratio float64) int {
+	p := 0
+	for _, t := range saturatedRatio {
+		if ratio >= t {
+			p++
+		}
+	}
+	return p
+}
+
+func fvnPoints(pct float64, over40, over60, over80 int) int {
+	switch {
+	case pct > 80:
+		return over80
+	case pct > 60:
+		return over60
+	case pct > 40:
+		return over40
+	}
+	return 0
+}
+
+func nutriGrade(score int, profile string) string {
+	if profile == ProfileBeverage {
+		switch {
+		case score <= 1:
+			return "b"
+		case score <= 5:
+			return "c"
+		case score <= 9:
+			return "d"
+		}
+		return "e"
+	}
+	switch {
+	case score <= -1:
+		return "a"
+	case score <= 2:
+		return "b"
+	case score <= 10:
+		return "c"
+	case score <= 18:
+		return "d"
+	}
+	return "e"
+}
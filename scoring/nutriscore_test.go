@@ -0,0 +1,131 @@
+package scoring
+
+import (
+	"reflect"
+	"testing"
+
+	"backend/models"
+)
+
+// per100 builds nutrition facts; sodium is in grams as on labels
+func per100(kj, sugars, satFat, sodium, proteins, fiber float64) *models.Nutrition {
+	f := func(v float64) *float64 { return &v }
+	return &models.Nutrition{EnergyKJ: f(kj), Sugars: f(sugars), SaturatedFat: f(satFat), Sodium: f(sodium), Proteins: f(proteins), Fiber: f(fiber)}
+}
+
+func with(n *models.Nutrition, edit func(n *models.Nutrition)) *models.Nutrition {
+	c := *n
+	edit(&c)
+	return &c
+}
+
+func TestNutriScore(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	// negative points 11: energy 4, sugars 2, saturated fat 3, sodium 2; positive: fibre 3, proteins 5
+	borderline := per100(1400, 10, 3.5, 0.2, 10, 3)
+
+	tests := []struct {
+		name       string
+		n          *models.Nutrition
+		categories []string
+		profile    string
+		negative   int
+		positive   int
+		grade      string
+	}{
+		// energy 6, sugars 10, saturated fat 10; proteins 3 dropped as negatives are 11+
+		{"hazelnut spread", per100(2252, 56.3, 10.6, 0.0428, 6.3, 0), []string{"spreads"}, ProfileGeneral, 26, 0, "e"},
+		{"plain yogurt", per100(250, 4.0, 2.3, 0.05, 4.0, 0), []string{"yogurts"}, ProfileGeneral, 2, 2, "b"},
+		{"negatives 11 drop proteins", borderline, nil, ProfileGeneral, 11, 3, "c"},
+		{"negatives 10 keep proteins", with(borderline, func(n *models.Nutrition) { n.Sodium = f(0.17) }), nil, ProfileGeneral, 10, 8, "b"},
+		{"full fruit share keeps proteins", with(borderline, func(n *models.Nutrition) { n.FruitsVegNuts = f(85) }), nil, ProfileGeneral, 11, 13, "a"},
+		{"cheese keeps proteins", per100(1600, 0, 18, 0.3, 28, 0), []string{"dairies", "cheeses"}, ProfileCheese, 17, 5, "d"},
+		{"same facts, not a cheese", per100(1600, 0, 18, 0.3, 28, 0), []string{"dairies"}, ProfileGeneral, 17, 0, "d"},
+		// energy 10, saturated fat 14% of fat 1
+		{"olive oil", with(per100(3378, 0, 14, 0, 0, 0), func(n *models.Nutrition) { n.Fat = f(100) }), []string{"fats", "olive-oils"}, ProfileFat, 11, 0, "d"},
+		// energy 8, saturated fat 66% of fat 10
+		{"butter", with(per100(3000, 0.5, 54, 0.01, 0.7, 0), func(n *models.Nutrition) { n.Fat = f(82) }), []string{"butters"}, ProfileFat, 18, 0, "d"},
+		// energy 6 (180 kJ is not above the 180 threshold), sugars 8
+		{"cola", per100(180, 10.6, 0, 0, 0, 0), []string{"beverages", "sodas"}, ProfileBeverage, 14, 0, "e"},
+		// energy 7, sugars 6; fruit 10
+		{"orange juice", with(per100(190, 8.6, 0, 0, 0.7, 0.2), func(n *models.Nutrition) { n.FruitsVegNuts = f(100) }), []string{"beverages", "fruit-juices"}, ProfileBeverage, 13, 10, "c"},
+		{"milk is not a beverage", per100(270, 4.8, 1.1, 0.04, 3.3, 0), []string{"beverages", "milks"}, ProfileGeneral, 2, 2, "b"},
+		{"water", nil, []string{"beverages", "waters"}, ProfileWater, 0, 0, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, ok := NutriScore(tt.n, tt.categories)
+			if !ok {
+				t.Fatalf("NutriScore not computed, missing %v", ns.Missing)
+			}
+			if ns.Profile != tt.profile || ns.Negative != tt.negative || ns.Positive != tt.positive ||
+				ns.Score != tt.negative-tt.positive || ns.Grade != tt.grade {
+				t.Errorf("NutriScore = %s: -%d +%d = %d (%s), want %s: -%d +%d (%s)\n%+v",
+					ns.Profile, ns.Negative, ns.Positive, ns.Score, ns.Grade, tt.profile, tt.negative, tt.positive, tt.grade, ns.Components)
+			}
+		})
+	}
+}
+
+func TestNutriScoreMissing(t *testing.T) {
+	ns, ok := NutriScore(nil, []string{"yogurts"})
+	if want := []string{"energy", "sugars", "saturated_fat", "sodium", "proteins"}; ok || ns.Grade != "" || !reflect.DeepEqual(ns.Missing, want) {
+		t.Errorf("NutriScore(nil) = %v, %+v; want missing %v", ok, ns, want)
+	}
+	if ns, ok := NutriScore(per100(3000, 0, 50, 0, 0, 0), []string{"butters"}); ok || !reflect.DeepEqual(ns.Missing, []string{"fat"}) {
+		t.Errorf("fat profile without fat = %v, missing %v", ok, ns.Missing)
+	}
+	// fibre and fruit are optional
+	n := per100(250, 4, 2.3, 0.05, 4, 0)
+	n.Fiber = nil
+	if _, ok := NutriScore(n, nil); !ok {
+		t.Error("unknown fibre blocked the score")
+	}
+}
+
+func TestNutriScoreThresholds(t *testing.T) {
+	for _, tt := range []struct {
+		v          float64
+		thresholds []float64
+		want       int
+	}{
+		{335, energyKJ, 0}, {335.1, energyKJ, 1}, {3350, energyKJ, 9}, {4000, energyKJ, 10},
+		{4.5, sugarsG, 0}, {4.6, sugarsG, 1}, {45.1, sugarsG, 10},
+		{1, saturatedFatG, 0}, {10.1, saturatedFatG, 10},
+		{90, sodiumMg, 0}, {901, sodiumMg, 10},
+		{0.9, fiberG, 0}, {4.8, fiberG, 5},
+		{1.6, proteinsG, 0}, {8.1, proteinsG, 5},
+		{0, beverageKJ, 0}, {1, beverageKJ, 1}, {271, beverageKJ, 10},
+		{0, beverageSugars, 0}, {13.6, beverageSugars, 10},
+	} {
+		if got := points(tt.v, tt.thresholds); got != tt.want {
+			t.Errorf("points(%v, %v) = %d, want %d", tt.v, tt.thresholds, got, tt.want)
+		}
+	}
+	// the saturated fat ratio's bounds are inclusive
+	for ratio, want := range map[float64]int{9.9: 0, 10: 1, 16: 2, 63.9: 9, 64: 10, 100: 10} {
+		if got := ratioPoints(ratio); got != want {
+			t.Errorf("ratioPoints(%v) = %d, want %d", ratio, got, want)
+		}
+	}
+	for pct, want := range map[float64]int{40: 0, 40.1: 1, 60.1: 2, 80: 2, 80.1: 5} {
+		if got := fvnPoints(pct, 1, 2, 5); got != want {
+			t.Errorf("fvnPoints(%v) = %d, want %d", pct, got, want)
+		}
+	}
+}
+
+func TestNutriGrade(t *testing.T) {
+	general := map[int]string{-15: "a", -1: "a", 0: "b", 2: "b", 3: "c", 10: "c", 11: "d", 18: "d", 19: "e", 40: "e"}
+	for score, want := range general {
+		if got := nutriGrade(score, ProfileGeneral); got != want {
+			t.Errorf("general %d = %s, want %s", score, got, want)
+		}
+	}
+	beverage := map[int]string{-3: "b", 1: "b", 2: "c", 5: "c", 6: "d", 9: "d", 10: "e"}
+	for score, want := range beverage {
+		if got := nutriGrade(score, ProfileBeverage); got != want {
+			t.Errorf("beverage %d = %s, want %s", score, got, want)
+		}
+	}
+}
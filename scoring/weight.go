@@ -0,0 +1,47 @@
+package scoring
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var quantityRe = regexp.MustCompile(`(?i)^\s*(?:(\d+)\s*[x×*]\s*)?(\d+(?:[.,]\d+)?)\s*(kg|g|mg|l|cl|ml|oz|lb)\b`)
+
+var unitKg = map[string]float64{
+	"kg": 1,
+	"g":  0.001,
+	"mg": 0.000001,
+	"l":  1, // liquids are approximated at 1 kg per litre
+	"cl": 0.01,
+	"ml": 0.001,
+	"oz": 0.0283495,
+	"lb": 0.453592,
+}
+
+// ParseWeightKg converts a label quantity such as "500 g", "1,5 kg", "33 cl" or "4 x 125 g"
+// into kilograms. It returns 0 when the text cannot be understood.
+func ParseWeightKg(quantity string) float64 {
+	m := quantityRe.FindStringSubmatch(quantity)
+	if m == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(strings.Replace(m[2], ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	if m[1] != "" {
+		if packs, err := strconv.Atoi(m[1]); err == nil {
+			value *= float64(packs)
+		}
+	}
+	return value * unitKg[strings.ToLower(m[3])]
+}
+
+// NetWeightKg prefers an explicit net weight in grams and falls back to parsing the label quantity
+func NetWeightKg(netWeightGrams float64, quantity string) float64 {
+	if netWeightGrams > 0 {
+		return netWeightGrams / 1000
+	}
+	return ParseWeightKg(quantity)
+}
@@ -0,0 +1,141 @@
+// Package badges awards badges from a declarative catalog of rules, each a
+// threshold on one of the metrics of package metrics.
+package badges
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"backend/models"
+	"backend/services/metrics"
+)
+
+//go:embed badges.json
+var builtinBadgesJSON []byte
+
+var Comparators = []string{">=", ">", "<=", "<", "=="}
+
+// Rule earns a badge once the metric, measured over the window, compares to
+// the threshold. An empty window is all time.
+type Rule struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Icon        string  `json:"icon,omitempty"`
+	Metric      string  `json:"metric"`
+	Comparator  string  `json:"comparator"`
+	Threshold   float64 `json:"threshold"`
+	Window      string  `json:"window,omitempty"`
+}
+
+// Met reports whether value satisfies the rule
+func (r Rule) Met(value float64) bool {
+	switch r.Comparator {
+	case ">=":
+		return value >= r.Threshold
+	case ">":
+		return value > r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	case "<":
+		return value < r.Threshold
+	}
+	return value == r.Threshold
+}
+
+// Progress is how close value is to meeting the rule, from 0 to 1
+func (r Rule) Progress(value float64) float64 {
+	switch {
+	case r.Met(value):
+		return 1
+	case (r.Comparator == ">=" || r.Comparator == ">") && r.Threshold > 0 && value > 0:
+		return min(math.Round(value/r.Threshold*1000)/1000, 0.99)
+	case (r.Comparator == "<=" || r.Comparator == "<") && value > 0:
+		return min(math.Round(r.Threshold/value*1000)/1000, 0.99)
+	}
+	return 0
+}
+
+// Badge is the badge the rule awards
+func (r Rule) Badge() models.Badge {
+	return models.Badge{ID: r.ID, Name: r.Name, Description: r.Description, Icon: r.Icon}
+}
+
+// Catalog is the set of badge rules evaluated for every user
+type Catalog []Rule
+
+var (
+	defaultMu      sync.RWMutex
+	defaultCatalog = builtinCatalog()
+)
+
+// Default returns the catalog used by the handlers
+func Default() Catalog {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultCatalog
+}
+
+// SetDefault replaces the catalog used by the handlers
+func SetDefault(c Catalog) {
+	defaultMu.Lock()
+	defaultCatalog = c
+	defaultMu.Unlock()
+}
+
+// Load reads a JSON array of rules in the format of the built-in badges.json
+func Load(path string) (Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCatalog(f)
+}
+
+func builtinCatalog() Catalog {
+	c, err := parseCatalog(strings.NewReader(string(builtinBadgesJSON)))
+	if err != nil {
+		panic("badges: bad embedded badges.json: " + err.Error())
+	}
+	return c
+}
+
+func parseCatalog(r io.Reader) (Catalog, error) {
+	var c Catalog
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{}
+	for i, rule := range c {
+		switch {
+		case rule.ID <= 0 || rule.Name == "":
+			return nil, fmt.Errorf("badges: entry %d: a positive id and a name are required", i+1)
+		case seen[rule.ID]:
+			return nil, fmt.Errorf("badges: duplicate id %d", rule.ID)
+		case !metrics.Valid(rule.Metric):
+			return nil, fmt.Errorf("badges: %d: metric must be one of %s", rule.ID, strings.Join(metrics.All, ", "))
+		case !validComparator(rule.Comparator):
+			return nil, fmt.Errorf("badges: %d: comparator must be one of %s", rule.ID, strings.Join(Comparators, " "))
+		case !metrics.ValidWindow(rule.Window):
+			return nil, fmt.Errorf("badges: %d: window must be empty or one of %s", rule.ID, strings.Join(metrics.Windows, ", "))
+		}
+		seen[rule.ID] = true
+	}
+	return c, nil
+}
+
+func validComparator(c string) bool {
+	for _, known := range Comparators {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
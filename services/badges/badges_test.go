@@ -0,0 +1,163 @@
+package badges
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+	"backend/services/metrics"
+)
+
+func TestRuleMet(t *testing.T) {
+	tests := []struct {
+		comparator string
+		value      float64
+		want       bool
+	}{
+		{">=", 9, false}, {">=", 10, true}, {">=", 11, true},
+		{">", 10, false}, {">", 10.5, true},
+		{"<=", 10, true}, {"<=", 11, false},
+		{"<", 10, false}, {"<", 9, true},
+		{"==", 10, true}, {"==", 9, false},
+	}
+	for _, tt := range tests {
+		r := Rule{Comparator: tt.comparator, Threshold: 10}
+		if got := r.Met(tt.value); got != tt.want {
+			t.Errorf("%v %s 10 = %v, want %v", tt.value, tt.comparator, got, tt.want)
+		}
+	}
+}
+
+func TestRuleProgress(t *testing.T) {
+	tests := []struct {
+		comparator string
+		threshold  float64
+		value      float64
+		want       float64
+	}{
+		{">=", 10, 0, 0},
+		{">=", 10, 2.5, 0.25},
+		{">=", 3, 1, 0.333},
+		{">=", 10, 9.999, 0.99}, // only a met rule shows 1
+		{">=", 10, 12, 1},
+		{">", 10, 10, 0.99},
+		{">=", 0, 0, 1},
+		{"<=", 5, 10, 0.5},
+		{"<=", 5, 4, 1},
+		{"<", 5, 0, 1},
+		{"==", 10, 5, 0},
+		{">=", 10, -3, 0},
+	}
+	for _, tt := range tests {
+		r := Rule{Comparator: tt.comparator, Threshold: tt.threshold}
+		if got := r.Progress(tt.value); got != tt.want {
+			t.Errorf("progress of %v towards %s %v = %v, want %v", tt.value, tt.comparator, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	valid := `{"id": 1, "name": "First", "metric": "baskets", "comparator": ">=", "threshold": 1}`
+	tests := []struct {
+		name  string
+		json  string
+		error string // "" when the catalog is accepted
+	}{
+		{"valid", `[` + valid + `]`, ""},
+		{"windowed", `[{"id": 1, "name": "Weekly", "metric": "scans", "comparator": ">", "threshold": 5, "window": "week"}]`, ""},
+		{"no id", `[{"name": "First", "metric": "baskets", "comparator": ">="}]`, "positive id"},
+		{"no name", `[{"id": 1, "metric": "baskets", "comparator": ">="}]`, "positive id"},
+		{"duplicate id", `[` + valid + `,` + valid + `]`, "duplicate id 1"},
+		{"unknown metric", `[{"id": 1, "name": "First", "metric": "basket", "comparator": ">="}]`, "metric must be one of"},
+		{"unknown comparator", `[{"id": 1, "name": "First", "metric": "baskets", "comparator": "=>"}]`, "comparator must be one of"},
+		{"bad window", `[{"id": 1, "name": "First", "metric": "baskets", "comparator": ">=", "window": "fortnight"}]`, "window must be empty or one of"},
+		{"not json", `[{"id": 1,`, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCatalog(strings.NewReader(tt.json))
+			if tt.error == "" {
+				if err != nil {
+					t.Fatalf("parseCatalog: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("parseCatalog = %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+	if len(builtinCatalog()) == 0 {
+		t.Error("built-in catalog is empty")
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	before := Default()
+	t.Cleanup(func() { SetDefault(before) })
+	SetDefault(Catalog{
+		{ID: 1, Name: "First Basket", Metric: metrics.Baskets, Comparator: ">=", Threshold: 1},
+		{ID: 2, Name: "Weekly Regular", Metric: metrics.Baskets, Comparator: ">=", Threshold: 3, Window: metrics.Week},
+		{ID: 3, Name: "Label Reader", Metric: metrics.Scans, Comparator: ">=", Threshold: 4},
+	})
+
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	e := NewEvaluator(metrics.New(repos.Baskets, repos.History, repos.Goals), repos.Badges)
+	e.now = func() time.Time { return now }
+
+	ids := func(badges []models.Badge) []int {
+		out := []int{}
+		for _, b := range badges {
+			out = append(out, b.ID)
+		}
+		return out
+	}
+	basket := func(at time.Time) {
+		t.Helper()
+		if err := repos.Baskets.Insert(ctx, &models.Basket{UserID: "u1", TotalItems: 1, CreatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := e.Evaluate(ctx, "u1"); err != nil || len(got) != 0 {
+		t.Fatalf("Evaluate without activity = %v, %v", got, err)
+	}
+
+	// three baskets, but only two in the last week
+	basket(now.AddDate(0, 0, -10))
+	basket(now.AddDate(0, 0, -2))
+	basket(now.AddDate(0, 0, -1))
+	got, err := e.Evaluate(ctx, "u1")
+	if err != nil || !reflect.DeepEqual(ids(got), []int{1}) {
+		t.Fatalf("Evaluate = %v, %v; want badge 1", ids(got), err)
+	}
+	if got, _ := e.Evaluate(ctx, "u1"); len(got) != 0 {
+		t.Errorf("badges awarded again: %v", ids(got))
+	}
+
+	locked, err := e.Locked(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Status{
+		{Rule: Default()[1], Value: 2, Progress: 0.667},
+		{Rule: Default()[2], Value: 0, Progress: 0},
+	}
+	if !reflect.DeepEqual(locked, want) {
+		t.Errorf("Locked = %+v, want %+v", locked, want)
+	}
+
+	basket(now)
+	if got, _ := e.Evaluate(ctx, "u1"); !reflect.DeepEqual(ids(got), []int{2}) {
+		t.Errorf("Evaluate after a third basket this week = %v, want badge 2", ids(got))
+	}
+	if got, _ := e.Evaluate(ctx, "u2"); !reflect.DeepEqual(ids(got), []int{}) {
+		t.Errorf("another user earned %v", ids(got))
+	}
+}
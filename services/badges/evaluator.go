@@ -0,0 +1,110 @@
+package badges
+
+import (
+	"context"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+	"backend/services/metrics"
+)
+
+// Status is a catalog rule with the user's current value and progress towards it
+type Status struct {
+	Rule
+	Value    float64 `json:"value"`
+	Progress float64 `json:"progress"` // 0-1
+}
+
+// Evaluator awards the badges of Default() as users' metrics change
+type Evaluator struct {
+	meter  *metrics.Meter
+	badges repository.BadgeRepo
+	now    func() time.Time
+}
+
+func NewEvaluator(meter *metrics.Meter, badges repository.BadgeRepo) *Evaluator {
+	return &Evaluator{meter: meter, badges: badges, now: time.Now}
+}
+
+// Evaluate awards every badge the user now qualifies for and returns the newly earned ones.
+// Run it after each event that moves a metric: a saved basket, a scan, a completed goal.
+func (e *Evaluator) Evaluate(ctx context.Context, userID string) ([]models.Badge, error) {
+	earned, err := e.earned(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	awarded := []models.Badge{}
+	measure := e.measurer(userID)
+	for _, rule := range Default() {
+		if earned[rule.ID] {
+			continue
+		}
+		value, err := measure(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		if !rule.Met(value) {
+			continue
+		}
+		isNew, err := e.badges.Award(ctx, userID, rule.Badge())
+		if err != nil {
+			return nil, err
+		}
+		if isNew {
+			awarded = append(awarded, rule.Badge())
+		}
+	}
+	return awarded, nil
+}
+
+// Locked returns the catalog badges the user has not earned, with progress towards each
+func (e *Evaluator) Locked(ctx context.Context, userID string) ([]Status, error) {
+	earned, err := e.earned(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := []Status{}
+	measure := e.measurer(userID)
+	for _, rule := range Default() {
+		if earned[rule.ID] {
+			continue
+		}
+		value, err := measure(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Status{Rule: rule, Value: value, Progress: rule.Progress(value)})
+	}
+	return out, nil
+}
+
+func (e *Evaluator) earned(ctx context.Context, userID string) (map[int]bool, error) {
+	page, err := e.badges.ListByUser(ctx, userID, repository.ListQuery{})
+	if err != nil {
+		return nil, err
+	}
+	earned := map[int]bool{}
+	for _, b := range page.Items {
+		earned[b.BadgeID] = true
+	}
+	return earned, nil
+}
+
+// measurer measures rules for one user, reading each metric and window once
+func (e *Evaluator) measurer(userID string) func(context.Context, Rule) (float64, error) {
+	now := e.now()
+	cache := map[[2]string]float64{}
+	return func(ctx context.Context, rule Rule) (float64, error) {
+		key := [2]string{rule.Metric, rule.Window}
+		if v, ok := cache[key]; ok {
+			return v, nil
+		}
+		v, _, err := e.meter.Measure(ctx, userID, rule.Metric, metrics.WindowStart(rule.Window, now))
+		if err != nil {
+			return 0, err
+		}
+		cache[key] = v
+		return v, nil
+	}
+}
@@ -0,0 +1,171 @@
+// Package basket analyzes a list of scanned products into carbon and health totals.
+// It is shared by the analyze and save basket endpoints.
+package basket
+
+import (
+	"context"
+
+	"backend/allergens"
+	"backend/importer"
+	"backend/models"
+	"backend/scoring"
+)
+
+// ProductStore looks up catalog products by barcode in a single batch.
+// Barcodes with no product are simply absent from the returned map.
+type ProductStore interface {
+	FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error)
+}
+
+// BasketLine is one requested product and how many packages of it are in the basket
+type BasketLine struct {
+	Barcode  string `json:"barcode"`
+	Quantity int    `json:"quantity"`
+}
+
+// LineReport is the analysis of a single basket line
+type LineReport = models.BasketLineReport
+
+// BasketReport aggregates the lines. Unknown barcodes are listed separately and
+// excluded from the totals rather than being given a made-up score; unscored
+// products count as items but not towards AvgHealthScore.
+type BasketReport struct {
+	TotalItems      int          `json:"total_items" bson:"total_items"`
+	TotalCarbon     float64      `json:"total_carbon" bson:"total_carbon"`
+	AvgHealthScore  int          `json:"avg_health_score" bson:"avg_health_score"`
+	Items           []LineReport `json:"items" bson:"items"`
+	UnknownBarcodes []string     `json:"unknown_barcodes" bson:"unknown_barcodes"`
+	// Warnings are conflicts with the dietary profile passed to AnalyzeFor
+	Warnings []allergens.Warning `json:"warnings,omitempty" bson:"warnings,omitempty"`
+}
+
+// Service runs basket analysis against a product store and carbon model
+type Service struct {
+	products ProductStore
+	model    scoring.CarbonModel
+}
+
+// New returns a Service using the given store. A nil model means scoring.Default() at analysis time.
+func New(products ProductStore, model scoring.CarbonModel) *Service {
+	return &Service{products: products, model: model}
+}
+
+// Analyze prices every line. Quantities below 1 count as 1.
+func (s *Service) Analyze(ctx context.Context, lines []BasketLine) (BasketReport, error) {
+	return s.AnalyzeFor(ctx, lines, nil)
+}
+
+// AnalyzeFor is Analyze plus a warning for every product that conflicts with
+// profile; a nil profile adds no warnings.
+func (s *Service) AnalyzeFor(ctx context.Context, lines []BasketLine, profile *models.DietaryProfile) (BasketReport, error) {
+	model := s.model
+	if model == nil {
+		model = scoring.Default()
+	}
+
+	products, err := s.products.FindByBarcodes(ctx, uniqueBarcodes(lines))
+	if err != nil {
+		return BasketReport{}, err
+	}
+
+	report := BasketReport{Items: make([]LineReport, 0, len(lines)), UnknownBarcodes: []string{}}
+	totalHealth, scoredItems := 0, 0
+
+	for _, line := range lines {
+		qty := line.Quantity
+		if qty < 1 {
+			qty = 1
+		}
+
+		prod, ok := products[line.Barcode]
+		if !ok {
+			report.Items = append(report.Items, LineReport{Barcode: line.Barcode, Quantity: qty})
+			report.UnknownBarcodes = append(report.UnknownBarcodes, line.Barcode)
+			continue
+		}
+
+		item := scoring.Item{
+			Barcode:     prod.Barcode,
+			Category:    prod.Category,
+			Categories:  prod.Categories,
+			EcoScore:    prod.EcoScore,
+			Unscored:    !prod.HasEcoScore(),
+			NetWeightKg: scoring.NetWeightKg(prod.NetWeightG, prod.Quantity),
+			Units:       qty,
+		}
+		// an unscored product outside every factor table adds no carbon
+		est, ok := model.Estimate(item)
+		if !ok {
+			est, _ = scoring.Linear{}.Estimate(item)
+		}
+
+		report.Items = append(report.Items, LineReport{
+			Barcode:        line.Barcode,
+			ProductName:    prod.Name,
+			Quantity:       qty,
+			Known:          true,
+			Carbon:         est.CarbonKg,
+			HealthScore:    prod.EcoScore,
+			Unscored:       item.Unscored,
+			CarbonModel:    est.Model,
+			CarbonFactor:   est.Factor,
+			FactorUnit:     est.FactorUnit,
+			FactorCategory: est.FactorCategory,
+		})
+		report.TotalItems += qty
+		report.TotalCarbon += est.CarbonKg
+		if !item.Unscored {
+			totalHealth += prod.EcoScore * qty
+			scoredItems += qty
+		}
+	}
+
+	if scoredItems > 0 {
+		report.AvgHealthScore = totalHealth / scoredItems
+	}
+
+	if profile != nil {
+		report.Warnings = []allergens.Warning{}
+		for _, code := range uniqueBarcodes(lines) {
+			prod, ok := products[code]
+			if !ok {
+				continue
+			}
+			p := *prod
+			importer.Backfill(&p) // older entries only have the ingredients in RawData
+			report.Warnings = append(report.Warnings, allergens.Check(p, *profile)...)
+		}
+	}
+	return report, nil
+}
+
+// uniqueBarcodes returns each barcode once, in first-seen order
+func uniqueBarcodes(lines []BasketLine) []string {
+	seen := make(map[string]bool, len(lines))
+	codes := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if !seen[l.Barcode] {
+			seen[l.Barcode] = true
+			codes = append(codes, l.Barcode)
+		}
+	}
+	return codes
+}
+
+// LinesFromBarcodes turns a plain barcode list into lines of quantity 1
+func LinesFromBarcodes(barcodes []string) []BasketLine {
+	lines := make([]BasketLine, len(barcodes))
+	for i, code := range barcodes {
+		lines[i] = BasketLine{Barcode: code, Quantity: 1}
+	}
+	return lines
+}
+
+// LinesFromItems converts frontend basket items (productId is the barcode)
+func LinesFromItems(items []models.BasketItem) []BasketLine {
+	lines := make([]BasketLine, len(items))
+	for i, it := range items {
+		lines[i] = BasketLine{Barcode: it.ProductID, Quantity: it.Quantity}
+	}
+	return lines
+}
@@ -0,0 +1,340 @@
+package basket
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+)
+
+// countingStore records the barcodes of every lookup
+type countingStore struct {
+	next  ProductStore
+	calls [][]string
+}
+
+func (s *countingStore) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	s.calls = append(s.calls, barcodes)
+	return s.next.FindByBarcodes(ctx, barcodes)
+}
+
+func testProducts() *repository.MemoryProducts {
+	return repository.NewMemoryProducts(
+		models.Product{Barcode: "yogurt", Name: "Yogurt", Category: "yogurts", Quantity: "500 g", EcoScore: 80,
+			IngredientsText: "milk, live cultures"},
+		models.Product{Barcode: "cheese", Name: "Cheese", Category: "en:cheeses", NetWeightG: 200, EcoScore: 40},
+		models.Product{Barcode: "plain", Name: "Uncategorized", EcoScore: 60,
+			IngredientsText: "wheat flour, water, salt"},
+		models.Product{Barcode: "noweight", Name: "Yogurt without weight", Category: "yogurts", EcoScore: 90},
+	)
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []BasketLine
+		items      int
+		carbon     float64
+		avgHealth  int
+		unknown    []string
+		models     []string // CarbonModel of each line, "" for unknown barcodes
+		quantities []int
+	}{
+		{
+			name:       "factor model uses category and net weight",
+			lines:      []BasketLine{{"yogurt", 1}},
+			items:      1,
+			carbon:     2.5 * 0.5,
+			avgHealth:  80,
+			unknown:    []string{},
+			models:     []string{"category_factor"},
+			quantities: []int{1},
+		},
+		{
+			name:       "factor model reads NetWeightG and strips the language prefix",
+			lines:      []BasketLine{{"cheese", 2}},
+			items:      2,
+			carbon:     21 * 0.2 * 2,
+			avgHealth:  40,
+			unknown:    []string{},
+			models:     []string{"category_factor"},
+			quantities: []int{2},
+		},
+		{
+			name:       "linear fallback without a category",
+			lines:      []BasketLine{{"plain", 1}},
+			items:      1,
+			carbon:     (100 - 60) * 0.05,
+			avgHealth:  60,
+			unknown:    []string{},
+			models:     []string{"linear"},
+			quantities: []int{1},
+		},
+		{
+			name:       "linear fallback without a weight",
+			lines:      []BasketLine{{"noweight", 3}},
+			items:      3,
+			carbon:     (100 - 90) * 0.05 * 3,
+			avgHealth:  90,
+			unknown:    []string{},
+			models:     []string{"linear"},
+			quantities: []int{3},
+		},
+		{
+			name:       "quantities below one count as one",
+			lines:      []BasketLine{{"plain", 0}, {"plain", -4}},
+			items:      2,
+			carbon:     2 * (100 - 60) * 0.05,
+			avgHealth:  60,
+			unknown:    []string{},
+			models:     []string{"linear", "linear"},
+			quantities: []int{1, 1},
+		},
+		{
+			name:       "health score is weighted by quantity",
+			lines:      []BasketLine{{"yogurt", 3}, {"cheese", 1}},
+			items:      4,
+			carbon:     2.5*0.5*3 + 21*0.2,
+			avgHealth:  (80*3 + 40) / 4,
+			unknown:    []string{},
+			models:     []string{"category_factor", "category_factor"},
+			quantities: []int{3, 1},
+		},
+		{
+			name:       "duplicate barcodes stay separate lines",
+			lines:      []BasketLine{{"plain", 1}, {"yogurt", 1}, {"plain", 2}},
+			items:      4,
+			carbon:     3*(100-60)*0.05 + 2.5*0.5,
+			avgHealth:  (60*3 + 80) / 4,
+			unknown:    []string{},
+			models:     []string{"linear", "category_factor", "linear"},
+			quantities: []int{1, 1, 2},
+		},
+		{
+			name:       "unknown barcodes are listed and left out of the totals",
+			lines:      []BasketLine{{"missing", 5}, {"plain", 1}, {"missing", 1}},
+			items:      1,
+			carbon:     (100 - 60) * 0.05,
+			avgHealth:  60,
+			unknown:    []string{"missing", "missing"},
+			models:     []string{"", "linear", ""},
+			quantities: []int{5, 1, 1},
+		},
+		{
+			name:       "only unknown barcodes",
+			lines:      []BasketLine{{"missing", 1}},
+			unknown:    []string{"missing"},
+			models:     []string{""},
+			quantities: []int{1},
+		},
+		{
+			name:    "empty basket",
+			unknown: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &countingStore{next: testProducts()}
+			report, err := New(store, nil).Analyze(context.Background(), tt.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.TotalItems != tt.items {
+				t.Errorf("TotalItems = %d, want %d", report.TotalItems, tt.items)
+			}
+			if math.Abs(report.TotalCarbon-tt.carbon) > 1e-9 {
+				t.Errorf("TotalCarbon = %v, want %v", report.TotalCarbon, tt.carbon)
+			}
+			if report.AvgHealthScore != tt.avgHealth {
+				t.Errorf("AvgHealthScore = %d, want %d", report.AvgHealthScore, tt.avgHealth)
+			}
+			if !reflect.DeepEqual(report.UnknownBarcodes, tt.unknown) {
+				t.Errorf("UnknownBarcodes = %v, want %v", report.UnknownBarcodes, tt.unknown)
+			}
+			if len(report.Items) != len(tt.lines) {
+				t.Fatalf("%d items, want one per line (%d)", len(report.Items), len(tt.lines))
+			}
+			for i, item := range report.Items {
+				if item.Barcode != tt.lines[i].Barcode || item.Quantity != tt.quantities[i] {
+					t.Errorf("item %d = %s x%d, want %s x%d", i, item.Barcode, item.Quantity, tt.lines[i].Barcode, tt.quantities[i])
+				}
+				if item.CarbonModel != tt.models[i] || item.Known != (tt.models[i] != "") {
+					t.Errorf("item %d model = %q known = %v, want %q", i, item.CarbonModel, item.Known, tt.models[i])
+				}
+			}
+			if report.Warnings != nil {
+				t.Errorf("Warnings = %v without a profile, want nil", report.Warnings)
+			}
+
+			// every barcode is looked up once, in one batch
+			if len(store.calls) != 1 {
+				t.Fatalf("%d store calls, want 1", len(store.calls))
+			}
+			if want := uniqueBarcodes(tt.lines); !reflect.DeepEqual(store.calls[0], want) {
+				t.Errorf("looked up %v, want %v", store.calls[0], want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeUnscored(t *testing.T) {
+	products := repository.NewMemoryProducts(
+		models.Product{Barcode: "plain", Name: "Uncategorized", EcoScore: 60},
+		models.Product{Barcode: "unscored", Name: "No category, no score"},
+		models.Product{Barcode: "unscored-yogurt", Name: "Yogurt without score", Category: "yogurts", Quantity: "500 g"},
+	)
+	report, err := New(products, nil).Analyze(context.Background(), []BasketLine{{"plain", 1}, {"unscored", 2}, {"unscored-yogurt", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the unscored lines count as items but leave the health average alone; one
+	// with a category factor is still priced, one without is not charged 5 kg
+	if report.TotalItems != 4 || report.AvgHealthScore != 60 {
+		t.Errorf("TotalItems = %d, AvgHealthScore = %d; want 4, 60", report.TotalItems, report.AvgHealthScore)
+	}
+	if want := (100-60)*0.05 + 2.5*0.5; math.Abs(report.TotalCarbon-want) > 1e-9 {
+		t.Errorf("TotalCarbon = %v, want %v", report.TotalCarbon, want)
+	}
+	for i, want := range []struct {
+		model    string
+		unscored bool
+	}{{"linear", false}, {"", true}, {"category_factor", true}} {
+		if item := report.Items[i]; !item.Known || item.CarbonModel != want.model || item.Unscored != want.unscored {
+			t.Errorf("item %d = %+v, want model %q, unscored %v", i, item, want.model, want.unscored)
+		}
+	}
+}
+
+func TestAnalyzeForWarnings(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []BasketLine
+		profile models.DietaryProfile
+		want    []string // kind:code of each warning
+	}{
+		{"no conflicts", []BasketLine{{"plain", 1}}, models.DietaryProfile{Allergens: []string{"peanuts"}}, []string{}},
+		{"allergen", []BasketLine{{"yogurt", 1}}, models.DietaryProfile{Allergens: []string{"milk"}}, []string{"allergen:milk"}},
+		{"diet", []BasketLine{{"yogurt", 1}}, models.DietaryProfile{Diets: []string{"vegan"}}, []string{"diet:vegan"}},
+		{"duplicate barcodes warn once", []BasketLine{{"plain", 1}, {"plain", 2}}, models.DietaryProfile{Allergens: []string{"gluten"}}, []string{"allergen:gluten"}},
+		{"unknown barcodes are skipped", []BasketLine{{"missing", 1}}, models.DietaryProfile{Allergens: []string{"milk"}}, []string{}},
+		{
+			"every product is checked",
+			[]BasketLine{{"yogurt", 1}, {"plain", 1}},
+			models.DietaryProfile{Allergens: []string{"milk", "gluten"}},
+			[]string{"allergen:milk", "allergen:gluten"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := New(testProducts(), nil).AnalyzeFor(context.Background(), tt.lines, &tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, w := range report.Warnings {
+				got = append(got, w.Kind+":"+w.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("warnings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	if got, want := LinesFromBarcodes([]string{"a", "b"}), []BasketLine{{"a", 1}, {"b", 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LinesFromBarcodes = %v, want %v", got, want)
+	}
+	items := []models.BasketItem{{ProductID: "a", Quantity: 3}}
+	if got, want := LinesFromItems(items), []BasketLine{{"a", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LinesFromItems = %v, want %v", got, want)
+	}
+}
+
+// benchRTT stands in for a Mongo round trip on every store call
+const benchRTT = 500 * time.Microsecond
+
+// latencyStore adds a fixed delay to every call of the wrapped store
+type latencyStore struct {
+	next ProductStore
+	rtt  time.Duration
+}
+
+func (s latencyStore) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	time.Sleep(s.rtt)
+	return s.next.FindByBarcodes(ctx, barcodes)
+}
+
+// perBarcodeStore reproduces the old behaviour: one round trip per barcode
+type perBarcodeStore struct {
+	next ProductStore
+}
+
+func (s perBarcodeStore) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	found := make(map[string]*models.Product, len(barcodes))
+	for _, code := range barcodes {
+		one, err := s.next.FindByBarcodes(ctx, []string{code})
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range one {
+			found[k] = v
+		}
+	}
+	return found, nil
+}
+
+// BenchmarkAnalyze compares the one-query-per-barcode lookup with the batched
+// $in path and the LRU cache for 10, 100 and 1000-item baskets:
+//
+//	go test -run '^$' -bench Analyze ./services/basket
+func BenchmarkAnalyze(b *testing.B) {
+	const catalog = 5000
+	mem := repository.NewMemoryProducts()
+	for i := 0; i < catalog; i++ {
+		mem.Put(models.Product{
+			Barcode:  strconv.Itoa(4000000000000 + i),
+			Name:     "Product " + strconv.Itoa(i),
+			EcoScore: i % 100,
+			Category: "yogurts",
+			Quantity: "500 g",
+		})
+	}
+	slow := latencyStore{next: mem, rtt: benchRTT}
+
+	stores := []struct {
+		name  string
+		store func() ProductStore
+	}{
+		{"per-barcode", func() ProductStore { return perBarcodeStore{next: slow} }},
+		{"batch", func() ProductStore { return slow }},
+		{"batch+lru", func() ProductStore { return NewCachedProductStore(slow, catalog, 0) }},
+	}
+
+	for _, size := range []int{10, 100, 1000} {
+		lines := make([]BasketLine, size)
+		for i := range lines {
+			lines[i] = BasketLine{Barcode: strconv.Itoa(4000000000000 + i%catalog), Quantity: 1}
+		}
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			for _, s := range stores {
+				b.Run(s.name, func(b *testing.B) {
+					svc := New(s.store(), nil)
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						if _, err := svc.Analyze(context.Background(), lines); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package basket
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// CachedProductStore keeps recently used products in an in-process LRU and
+// only asks the wrapped store for barcodes it has not seen (or whose entry expired).
+type CachedProductStore struct {
+	next ProductStore
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List // front = most recently used
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	barcode string
+	product models.Product
+	expires time.Time
+}
+
+// NewCachedProductStore wraps next with an LRU of at most size products. ttl <= 0 means entries never expire.
+func NewCachedProductStore(next ProductStore, size int, ttl time.Duration) *CachedProductStore {
+	return &CachedProductStore{
+		next:  next,
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *CachedProductStore) FindByBarcodes(ctx context.Context, barcodes []string) (map[string]*models.Product, error) {
+	found := make(map[string]*models.Product, len(barcodes))
+	var misses []string
+
+	now := time.Now()
+	c.mu.Lock()
+	for _, code := range barcodes {
+		el, ok := c.items[code]
+		if !ok {
+			misses = append(misses, code)
+			continue
+		}
+		entry := el.Value.(*cacheEntry)
+		if c.ttl > 0 && now.After(entry.expires) {
+			c.removeElement(el)
+			misses = append(misses, code)
+			continue
+		}
+		c.order.MoveToFront(el)
+		p := entry.product
+		found[code] = &p
+	}
+	c.mu.Unlock()
+
+	if len(misses) == 0 {
+		return found, nil
+	}
+
+	fetched, err := c.next.FindByBarcodes(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	for code, p := range fetched {
+		found[code] = p
+		c.add(code, *p, now)
+	}
+	c.mu.Unlock()
+	return found, nil
+}
+
+// Invalidate drops a barcode so the next lookup reads the backing store
+func (c *CachedProductStore) Invalidate(barcode string) {
+	c.mu.Lock()
+	if el, ok := c.items[barcode]; ok {
+		c.removeElement(el)
+	}
+	c.mu.Unlock()
+}
+
+func (c *CachedProductStore) add(code string, p models.Product, now time.Time) {
+	if el, ok := c.items[code]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.product = p
+		entry.expires = now.Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[code] = c.order.PushFront(&cacheEntry{barcode: code, product: p, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *CachedProductStore) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).barcode)
+}
@@ -0,0 +1,153 @@
+// Package goals ties user goals to measured metrics and keeps their progress
+// current as baskets are saved and products scanned.
+package goals
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+	"backend/services/metrics"
+)
+
+// Goal types are the metrics a goal can be set on, each measured over the goal's window
+const (
+	TypeCarbonSaved = metrics.CarbonSaved
+	TypeBaskets     = metrics.Baskets
+	TypeAvgEcoScore = metrics.AvgEcoScore
+	TypeScans       = metrics.Scans
+)
+
+var Types = []string{TypeCarbonSaved, TypeBaskets, TypeAvgEcoScore, TypeScans}
+
+// typeAliases map the free-form types older clients sent
+var typeAliases = map[string]string{
+	"carbon":    TypeCarbonSaved,
+	"co2":       TypeCarbonSaved,
+	"basket":    TypeBaskets,
+	"eco_score": TypeAvgEcoScore,
+	"ecoscore":  TypeAvgEcoScore,
+	"scan":      TypeScans,
+}
+
+// Windows a goal can be measured over. The rolling ones end now; the default
+// counts everything since the goal was created.
+const (
+	WindowSinceCreated = "since_created"
+	WindowWeek         = metrics.Week
+	WindowMonth        = metrics.Month
+	WindowYear         = metrics.Year
+)
+
+var Windows = []string{WindowSinceCreated, WindowWeek, WindowMonth, WindowYear}
+
+// NormalizeType returns the canonical goal type; ok is false for unknown types
+func NormalizeType(t string) (string, bool) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if alias, ok := typeAliases[t]; ok {
+		t = alias
+	}
+	for _, known := range Types {
+		if t == known {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// NormalizeWindow returns the canonical window, WindowSinceCreated for ""; ok is false for unknown windows
+func NormalizeWindow(w string) (string, bool) {
+	w = strings.ToLower(strings.TrimSpace(w))
+	if w == "" {
+		return WindowSinceCreated, true
+	}
+	for _, known := range Windows {
+		if w == known {
+			return w, true
+		}
+	}
+	return "", false
+}
+
+// since is the start of g's window at now
+func since(g models.Goal, now time.Time) time.Time {
+	if g.Window == WindowSinceCreated || g.Window == "" {
+		return g.CreatedAt
+	}
+	return metrics.WindowStart(g.Window, now)
+}
+
+// Tracker measures goals against the user's activity
+type Tracker struct {
+	goals repository.GoalRepo
+	meter *metrics.Meter
+	now   func() time.Time
+}
+
+func New(goals repository.GoalRepo, meter *metrics.Meter) *Tracker {
+	return &Tracker{goals: goals, meter: meter, now: time.Now}
+}
+
+// Measure returns the current value of g's metric over its window. Goals of an
+// unknown type, saved before types were checked, keep their stored progress.
+func (t *Tracker) Measure(ctx context.Context, g models.Goal) (float64, error) {
+	metric, ok := NormalizeType(g.Type)
+	if !ok {
+		return g.Progress, nil
+	}
+	v, _, err := t.meter.Measure(ctx, g.UserID, metric, since(g, t.now()))
+	return v, err
+}
+
+// Track sets g's progress and completes it once the target is reached.
+// Completed goals are left as they are. g is not saved.
+func (t *Tracker) Track(ctx context.Context, g *models.Goal) error {
+	if !g.Active() {
+		return nil
+	}
+	progress, err := t.Measure(ctx, *g)
+	if err != nil {
+		return err
+	}
+	g.Progress = progress
+	if g.TargetValue > 0 && progress >= g.TargetValue {
+		Complete(g, t.now())
+	}
+	return nil
+}
+
+// Complete marks g completed at now
+func Complete(g *models.Goal, now time.Time) {
+	if g.Active() {
+		g.Status = models.GoalCompleted
+		g.CompletedAt = &now
+	}
+}
+
+// Refresh recomputes every active goal of the user, saving the ones that
+// changed, and returns the goals that are still active
+func (t *Tracker) Refresh(ctx context.Context, userID string) ([]models.Goal, error) {
+	list, err := t.goals.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	active := []models.Goal{}
+	for _, g := range list {
+		before := g
+		if err := t.Track(ctx, &g); err != nil {
+			return nil, err
+		}
+		if g.Progress != before.Progress || g.Status != before.Status {
+			g.UpdatedAt = t.now()
+			if err := t.goals.SaveProgress(ctx, &g); err != nil {
+				return nil, err
+			}
+		}
+		if g.Active() {
+			active = append(active, g)
+		}
+	}
+	return active, nil
+}
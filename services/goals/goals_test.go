@@ -0,0 +1,136 @@
+package goals
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+	"backend/services/metrics"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"baskets":         TypeBaskets,
+		" Basket ":        TypeBaskets,
+		"co2":             TypeCarbonSaved,
+		"ecoscore":        TypeAvgEcoScore,
+		"scan":            TypeScans,
+		"goals_completed": "",
+		"":                "",
+	} {
+		got, ok := NormalizeType(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("NormalizeType(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for in, want := range map[string]string{
+		"":              WindowSinceCreated,
+		"Week":          WindowWeek,
+		"since_created": WindowSinceCreated,
+		"day":           "",
+	} {
+		got, ok := NormalizeWindow(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("NormalizeWindow(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	created := now.AddDate(0, 0, -3)
+	tracker := New(repos.Goals, metrics.New(repos.Baskets, repos.History, repos.Goals))
+	tracker.now = func() time.Time { return now }
+
+	// one scan before the goals were created, two in the last week, one last month
+	for _, at := range []time.Time{now.AddDate(0, 0, -5), now.AddDate(0, 0, -2), now.AddDate(0, 0, -1), now.AddDate(0, 0, -20)} {
+		if err := repos.History.Add(ctx, models.ScanHistory{UserID: "u1", Barcode: "1", Time: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		goal     models.Goal
+		progress float64
+		done     bool
+	}{
+		{"since created", models.Goal{Type: TypeScans, TargetValue: 5, Window: WindowSinceCreated}, 2, false},
+		{"empty window is since created", models.Goal{Type: TypeScans, TargetValue: 5}, 2, false},
+		{"rolling week", models.Goal{Type: TypeScans, TargetValue: 5, Window: WindowWeek}, 3, false},
+		{"rolling month", models.Goal{Type: TypeScans, TargetValue: 4, Window: WindowMonth}, 4, true},
+		{"alias type", models.Goal{Type: "scan", TargetValue: 2}, 2, true},
+		{"no target never completes", models.Goal{Type: TypeScans}, 2, false},
+		{"unknown type keeps its progress", models.Goal{Type: "steps", TargetValue: 10, Progress: 7}, 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := tt.goal
+			g.UserID, g.CreatedAt = "u1", created
+			if err := tracker.Track(ctx, &g); err != nil {
+				t.Fatal(err)
+			}
+			if g.Progress != tt.progress || !g.Active() != tt.done {
+				t.Errorf("progress %v, active %v; want %v, done %v", g.Progress, g.Active(), tt.progress, tt.done)
+			}
+			if tt.done && (g.CompletedAt == nil || !g.CompletedAt.Equal(now)) {
+				t.Errorf("completed at %v, want %v", g.CompletedAt, now)
+			}
+		})
+	}
+
+	// a completed goal is left as it is
+	done := now.AddDate(0, 0, -1)
+	g := models.Goal{UserID: "u1", Type: TypeScans, TargetValue: 1, Progress: 1, Status: models.GoalCompleted, CompletedAt: &done}
+	if err := tracker.Track(ctx, &g); err != nil || g.Progress != 1 || !g.CompletedAt.Equal(done) {
+		t.Errorf("completed goal tracked to %+v, %v", g, err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tracker := New(repos.Goals, metrics.New(repos.Baskets, repos.History, repos.Goals))
+	tracker.now = func() time.Time { return now }
+
+	created := now.AddDate(0, 0, -1)
+	reach := models.Goal{UserID: "u1", Type: TypeBaskets, TargetValue: 1, CreatedAt: created}
+	open := models.Goal{UserID: "u1", Type: TypeBaskets, TargetValue: 3, CreatedAt: created}
+	other := models.Goal{UserID: "u2", Type: TypeBaskets, TargetValue: 1, CreatedAt: created}
+	for _, g := range []*models.Goal{&reach, &open, &other} {
+		if err := repos.Goals.Insert(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Baskets.Insert(ctx, &models.Basket{UserID: "u1", TotalItems: 1, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := tracker.Refresh(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != open.ID || active[0].Progress != 1 {
+		t.Fatalf("active goals = %+v, want the open one at 1", active)
+	}
+
+	// both changes were saved
+	saved, err := repos.Goals.FindByID(ctx, "u1", reach.ID.Hex())
+	if err != nil || saved.Active() || saved.Progress != 1 || !saved.UpdatedAt.Equal(now) {
+		t.Errorf("reached goal saved as %+v, %v", saved, err)
+	}
+	if saved, _ := repos.Goals.FindByID(ctx, "u1", open.ID.Hex()); saved.Progress != 1 {
+		t.Errorf("open goal saved with progress %v", saved.Progress)
+	}
+	if n, _ := repos.Goals.CountCompletedSince(ctx, "u1", time.Time{}); n != 1 {
+		t.Errorf("%d goals completed, want 1", n)
+	}
+	if saved, _ := repos.Goals.FindByID(ctx, "u2", other.ID.Hex()); saved.Progress != 0 || !saved.Active() {
+		t.Errorf("another user's goal changed: %+v", saved)
+	}
+}
@@ -0,0 +1,105 @@
+// Package metrics measures a user's activity over a time range. Goals and
+// badges are both defined on these metrics.
+package metrics
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"backend/repository"
+)
+
+const (
+	CarbonSaved    = "carbon_saved"    // kg CO2e of the saved baskets
+	Baskets        = "baskets"         // number of saved baskets
+	AvgEcoScore    = "avg_eco_score"   // item-weighted eco-score of the saved baskets
+	TotalScore     = "total_score"     // sum of the saved baskets' average scores, as in the impact totals
+	Scans          = "scans"           // number of scanned products
+	GoalsCompleted = "goals_completed" // number of goals completed
+)
+
+var All = []string{CarbonSaved, Baskets, AvgEcoScore, TotalScore, Scans, GoalsCompleted}
+
+// Rolling windows ending now; "" is all time
+const (
+	Week  = "week"
+	Month = "month"
+	Year  = "year"
+)
+
+var Windows = []string{Week, Month, Year}
+
+// Valid reports whether metric is one of All
+func Valid(metric string) bool {
+	for _, m := range All {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidWindow reports whether w is "" or one of Windows
+func ValidWindow(w string) bool {
+	if w == "" {
+		return true
+	}
+	for _, known := range Windows {
+		if w == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WindowStart is where window begins at now; all time starts at the zero time
+func WindowStart(window string, now time.Time) time.Time {
+	switch window {
+	case Week:
+		return now.AddDate(0, 0, -7)
+	case Month:
+		return now.AddDate(0, -1, 0)
+	case Year:
+		return now.AddDate(-1, 0, 0)
+	}
+	return time.Time{}
+}
+
+// Meter reads metrics from the repositories
+type Meter struct {
+	baskets repository.BasketRepo
+	history repository.HistoryRepo
+	goals   repository.GoalRepo
+}
+
+func New(baskets repository.BasketRepo, history repository.HistoryRepo, goals repository.GoalRepo) *Meter {
+	return &Meter{baskets: baskets, history: history, goals: goals}
+}
+
+// Measure returns the user's value of metric from since until now; ok is false for unknown metrics
+func (m *Meter) Measure(ctx context.Context, userID, metric string, since time.Time) (value float64, ok bool, err error) {
+	switch metric {
+	case Scans:
+		n, err := m.history.CountSince(ctx, userID, since)
+		return float64(n), true, err
+	case GoalsCompleted:
+		n, err := m.goals.CountCompletedSince(ctx, userID, since)
+		return float64(n), true, err
+	case CarbonSaved, Baskets, AvgEcoScore, TotalScore:
+		st, err := m.baskets.StatsSince(ctx, userID, since)
+		if err != nil {
+			return 0, true, err
+		}
+		switch metric {
+		case CarbonSaved:
+			return math.Round(st.Carbon*100) / 100, true, nil
+		case Baskets:
+			return float64(st.Baskets), true, nil
+		case TotalScore:
+			return st.Score, true, nil
+		}
+		return math.Round(st.AvgEcoScore*10) / 10, true, nil
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/repository"
+)
+
+func TestValid(t *testing.T) {
+	for _, m := range All {
+		if !Valid(m) {
+			t.Errorf("Valid(%q) = false", m)
+		}
+	}
+	if Valid("carbon") || Valid("") {
+		t.Error("Valid accepted an unknown metric")
+	}
+	for w, want := range map[string]bool{"": true, "week": true, "month": true, "year": true, "day": false, "Week": false} {
+		if got := ValidWindow(w); got != want {
+			t.Errorf("ValidWindow(%q) = %v, want %v", w, got, want)
+		}
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	for w, want := range map[string]time.Time{
+		Week:  time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC),
+		Month: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC), // AddDate normalizes February 31st
+		Year:  time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC),
+		"":    {},
+	} {
+		if got := WindowStart(w, now); !got.Equal(want) {
+			t.Errorf("WindowStart(%q) = %v, want %v", w, got, want)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	now := time.Now()
+	old, recent := now.AddDate(0, 0, -30), now.AddDate(0, 0, -1)
+
+	for _, b := range []models.Basket{
+		{UserID: "u1", TotalItems: 1, TotalCarbon: 1.234, AvgHealthScore: 90, CreatedAt: old},
+		{UserID: "u1", TotalItems: 3, TotalCarbon: 2.5, AvgHealthScore: 50, CreatedAt: recent},
+		{UserID: "u2", TotalItems: 5, TotalCarbon: 100, AvgHealthScore: 10, CreatedAt: recent},
+	} {
+		if err := repos.Baskets.Insert(ctx, &b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, at := range []time.Time{old, recent, recent} {
+		if err := repos.History.Add(ctx, models.ScanHistory{UserID: "u1", Barcode: "1", Time: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Goals.Insert(ctx, &models.Goal{UserID: "u1", Status: models.GoalCompleted, CompletedAt: &recent}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Goals.Insert(ctx, &models.Goal{UserID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(repos.Baskets, repos.History, repos.Goals)
+	week := WindowStart(Week, now)
+	tests := []struct {
+		metric string
+		since  time.Time
+		want   float64
+	}{
+		{CarbonSaved, time.Time{}, 3.73},
+		{CarbonSaved, week, 2.5},
+		{Baskets, time.Time{}, 2},
+		{Baskets, week, 1},
+		// weighted by items: (90*1 + 50*3) / 4
+		{AvgEcoScore, time.Time{}, 60},
+		{AvgEcoScore, week, 50},
+		{TotalScore, time.Time{}, 140},
+		{Scans, time.Time{}, 3},
+		{Scans, week, 2},
+		{GoalsCompleted, time.Time{}, 1},
+		{GoalsCompleted, now.Add(time.Hour), 0},
+	}
+	for _, tt := range tests {
+		got, ok, err := m.Measure(ctx, "u1", tt.metric, tt.since)
+		if err != nil || !ok || got != tt.want {
+			t.Errorf("Measure(%s, since %v) = %v, %v, %v; want %v", tt.metric, tt.since, got, ok, err, tt.want)
+		}
+	}
+
+	if v, _, _ := m.Measure(ctx, "nobody", AvgEcoScore, time.Time{}); v != 0 {
+		t.Errorf("avg eco score without baskets = %v, want 0", v)
+	}
+	if _, ok, err := m.Measure(ctx, "u1", "carbon", time.Time{}); ok || err != nil {
+		t.Errorf("unknown metric = %v, %v; want not ok", ok, err)
+	}
+}
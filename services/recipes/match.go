@@ -0,0 +1,198 @@
+package recipes
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"backend/models"
+)
+
+// Query narrows and limits a match
+type Query struct {
+	Diets []string // canonical diet names the recipe must all carry
+	Limit int      // 0 means no limit
+}
+
+// MatchedIngredient is a recipe ingredient and the product that supplies it
+type MatchedIngredient struct {
+	Name    string `json:"name"`
+	Barcode string `json:"barcode"`
+	Product string `json:"product"`
+}
+
+// Result is a recipe with what the products cover and what is still needed
+type Result struct {
+	ID          string              `json:"id"`
+	Title       string              `json:"title"`
+	Coverage    float64             `json:"coverage"` // share of required ingredients the products supply, 0-1
+	Ingredients []string            `json:"ingredients"`
+	Matched     []MatchedIngredient `json:"matched_ingredients"`
+	Missing     []string            `json:"missing_ingredients"`
+	Optional    []string            `json:"optional_ingredients"` // optional ingredients not supplied
+	Pantry      []string            `json:"pantry"`
+	Steps       []string            `json:"steps"`
+	Diets       []string            `json:"diets"`
+
+	PrepMinutes  int `json:"prep_minutes"`
+	CookMinutes  int `json:"cook_minutes"`
+	TotalMinutes int `json:"total_minutes"`
+	TimeMinutes  int `json:"time_minutes"` // same as TotalMinutes, kept for older clients
+}
+
+// Match returns the recipes that use at least one of the products, best covered
+// first; ties go to fewer missing ingredients, then to the quicker recipe.
+func (c Corpus) Match(products []models.Product, q Query) []Result {
+	type source struct {
+		product models.Product
+		words   map[string]bool
+	}
+	sources := make([]source, 0, len(products))
+	for _, p := range products {
+		sources = append(sources, source{p, productWords(p)})
+	}
+
+	out := []Result{}
+	for _, rec := range c {
+		if !hasDiets(rec, q.Diets) {
+			continue
+		}
+		res := Result{
+			ID: rec.ID, Title: rec.Title, Steps: rec.Steps, Diets: rec.Diets,
+			Ingredients: []string{}, Matched: []MatchedIngredient{}, Missing: []string{},
+			Optional: []string{}, Pantry: []string{},
+			PrepMinutes: rec.PrepMinutes, CookMinutes: rec.CookMinutes,
+			TotalMinutes: rec.TotalMinutes(), TimeMinutes: rec.TotalMinutes(),
+		}
+		required, supplied := 0, 0
+		for _, ing := range rec.Ingredients {
+			if ing.Quantity != "" {
+				res.Ingredients = append(res.Ingredients, ing.Name+" ("+ing.Quantity+")")
+			} else {
+				res.Ingredients = append(res.Ingredients, ing.Name)
+			}
+
+			var from *models.Product
+			for i := range sources {
+				if matches(ing, sources[i].words) {
+					from = &sources[i].product
+					break
+				}
+			}
+			if from != nil {
+				res.Matched = append(res.Matched, MatchedIngredient{Name: ing.Name, Barcode: from.Barcode, Product: from.Name})
+			}
+			switch {
+			case ing.Pantry:
+				if from == nil {
+					res.Pantry = append(res.Pantry, ing.Name)
+				}
+			case ing.Optional:
+				if from == nil {
+					res.Optional = append(res.Optional, ing.Name)
+				}
+			default:
+				required++
+				if from != nil {
+					supplied++
+				} else {
+					res.Missing = append(res.Missing, ing.Name)
+				}
+			}
+		}
+		if supplied == 0 {
+			continue
+		}
+		res.Coverage = math.Round(float64(supplied)/float64(required)*1000) / 1000
+		out = append(out, res)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Coverage != b.Coverage {
+			return a.Coverage > b.Coverage
+		}
+		if len(a.Missing) != len(b.Missing) {
+			return len(a.Missing) < len(b.Missing)
+		}
+		return a.TotalMinutes < b.TotalMinutes
+	})
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out
+}
+
+func hasDiets(rec Recipe, diets []string) bool {
+	for _, d := range diets {
+		found := false
+		for _, rd := range rec.Diets {
+			if rd == d {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether every word of one of the ingredient's match keywords is among words
+func matches(ing Ingredient, words map[string]bool) bool {
+	for _, keyword := range ing.Match {
+		kw := tokenize(keyword)
+		if len(kw) == 0 {
+			continue
+		}
+		all := true
+		for _, w := range kw {
+			if !words[w] {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// productWords are the singular words of a product's name and categories
+func productWords(p models.Product) map[string]bool {
+	words := map[string]bool{}
+	for _, text := range append([]string{p.Name, p.Category}, p.Categories...) {
+		if i := strings.Index(text, ":"); i == 2 {
+			text = text[i+1:] // Open Food Facts language prefix, "en:"
+		}
+		for _, w := range tokenize(text) {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// tokenize lower-cases s, splits it into letter runs and singularizes each
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) })
+	for i, f := range fields {
+		fields[i] = singular(f)
+	}
+	return fields
+}
+
+// singular strips common English plural endings: berries, tomatoes, oats
+func singular(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 4 && strings.HasSuffix(w, "oes"):
+		return w[:len(w)-2]
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss"):
+		return w[:len(w)-1]
+	}
+	return w
+}
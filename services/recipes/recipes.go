@@ -0,0 +1,125 @@
+// Package recipes matches products against a local recipe corpus, ranking
+// recipes by how many of their ingredients the products cover.
+package recipes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"backend/allergens"
+)
+
+//go:embed recipes.json
+var builtinRecipesJSON []byte
+
+// NormalizeDiet returns the canonical diet name; ok is false unless it is one of
+// allergens.Diets, the diets dietary profiles and product checks use
+func NormalizeDiet(d string) (string, bool) {
+	d = strings.ToLower(strings.TrimSpace(d))
+	if !allergens.ValidDiet(d) {
+		return "", false
+	}
+	return d, true
+}
+
+// Ingredient is one line of a recipe. Match lists the product words that supply
+// it; pantry staples (oil, salt, ...) are assumed to be at hand, and optional
+// ingredients do not count towards coverage.
+type Ingredient struct {
+	Name     string   `json:"name"`
+	Quantity string   `json:"quantity,omitempty"`
+	Match    []string `json:"match,omitempty"`
+	Pantry   bool     `json:"pantry,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+}
+
+type Recipe struct {
+	ID          string       `json:"id"`
+	Title       string       `json:"title"`
+	Ingredients []Ingredient `json:"ingredients"`
+	Steps       []string     `json:"steps"`
+	PrepMinutes int          `json:"prep_minutes"`
+	CookMinutes int          `json:"cook_minutes"`
+	Diets       []string     `json:"diets"`
+}
+
+// TotalMinutes is preparation plus cooking time
+func (r Recipe) TotalMinutes() int { return r.PrepMinutes + r.CookMinutes }
+
+// Corpus is the set of recipes matched against
+type Corpus []Recipe
+
+var (
+	defaultMu     sync.RWMutex
+	defaultCorpus = builtinCorpus()
+)
+
+// Default returns the corpus used by the recipe handlers
+func Default() Corpus {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultCorpus
+}
+
+// SetDefault replaces the corpus used by the recipe handlers
+func SetDefault(c Corpus) {
+	defaultMu.Lock()
+	defaultCorpus = c
+	defaultMu.Unlock()
+}
+
+// Load reads a JSON array of recipes in the format of the built-in recipes.json
+func Load(path string) (Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCorpus(f)
+}
+
+func builtinCorpus() Corpus {
+	c, err := parseCorpus(strings.NewReader(string(builtinRecipesJSON)))
+	if err != nil {
+		panic("recipes: bad embedded recipes.json: " + err.Error())
+	}
+	return c
+}
+
+func parseCorpus(r io.Reader) (Corpus, error) {
+	var c Corpus
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i, rec := range c {
+		switch {
+		case rec.ID == "" || rec.Title == "":
+			return nil, fmt.Errorf("recipes: entry %d: id and title are required", i+1)
+		case seen[rec.ID]:
+			return nil, fmt.Errorf("recipes: duplicate id %q", rec.ID)
+		case len(rec.Ingredients) == 0:
+			return nil, fmt.Errorf("recipes: %s: no ingredients", rec.ID)
+		}
+		seen[rec.ID] = true
+		for j, d := range rec.Diets {
+			diet, ok := NormalizeDiet(d)
+			if !ok {
+				return nil, fmt.Errorf("recipes: %s: unknown diet %q", rec.ID, d)
+			}
+			c[i].Diets[j] = diet
+		}
+		if c[i].Diets == nil {
+			c[i].Diets = []string{}
+		}
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("recipes: empty corpus")
+	}
+	return c, nil
+}
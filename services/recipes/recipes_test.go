@@ -0,0 +1,171 @@
+package recipes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"backend/models"
+)
+
+func testCorpus(t *testing.T) Corpus {
+	t.Helper()
+	c, err := parseCorpus(strings.NewReader(`[
+		{"id": "porridge", "title": "Porridge", "prep_minutes": 2, "cook_minutes": 5, "diets": ["Vegetarian"],
+		 "ingredients": [
+			{"name": "oats", "quantity": "50 g", "match": ["oat"]},
+			{"name": "milk", "match": ["milk", "oat drink"]},
+			{"name": "berries", "match": ["berry"], "optional": true},
+			{"name": "salt", "pantry": true}]},
+		{"id": "overnight-oats", "title": "Overnight oats", "prep_minutes": 5, "diets": ["vegan", "vegetarian"],
+		 "ingredients": [
+			{"name": "oats", "match": ["oat"]},
+			{"name": "plant drink", "match": ["oat drink", "soy drink"]}]},
+		{"id": "hummus-toast", "title": "Hummus toast", "prep_minutes": 5, "diets": ["vegan", "vegetarian", "lactose-free"],
+		 "ingredients": [
+			{"name": "hummus", "match": ["hummus"]},
+			{"name": "bread", "match": ["bread"]},
+			{"name": "tomatoes", "match": ["tomato"]}]},
+		{"id": "omelette", "title": "Omelette", "prep_minutes": 5, "cook_minutes": 5, "diets": ["gluten-free"],
+		 "ingredients": [
+			{"name": "eggs", "match": ["egg"]},
+			{"name": "butter", "match": ["butter"], "pantry": true}]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func ids(results []Result) []string {
+	out := []string{}
+	for _, r := range results {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestMatch(t *testing.T) {
+	c := testCorpus(t)
+	oats := models.Product{Barcode: "1", Name: "Rolled Oats", Categories: []string{"en:cereals"}}
+	oatDrink := models.Product{Barcode: "2", Name: "Barista", Category: "en:oat-drinks"}
+	milk := models.Product{Barcode: "3", Name: "Whole milk"}
+	hummus := models.Product{Barcode: "4", Name: "Classic Hummus"}
+	eggs := models.Product{Barcode: "5", Name: "Free range eggs"}
+	butter := models.Product{Barcode: "6", Name: "Butter"}
+
+	tests := []struct {
+		name     string
+		products []models.Product
+		query    Query
+		want     []string
+		coverage []float64
+	}{
+		// both fully covered: the quicker one first
+		{"oats and oat drink", []models.Product{oats, oatDrink}, Query{}, []string{"overnight-oats", "porridge"}, []float64{1, 1}},
+		{"coverage first", []models.Product{oats, milk}, Query{}, []string{"porridge", "overnight-oats"}, []float64{1, 0.5}},
+		{"unmatched recipes are left out", []models.Product{hummus}, Query{}, []string{"hummus-toast"}, []float64{0.333}},
+		{"a pantry match alone is not a match", []models.Product{butter}, Query{}, []string{}, nil},
+		{"pantry ingredients do not count", []models.Product{eggs}, Query{}, []string{"omelette"}, []float64{1}},
+		{"diets all apply", []models.Product{oats, milk}, Query{Diets: []string{"vegan", "vegetarian"}}, []string{"overnight-oats"}, []float64{0.5}},
+		{"no recipe has the diet", []models.Product{oats}, Query{Diets: []string{"halal"}}, []string{}, nil},
+		{"limit", []models.Product{oats, milk}, Query{Limit: 1}, []string{"porridge"}, []float64{1}},
+		{"no products", nil, Query{}, []string{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Match(tt.products, tt.query)
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Fatalf("Match = %v, want %v", ids(got), tt.want)
+			}
+			for i, r := range got {
+				if r.Coverage != tt.coverage[i] {
+					t.Errorf("%s coverage = %v, want %v", r.ID, r.Coverage, tt.coverage[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchResult(t *testing.T) {
+	got := testCorpus(t).Match([]models.Product{{Barcode: "1", Name: "Rolled Oats"}, {Barcode: "3", Name: "Whole milk"}}, Query{})
+	if len(got) == 0 || got[0].ID != "porridge" {
+		t.Fatalf("Match = %v", ids(got))
+	}
+	r := got[0]
+	want := Result{
+		ID: "porridge", Title: "Porridge", Coverage: 1,
+		Ingredients: []string{"oats (50 g)", "milk", "berries", "salt"},
+		Matched:     []MatchedIngredient{{"oats", "1", "Rolled Oats"}, {"milk", "3", "Whole milk"}},
+		Missing:     []string{}, Optional: []string{"berries"}, Pantry: []string{"salt"},
+		Diets:       []string{"vegetarian"},
+		PrepMinutes: 2, CookMinutes: 5, TotalMinutes: 7, TimeMinutes: 7,
+	}
+	r.Steps = nil
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("porridge =\n%+v\nwant\n%+v", r, want)
+	}
+}
+
+func TestSingular(t *testing.T) {
+	for w, want := range map[string]string{
+		"berries":  "berry",
+		"tomatoes": "tomato",
+		"oats":     "oat",
+		"eggs":     "egg",
+		"glass":    "glass",
+		"pies":     "pie", // too short for the "ies" rule
+		"toes":     "toe",
+		"bus":      "bus",
+		"hummus":   "hummu", // a known false plural; products and keywords are stripped alike
+		"oat":      "oat",
+	} {
+		if got := singular(w); got != want {
+			t.Errorf("singular(%q) = %q, want %q", w, got, want)
+		}
+	}
+	if got := tokenize("Oat-Drinks, 1L"); !reflect.DeepEqual(got, []string{"oat", "drink", "l"}) {
+		t.Errorf("tokenize = %v", got)
+	}
+}
+
+func TestNormalizeDiet(t *testing.T) {
+	for in, want := range map[string]string{
+		"vegan":         "vegan",
+		" Gluten-Free ": "gluten-free",
+		"LACTOSE-FREE":  "lactose-free",
+		"halal":         "halal",
+		"dairy-free":    "",
+		"gluten free":   "",
+		"pescatarian":   "",
+		"":              "",
+	} {
+		got, ok := NormalizeDiet(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("NormalizeDiet(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestParseCorpus(t *testing.T) {
+	for name, in := range map[string]string{
+		"empty":          `[]`,
+		"no id":          `[{"title": "T", "ingredients": [{"name": "x"}]}]`,
+		"duplicate id":   `[{"id": "a", "title": "A", "ingredients": [{"name": "x"}]}, {"id": "a", "title": "B", "ingredients": [{"name": "y"}]}]`,
+		"no ingredients": `[{"id": "a", "title": "A"}]`,
+		"unknown diet":   `[{"id": "a", "title": "A", "diets": ["dairy-free"], "ingredients": [{"name": "x"}]}]`,
+		"not an array":   `{"id": "a"}`,
+	} {
+		if _, err := parseCorpus(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: parseCorpus accepted it", name)
+		}
+	}
+
+	c, err := parseCorpus(strings.NewReader(`[{"id": "a", "title": "A", "ingredients": [{"name": "x"}]}]`))
+	if err != nil || c[0].Diets == nil {
+		t.Errorf("parseCorpus = %+v, %v; want empty diets, not nil", c, err)
+	}
+	if len(builtinCorpus()) == 0 {
+		t.Error("built-in corpus is empty")
+	}
+}
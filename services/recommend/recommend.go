@@ -0,0 +1,225 @@
+// Package recommend ranks greener same-category substitutes for a product and
+// produces rule-based tips for improving on it.
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"backend/importer"
+	"backend/models"
+	"backend/repository"
+	"backend/scoring"
+)
+
+// Catalog is the product search the engine draws candidates from
+type Catalog interface {
+	Search(ctx context.Context, q repository.ProductQuery) (repository.SearchResult, error)
+}
+
+// Weights balance the ranking signals. Each signal is in [0,1]; unknown ones count as 0.5.
+type Weights struct {
+	Eco       float64
+	Nutrition float64
+	Price     float64
+}
+
+var DefaultWeights = Weights{Eco: 0.6, Nutrition: 0.3, Price: 0.1}
+
+// candidatePool is how many of the greenest products per category are considered
+const candidatePool = 50
+
+// Alternative is a suggested substitute and why it was picked
+type Alternative struct {
+	Name                string   `json:"name"`
+	Brand               string   `json:"brand"`
+	Barcode             string   `json:"barcode"`
+	GreenScore          int      `json:"green_score"`
+	EcoImprovement      int      `json:"eco_improvement"`
+	NutritionSimilarity *float64 `json:"nutrition_similarity"` // nil when the two share no known nutrients
+	Price               float64  `json:"price,omitempty"`
+	Score               float64  `json:"score"`
+	Reasons             []string `json:"reasons"`
+}
+
+// Result is everything the engine knows to suggest for one product
+type Result struct {
+	Category     string        `json:"category"` // category the alternatives come from, "" if none matched
+	Alternatives []Alternative `json:"alternatives"`
+	Tips         []Tip         `json:"tips"`
+}
+
+type Engine struct {
+	catalog Catalog
+	weights Weights
+}
+
+// New returns an engine over catalog using DefaultWeights
+func New(catalog Catalog) *Engine {
+	return &Engine{catalog: catalog, weights: DefaultWeights}
+}
+
+// Recommend returns up to limit alternatives with a strictly better ecoScore. It
+// tries the product's most specific category first and widens to broader ones
+// only when a category has no greener product. Unscored candidates are never
+// suggested; for an unscored product every scored candidate qualifies.
+func (e *Engine) Recommend(ctx context.Context, p models.Product, limit int) (Result, error) {
+	res := Result{Alternatives: []Alternative{}, Tips: Tips(p)}
+
+	for _, category := range categories(p) {
+		found, err := e.catalog.Search(ctx, repository.ProductQuery{Category: category, Sort: "-ecoScore", Limit: candidatePool})
+		if err != nil {
+			return res, err
+		}
+		for _, c := range found.Products {
+			if !c.HasEcoScore() || (p.HasEcoScore() && c.EcoScore <= p.EcoScore) || c.Barcode == p.Barcode {
+				continue
+			}
+			res.Alternatives = append(res.Alternatives, e.rank(p, c, category))
+		}
+		if len(res.Alternatives) > 0 {
+			res.Category = category
+			break
+		}
+	}
+
+	sort.SliceStable(res.Alternatives, func(i, j int) bool { return res.Alternatives[i].Score > res.Alternatives[j].Score })
+	if limit > 0 && len(res.Alternatives) > limit {
+		res.Alternatives = res.Alternatives[:limit]
+	}
+	return res, nil
+}
+
+// categories lists the product's categories from most to least specific, without duplicates
+func categories(p models.Product) []string {
+	var out []string
+	add := func(c string) {
+		if c != "" && !containsFold(out, c) {
+			out = append(out, c)
+		}
+	}
+	add(p.Category)
+	for i := len(p.Categories) - 1; i >= 0; i-- {
+		add(p.Categories[i])
+	}
+	return out
+}
+
+func (e *Engine) rank(p, c models.Product, category string) Alternative {
+	alt := Alternative{
+		Name:       c.Name,
+		Brand:      c.Brand,
+		Barcode:    c.Barcode,
+		GreenScore: c.EcoScore,
+		Price:      c.Price,
+	}
+
+	// there is no improvement to measure against an unscored product
+	eco := 0.5
+	if p.HasEcoScore() {
+		alt.EcoImprovement = c.EcoScore - p.EcoScore
+		eco = float64(alt.EcoImprovement) / math.Max(1, float64(100-p.EcoScore))
+		alt.Reasons = append(alt.Reasons, fmt.Sprintf("Eco-Score %d vs %d (+%d)", c.EcoScore, p.EcoScore, alt.EcoImprovement))
+	} else {
+		alt.Reasons = append(alt.Reasons, fmt.Sprintf("Eco-Score %d (this product has none)", c.EcoScore))
+	}
+	alt.Reasons = append(alt.Reasons, "Same category: "+category)
+
+	nutrition := 0.5
+	if sim, ok := NutritionSimilarity(nutritionOf(p), nutritionOf(c)); ok {
+		nutrition = sim
+		alt.NutritionSimilarity = &sim
+		switch pct := int(math.Round(sim * 100)); {
+		case sim >= 0.8:
+			alt.Reasons = append(alt.Reasons, fmt.Sprintf("Very similar nutrition (%d%% match)", pct))
+		case sim >= 0.6:
+			alt.Reasons = append(alt.Reasons, fmt.Sprintf("Similar nutrition (%d%% match)", pct))
+		default:
+			alt.Reasons = append(alt.Reasons, fmt.Sprintf("Different nutrition profile (%d%% match)", pct))
+		}
+	}
+
+	price := 0.5
+	if p.Price > 0 && c.Price > 0 {
+		ratio := c.Price / p.Price
+		price = math.Max(0, math.Min(1, 2-ratio))
+		switch {
+		case ratio < 1:
+			alt.Reasons = append(alt.Reasons, fmt.Sprintf("Cheaper (%.2f vs %.2f)", c.Price, p.Price))
+		case ratio > 1:
+			alt.Reasons = append(alt.Reasons, fmt.Sprintf("Costs %d%% more", int(math.Round((ratio-1)*100))))
+		}
+	}
+
+	for _, l := range c.Labels {
+		if !containsFold(p.Labels, l) {
+			alt.Reasons = append(alt.Reasons, "Labelled "+l)
+		}
+	}
+	if scoring.HasPalmOil(p) && !scoring.HasPalmOil(c) {
+		alt.Reasons = append(alt.Reasons, "Free of palm oil")
+	}
+
+	w := e.weights
+	alt.Score = (w.Eco*eco + w.Nutrition*nutrition + w.Price*price) / (w.Eco + w.Nutrition + w.Price)
+	alt.Score = math.Round(alt.Score*1000) / 1000
+	return alt
+}
+
+// similarityNutrients are the per-100g values compared by NutritionSimilarity
+var similarityNutrients = []func(n *models.Nutrition) *float64{
+	func(n *models.Nutrition) *float64 { return n.EnergyKcal },
+	func(n *models.Nutrition) *float64 { return n.Proteins },
+	func(n *models.Nutrition) *float64 { return n.Carbohydrates },
+	func(n *models.Nutrition) *float64 { return n.Sugars },
+	func(n *models.Nutrition) *float64 { return n.Fat },
+	func(n *models.Nutrition) *float64 { return n.SaturatedFat },
+	func(n *models.Nutrition) *float64 { return n.Fiber },
+	func(n *models.Nutrition) *float64 { return n.Salt },
+}
+
+// nutritionOf returns the product's nutrition facts, falling back to its raw
+// OFF nutriments for products stored before the typed facts existed
+func nutritionOf(p models.Product) *models.Nutrition {
+	if p.Nutrition != nil {
+		return p.Nutrition
+	}
+	return importer.NutritionFrom(p.Nutrients, "", 0)
+}
+
+// NutritionSimilarity compares the nutrients both products report, giving 1 for
+// identical values. ok is false when they have none in common.
+func NutritionSimilarity(a, b *models.Nutrition) (sim float64, ok bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	total, n := 0.0, 0
+	for _, field := range similarityNutrients {
+		x, y := field(a), field(b)
+		if x == nil || y == nil {
+			continue
+		}
+		n++
+		if hi := math.Max(*x, *y); hi > 0 {
+			total += 1 - math.Abs(*x-*y)/hi
+		} else {
+			total++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return math.Round(total/float64(n)*1000) / 1000, true
+}
+
+func containsFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
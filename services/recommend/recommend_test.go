@@ -0,0 +1,123 @@
+package recommend
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"backend/models"
+	"backend/repository"
+)
+
+func TestNutritionSimilarity(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	typed := models.Product{Nutrition: &models.Nutrition{EnergyKcal: f(100), Sugars: f(10), Fat: f(0)}}
+	raw := models.Product{Nutrients: map[string]float64{"energy-kcal_100g": 50, "sugars_100g": 10, "fat_100g": 0}}
+
+	sim, ok := NutritionSimilarity(nutritionOf(typed), nutritionOf(raw))
+	if !ok || sim != 0.833 {
+		t.Errorf("typed vs raw = %v, %v; want 0.833", sim, ok)
+	}
+	if sim, ok := NutritionSimilarity(nutritionOf(typed), nutritionOf(typed)); !ok || sim != 1 {
+		t.Errorf("identical = %v, %v; want 1", sim, ok)
+	}
+	if _, ok := NutritionSimilarity(nutritionOf(typed), nutritionOf(models.Product{})); ok {
+		t.Error("product without nutrition compared")
+	}
+	other := models.Product{Nutrition: &models.Nutrition{Proteins: f(5)}}
+	if _, ok := NutritionSimilarity(typed.Nutrition, other.Nutrition); ok {
+		t.Error("products without a common nutrient compared")
+	}
+}
+
+func TestTips(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	rulesOf := func(p models.Product) map[string]bool {
+		out := map[string]bool{}
+		for _, tip := range Tips(p) {
+			out[tip.Rule] = true
+		}
+		return out
+	}
+
+	typed := models.Product{Nutrition: &models.Nutrition{Sugars: f(40), Salt: f(2)}}
+	raw := models.Product{Nutrients: map[string]float64{"sugars_100g": 40, "sodium_100g": 0.8}}
+	low := models.Product{Nutrition: &models.Nutrition{Sugars: f(5), Salt: f(0.2)}}
+	for name, tt := range map[string]struct {
+		p    models.Product
+		want bool
+	}{"typed": {typed, true}, "raw nutrients": {raw, true}, "low": {low, false}, "unknown": {models.Product{}, false}} {
+		got := rulesOf(tt.p)
+		if got["high_sugar"] != tt.want || got["high_salt"] != tt.want {
+			t.Errorf("%s: tips %v, want high_sugar and high_salt %v", name, got, tt.want)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	yogurt := func(code, name string, eco int) models.Product {
+		return models.Product{Barcode: code, Name: name, EcoScore: eco, Category: "yogurts", Categories: []string{"dairies", "yogurts"}}
+	}
+	target := yogurt("1", "Target", 40)
+	catalog := repository.NewMemoryProducts(
+		target,
+		yogurt("2", "Greenest yogurt", 80),
+		yogurt("3", "Greener yogurt", 60),
+		yogurt("4", "Worse yogurt", 30),
+		yogurt("5", "Same yogurt", 40),
+		models.Product{Barcode: "6", Name: "Milk", EcoScore: 95, Category: "milks", Categories: []string{"dairies", "milks"}},
+		models.Product{Barcode: "7", Name: "Cheese", EcoScore: 20, Category: "cheeses", Categories: []string{"dairies", "cheeses"}},
+		yogurt("8", "Unscored yogurt", 0),
+	)
+	e := New(catalog)
+	ctx := context.Background()
+
+	names := func(res Result) []string {
+		var out []string
+		for _, a := range res.Alternatives {
+			out = append(out, a.Name)
+		}
+		return out
+	}
+
+	// the most specific category with a greener product wins; the rest are not mixed in
+	res, err := e.Recommend(ctx, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(res); res.Category != "yogurts" || !reflect.DeepEqual(got, []string{"Greenest yogurt", "Greener yogurt"}) {
+		t.Errorf("yogurt alternatives from %q = %v", res.Category, got)
+	}
+	if a := res.Alternatives[0]; a.EcoImprovement != 40 || a.GreenScore != 80 || a.Score <= res.Alternatives[1].Score {
+		t.Errorf("best alternative = %+v", a)
+	}
+	if res, _ := e.Recommend(ctx, target, 1); len(res.Alternatives) != 1 {
+		t.Errorf("limit 1 gave %v", names(res))
+	}
+
+	// no greener cheese, so the search widens to dairies
+	cheese, _ := catalog.FindByBarcode(ctx, "7")
+	res, err = e.Recommend(ctx, *cheese, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Category != "dairies" || len(res.Alternatives) != 6 || res.Alternatives[0].Name != "Milk" {
+		t.Errorf("cheese alternatives from %q = %v", res.Category, names(res))
+	}
+
+	// an unscored product is never suggested, and for one every scored product is an option
+	unscored, _ := catalog.FindByBarcode(ctx, "8")
+	res, err = e.Recommend(ctx, *unscored, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Alternatives) != 5 || res.Alternatives[0].Name != "Greenest yogurt" || res.Alternatives[0].EcoImprovement != 0 {
+		t.Errorf("unscored yogurt alternatives = %v, first %+v", names(res), res.Alternatives[0])
+	}
+
+	// nothing greener anywhere
+	milk, _ := catalog.FindByBarcode(ctx, "6")
+	if res, _ := e.Recommend(ctx, *milk, 0); res.Category != "" || len(res.Alternatives) != 0 {
+		t.Errorf("milk alternatives from %q = %v", res.Category, names(res))
+	}
+}
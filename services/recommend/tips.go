@@ -0,0 +1,89 @@
+package recommend
+
+import (
+	"fmt"
+	"strings"
+
+	"backend/models"
+	"backend/scoring"
+)
+
+// Tip is a rule-based suggestion. Swap tips point at a different kind of
+// product; the others at a better version of the same one.
+type Tip struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Swap    bool   `json:"swap"`
+}
+
+// highFactorKg is the kg CO2e per kg above which a category counts as high emission
+const highFactorKg = 10
+
+// certifications are the labels that satisfy the certification tip
+var certifications = []string{"organic", "eu-organic", "fair-trade", "fairtrade-international", "rainforest-alliance", "msc-sustainable-seafood"}
+
+// rules run in order; each returns ok=false when it does not apply
+var rules = []func(p models.Product) (Tip, bool){
+	func(p models.Product) (Tip, bool) {
+		category, factor, ok := scoring.CategoryFactor(p.Category, p.Categories)
+		if !ok || factor < highFactorKg {
+			return Tip{}, false
+		}
+		return Tip{Rule: "high_emission_category", Swap: true, Message: fmt.Sprintf(
+			"The %s category has a high footprint (about %.0f kg CO2e per kg); a plant-based alternative cuts it the most", category, factor)}, true
+	},
+	func(p models.Product) (Tip, bool) {
+		if !scoring.HasPalmOil(p) {
+			return Tip{}, false
+		}
+		return Tip{Rule: "palm_oil", Swap: true, Message: "Contains palm oil, a major driver of deforestation; look for a palm-oil-free alternative"}, true
+	},
+	func(p models.Product) (Tip, bool) {
+		for _, pkg := range p.Packaging {
+			if pkg = strings.ToLower(pkg); strings.Contains(pkg, "plastic") || pkg == "pet" || strings.Contains(pkg, "polystyrene") {
+				return Tip{Rule: "plastic_packaging", Message: "Packaged in plastic; glass, metal, cardboard or buying loose is easier to recycle"}, true
+			}
+		}
+		return Tip{}, false
+	},
+	func(p models.Product) (Tip, bool) {
+		if len(p.Origins) > 0 {
+			return Tip{}, false
+		}
+		return Tip{Rule: "unknown_origin", Message: "The origin of the ingredients isn't listed; local, seasonal produce usually travels less"}, true
+	},
+	func(p models.Product) (Tip, bool) {
+		if p.EcoScore >= 60 {
+			return Tip{}, false
+		}
+		for _, l := range p.Labels {
+			if containsFold(certifications, l) {
+				return Tip{}, false
+			}
+		}
+		return Tip{Rule: "no_certification", Message: "Look for an organic or fair-trade certified version"}, true
+	},
+	func(p models.Product) (Tip, bool) {
+		if n := nutritionOf(p); n != nil && n.Sugars != nil && *n.Sugars > 22.5 {
+			return Tip{Rule: "high_sugar", Message: fmt.Sprintf("High in sugar (%.1f g per 100 g); a lower-sugar option is better for you", *n.Sugars)}, true
+		}
+		return Tip{}, false
+	},
+	func(p models.Product) (Tip, bool) {
+		if n := nutritionOf(p); n != nil && n.Salt != nil && *n.Salt > 1.5 {
+			return Tip{Rule: "high_salt", Message: fmt.Sprintf("High in salt (%.1f g per 100 g); a lower-salt option is better for you", *n.Salt)}, true
+		}
+		return Tip{}, false
+	},
+}
+
+// Tips applies every rule to the product
+func Tips(p models.Product) []Tip {
+	tips := []Tip{}
+	for _, rule := range rules {
+		if t, ok := rule(p); ok {
+			tips = append(tips, t)
+		}
+	}
+	return tips
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// Error codes carried by every error response; clients should branch on these, not on messages
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidBody      = "invalid_body"
+	CodeValidation       = "validation_failed"
+	CodeBodyTooLarge     = "body_too_large"
+	CodeInvalidBarcode   = "invalid_barcode"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeInternal         = "internal_error"
+)
+
+// APIError is the error of a failed response, written by Error as
+//
+//	{"success": false, "error": {"code", "message", "details", "request_id"}}
+type APIError struct {
+	Status    int         `json:"-"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+
+	cause error // logged for 5xx responses, never sent
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+func (e *APIError) Unwrap() error { return e.cause }
+
+// WithDetails returns a copy of e carrying machine-readable details, such as the offending fields
+func (e *APIError) WithDetails(details interface{}) *APIError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+func NewError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *APIError {
+	return NewError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// InvalidBody reports a request body that is not the expected JSON
+func InvalidBody() *APIError {
+	return NewError(http.StatusBadRequest, CodeInvalidBody, "Invalid body")
+}
+
+func Unauthorized(message string) *APIError {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *APIError {
+	return NewError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *APIError {
+	return NewError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *APIError {
+	return NewError(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal reports an unexpected failure; message is sent to the client and cause only logged
+func Internal(message string, cause error) *APIError {
+	e := NewError(http.StatusInternalServerError, CodeInternal, message)
+	e.cause = cause
+	return e
+}
+
+// FromError maps err to an API error: an *APIError anywhere in its chain is
+// used as is, and anything else becomes a 500 whose message hides err. Callers
+// turn domain errors into an *APIError first.
+func FromError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Internal("Internal server error", err)
+}
+
+// Error writes err as an error response, see FromError. The request id set by
+// WithRequestID is included, and 5xx causes are logged under it.
+func Error(w http.ResponseWriter, err error) {
+	e := *FromError(err)
+	e.RequestID = w.Header().Get(RequestIDHeader)
+	if e.Status >= http.StatusInternalServerError && e.cause != nil {
+		log.Printf("request %s: %s: %v", e.RequestID, e.Message, e.cause)
+	}
+	JSON(w, e.Status, map[string]interface{}{"success": false, "error": e})
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/rand"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader carries the id that ties a response, and its error, to the server logs
+const RequestIDHeader = "X-Request-ID"
+
+var requestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// WithRequestID gives every request an id, reusing a well-formed X-Request-ID
+// sent by the client, and echoes it in the response's X-Request-ID header
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestID.MatchString(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
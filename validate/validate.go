@@ -0,0 +1,379 @@
+// Package validate checks decoded request structs against rules declared in
+// `validate` struct tags, reporting every failing field by its JSON name:
+//
+//	type goalRequest struct {
+//		Type        *string  `json:"type" validate:"enum=goal_type"`
+//		TargetValue *float64 `json:"target_value" validate:"gt=0,max=1000000"`
+//		Tags        []string `json:"tags" validate:"maxitems=10,dive,required,maxlen=32"`
+//	}
+//
+// Rules, separated by commas:
+//
+//	required        the value is not zero; for strings, not blank
+//	min=N max=N     numbers are at least / at most N
+//	gt=N            numbers are greater than N
+//	minlen=N        strings have at least / at most N bytes
+//	maxlen=N
+//	minitems=N      slices and maps have at least / at most N elements
+//	maxitems=N
+//	oneof=a b c     strings are one of the space-separated values
+//	enum=name       strings are accepted by the enum registered under name
+//	dive            the rules after it apply to each element of a slice
+//
+// Pointers are checked only when set, except by required. Nested structs and
+// slices of structs are always walked, so their fields report as "items[2].quantity".
+//
+// Struct panics on a malformed tag; Check finds those without a value to validate.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError is one field that broke one rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string { return e.Field + " " + e.Message }
+
+// Errors lists every field that failed validation
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Missing is the error for a required field that was not sent
+func Missing(field string) FieldError {
+	return FieldError{Field: field, Rule: "required", Message: "is required"}
+}
+
+type enum struct {
+	values []string // listed in messages
+	valid  func(string) bool
+}
+
+var (
+	enumsMu sync.RWMutex
+	enums   = map[string]enum{}
+)
+
+// RegisterEnum makes `enum=name` accept the strings valid accepts, naming values
+// in error messages. valid may accept more than values, such as aliases.
+func RegisterEnum(name string, values []string, valid func(string) bool) {
+	enumsMu.Lock()
+	enums[name] = enum{values: values, valid: valid}
+	enumsMu.Unlock()
+}
+
+// Struct validates v, a struct or pointer to one, returning Errors or nil
+func Struct(v interface{}) error {
+	var errs Errors
+	walk(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// walk checks the fields of the struct, or the elements of the slice, at v
+func walk(v reflect.Value, path string, errs *Errors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Slice:
+		default:
+			return // plain values carry no rules of their own
+		}
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := jsonName(f)
+			if name == "-" {
+				continue
+			}
+			field := name
+			if path != "" {
+				field = path + "." + name
+			}
+			if f.Anonymous && f.Tag.Get("json") == "" {
+				field = path // embedded fields are promoted
+			}
+			if rules := f.Tag.Get("validate"); rules != "" {
+				check(v.Field(i), field, strings.Split(rules, ","), errs)
+			}
+			walk(v.Field(i), field, errs)
+		}
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// check applies rules to the field value v, stopping at the first failure
+func check(v reflect.Value, field string, rules []string, errs *Errors) {
+	for i, rule := range rules {
+		if rule == "required" {
+			if isBlank(v) {
+				*errs = append(*errs, Missing(field))
+				return
+			}
+			continue
+		}
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		if rule == "dive" {
+			if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+				for j := 0; j < v.Len(); j++ {
+					check(v.Index(j), field+"["+strconv.Itoa(j)+"]", rules[i+1:], errs)
+				}
+			}
+			return
+		}
+		if msg := apply(v, rule); msg != "" {
+			name, _, _ := strings.Cut(rule, "=")
+			*errs = append(*errs, FieldError{Field: field, Rule: name, Message: msg})
+			return
+		}
+	}
+}
+
+func isBlank(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return v.IsZero()
+}
+
+// apply checks one rule against a non-pointer value, returning why it failed or ""
+func apply(v reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	switch name {
+	case "min", "max", "gt":
+		n, ok := number(v)
+		if !ok {
+			return ""
+		}
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic("validate: bad rule " + rule)
+		}
+		switch {
+		case name == "min" && n < limit:
+			return "must be at least " + arg
+		case name == "max" && n > limit:
+			return "must be at most " + arg
+		case name == "gt" && n <= limit:
+			return "must be greater than " + arg
+		}
+	case "minlen", "maxlen":
+		if v.Kind() != reflect.String {
+			return ""
+		}
+		limit := atoi(rule, arg)
+		switch {
+		case name == "minlen" && len(v.String()) < limit:
+			return "must be at least " + arg + " bytes long"
+		case name == "maxlen" && len(v.String()) > limit:
+			return "must be at most " + arg + " bytes long"
+		}
+	case "minitems", "maxitems":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array && v.Kind() != reflect.Map {
+			return ""
+		}
+		limit := atoi(rule, arg)
+		switch {
+		case name == "minitems" && v.Len() < limit:
+			return "must have at least " + arg + " items"
+		case name == "maxitems" && v.Len() > limit:
+			return "must have at most " + arg + " items"
+		}
+	case "oneof":
+		if v.Kind() != reflect.String {
+			return ""
+		}
+		values := strings.Fields(arg)
+		for _, allowed := range values {
+			if v.String() == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	case "enum":
+		if v.Kind() != reflect.String {
+			return ""
+		}
+		enumsMu.RLock()
+		e, ok := enums[arg]
+		enumsMu.RUnlock()
+		if !ok {
+			panic("validate: unknown enum " + arg)
+		}
+		if !e.valid(v.String()) {
+			return "must be one of " + strings.Join(e.values, ", ")
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+// Check reports the first malformed `validate` tag in the type of v or the
+// structs it contains: an unknown rule, a missing or non-numeric limit, an
+// unregistered enum, or a rule on a kind it never applies to. Run it on
+// request structs at startup, as Struct only panics once a request arrives.
+func Check(v interface{}) error {
+	return checkType(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+// checkType checks the tags of the struct type t, following walk
+func checkType(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || jsonName(f) == "-" {
+			continue
+		}
+		if rules := f.Tag.Get("validate"); rules != "" {
+			if err := checkRules(f.Type, strings.Split(rules, ",")); err != nil {
+				return fmt.Errorf("validate: %s.%s: %w", t, f.Name, err)
+			}
+		}
+		if err := checkType(f.Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRules checks rules against the field type t, as check applies them
+func checkRules(t reflect.Type, rules []string) error {
+	for i, rule := range rules {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+		var err error
+		switch name {
+		case "required":
+		case "dive":
+			if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+				return fmt.Errorf("dive on %s, not a slice", t)
+			}
+			return checkRules(t.Elem(), rules[i+1:])
+		case "min", "max", "gt":
+			_, err = strconv.ParseFloat(arg, 64)
+			err = applies(t, rule, err, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64)
+		case "minlen", "maxlen":
+			_, err = strconv.Atoi(arg)
+			err = applies(t, rule, err, reflect.String)
+		case "minitems", "maxitems":
+			_, err = strconv.Atoi(arg)
+			err = applies(t, rule, err, reflect.Slice, reflect.Array, reflect.Map)
+		case "oneof":
+			if len(strings.Fields(arg)) == 0 {
+				err = fmt.Errorf("rule %q lists no values", rule)
+			}
+			err = applies(t, rule, err, reflect.String)
+		case "enum":
+			enumsMu.RLock()
+			_, ok := enums[arg]
+			enumsMu.RUnlock()
+			if !ok {
+				err = fmt.Errorf("enum %q is not registered", arg)
+			}
+			err = applies(t, rule, err, reflect.String)
+		default:
+			err = fmt.Errorf("unknown rule %q", rule)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applies passes on a parse error for rule, or reports that rule ignores
+// values of type t when t is not one of kinds
+func applies(t reflect.Type, rule string, err error, kinds ...reflect.Kind) error {
+	if err != nil {
+		if _, ok := err.(*strconv.NumError); ok {
+			return fmt.Errorf("rule %q needs a number", rule)
+		}
+		return err
+	}
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	for _, k := range kinds {
+		if t.Kind() == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("rule %q does not apply to %s", rule, t)
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func atoi(rule, arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic("validate: bad rule " + rule)
+	}
+	return n
+}
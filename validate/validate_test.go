@@ -0,0 +1,208 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterEnum("test_color", []string{"red", "green"}, func(c string) bool {
+		return c == "red" || c == "green" || c == "RED"
+	})
+}
+
+type line struct {
+	ProductID string `json:"productId" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"min=1,max=9"`
+}
+
+type order struct {
+	Name     string         `json:"name" validate:"required,minlen=2,maxlen=5"`
+	Note     *string        `json:"note" validate:"maxlen=3"`
+	Ref      *string        `json:"ref" validate:"required"`
+	Price    float64        `json:"price" validate:"gt=0"`
+	Discount *float64       `json:"discount" validate:"min=0,max=0.5"`
+	Color    string         `json:"color" validate:"enum=test_color"`
+	Size     string         `json:"size" validate:"oneof=s m l"`
+	Tags     []string       `json:"tags" validate:"maxitems=2,dive,required,maxlen=3"`
+	Codes    *[]string      `json:"codes" validate:"minitems=1,dive,minlen=2"`
+	Extra    map[string]int `json:"extra" validate:"maxitems=1"`
+	Lines    []line         `json:"items"`
+	Primary  *line          `json:"primary"`
+	Secret   string         `json:"-" validate:"required"`
+	internal string
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// valid is an order that passes every rule
+func valid() order {
+	return order{Name: "abc", Ref: ptr("r1"), Price: 1, Color: "red", Size: "m", Lines: []line{{"1", 1}}}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(o *order)
+		errors []FieldError // nil when the order is valid
+	}{
+		{"valid", func(o *order) {}, nil},
+		{"valid at every limit", func(o *order) {
+			o.Name, o.Note, o.Discount = "abcde", ptr("abc"), ptr(0.5)
+			o.Tags, o.Codes, o.Extra = []string{"a", "b"}, &[]string{"ab"}, map[string]int{"a": 1}
+			o.Lines = []line{{"1", 1}, {"2", 9}}
+		}, nil},
+		{"required string", func(o *order) { o.Name = "" }, []FieldError{Missing("name")}},
+		{"required blank string", func(o *order) { o.Name = "  " }, []FieldError{Missing("name")}},
+		{"required nil pointer", func(o *order) { o.Ref = nil }, []FieldError{Missing("ref")}},
+		{"required set pointer to empty string", func(o *order) { o.Ref = ptr("") }, nil},
+		{"minlen", func(o *order) { o.Name = "a" }, []FieldError{{"name", "minlen", "must be at least 2 bytes long"}}},
+		{"maxlen", func(o *order) { o.Name = "abcdef" }, []FieldError{{"name", "maxlen", "must be at most 5 bytes long"}}},
+		{"maxlen on a pointer", func(o *order) { o.Note = ptr("abcd") }, []FieldError{{"note", "maxlen", "must be at most 3 bytes long"}}},
+		{"gt", func(o *order) { o.Price = 0 }, []FieldError{{"price", "gt", "must be greater than 0"}}},
+		{"min", func(o *order) { o.Discount = ptr(-0.1) }, []FieldError{{"discount", "min", "must be at least 0"}}},
+		{"max", func(o *order) { o.Discount = ptr(0.6) }, []FieldError{{"discount", "max", "must be at most 0.5"}}},
+		{"enum", func(o *order) { o.Color = "blue" }, []FieldError{{"color", "enum", "must be one of red, green"}}},
+		{"enum alias", func(o *order) { o.Color = "RED" }, nil},
+		{"enum empty", func(o *order) { o.Color = "" }, []FieldError{{"color", "enum", "must be one of red, green"}}},
+		{"oneof", func(o *order) { o.Size = "xl" }, []FieldError{{"size", "oneof", "must be one of s, m, l"}}},
+		{"maxitems", func(o *order) { o.Tags = []string{"a", "b", "c"} }, []FieldError{{"tags", "maxitems", "must have at most 2 items"}}},
+		{"maxitems on a map", func(o *order) { o.Extra = map[string]int{"a": 1, "b": 2} }, []FieldError{{"extra", "maxitems", "must have at most 1 items"}}},
+		{"minitems on a set pointer", func(o *order) { o.Codes = &[]string{} }, []FieldError{{"codes", "minitems", "must have at least 1 items"}}},
+		{"dive", func(o *order) { o.Tags = []string{"a", "abcd"} }, []FieldError{{"tags[1]", "maxlen", "must be at most 3 bytes long"}}},
+		{"dive required", func(o *order) { o.Tags = []string{" ", "a"} }, []FieldError{Missing("tags[0]")}},
+		{"dive through a pointer", func(o *order) { o.Codes = &[]string{"ab", "c"} }, []FieldError{{"codes[1]", "minlen", "must be at least 2 bytes long"}}},
+		{"nested slice", func(o *order) { o.Lines = []line{{"1", 1}, {"2", 1}, {"3", 10}} }, []FieldError{{"items[2].quantity", "max", "must be at most 9"}}},
+		{"nested pointer", func(o *order) { o.Primary = &line{Quantity: 1} }, []FieldError{Missing("primary.productId")}},
+		{"every field is reported, once each", func(o *order) {
+			o.Name, o.Price = "", 0
+			o.Lines = []line{{"", 0}}
+		}, []FieldError{
+			Missing("name"),
+			{"price", "gt", "must be greater than 0"},
+			Missing("items[0].productId"),
+			{"items[0].quantity", "min", "must be at least 1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := valid()
+			tt.change(&o)
+			err := Struct(&o)
+			if tt.errors == nil {
+				if err != nil {
+					t.Fatalf("Struct = %v, want nil", err)
+				}
+				return
+			}
+			errs, ok := err.(Errors)
+			if !ok || !reflect.DeepEqual([]FieldError(errs), tt.errors) {
+				t.Errorf("Struct = %#v, want %#v", err, tt.errors)
+			}
+		})
+	}
+}
+
+func TestStructEmbedded(t *testing.T) {
+	type inner struct {
+		Code string `json:"code" validate:"required"`
+	}
+	type outer struct {
+		inner
+		Line line `json:"line"`
+	}
+	// unexported embedded structs are skipped, as encoding/json cannot set them
+	err := Struct(outer{Line: line{ProductID: "1"}})
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 || errs[0].Field != "line.quantity" {
+		t.Errorf("Struct = %v, want line.quantity", err)
+	}
+	type Inner struct {
+		Code string `json:"code" validate:"required"`
+	}
+	type promoted struct{ Inner }
+	if err := Struct(promoted{}); err == nil || err.Error() != "code is required" {
+		t.Errorf("Struct = %v, want the promoted field by its own name", err)
+	}
+}
+
+func TestBadTagPanics(t *testing.T) {
+	for _, v := range []interface{}{
+		struct {
+			N int `validate:"mni=1"`
+		}{},
+		struct {
+			N int `validate:"max=ten"`
+		}{},
+		struct {
+			S string `validate:"maxlen="`
+		}{},
+		struct {
+			S string `validate:"enum=no_such_enum"`
+		}{},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Struct(%T) did not panic", v)
+				}
+			}()
+			_ = Struct(v)
+		}()
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check(order{}); err != nil {
+		t.Errorf("Check(order) = %v", err)
+	}
+	type nested struct {
+		Lines []struct {
+			Quantity int `json:"quantity" validate:"min=one"`
+		} `json:"items"`
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		error string
+	}{
+		{"unknown rule", struct {
+			N int `validate:"mni=1"`
+		}{}, `N: unknown rule "mni=1"`},
+		{"non-numeric limit", struct {
+			N int `validate:"max=ten"`
+		}{}, `rule "max=ten" needs a number`},
+		{"missing length", struct {
+			S string `validate:"maxlen"`
+		}{}, `rule "maxlen" needs a number`},
+		{"unregistered enum", struct {
+			S *string `validate:"enum=no_such_enum"`
+		}{}, `enum "no_such_enum" is not registered`},
+		{"empty oneof", struct {
+			S string `validate:"oneof="`
+		}{}, `rule "oneof=" lists no values`},
+		{"length on a slice", struct {
+			S []string `validate:"maxlen=3"`
+		}{}, `rule "maxlen=3" does not apply to []string`},
+		{"dive on a string", struct {
+			S string `validate:"dive,required"`
+		}{}, `dive on string, not a slice`},
+		{"rule after dive", struct {
+			S *[]int `validate:"dive,minlen=1"`
+		}{}, `rule "minlen=1" does not apply to int`},
+		{"nested struct", nested{}, `Quantity: rule "min=one" needs a number`},
+		{"pointer to a struct", &struct {
+			Line *struct {
+				N int `validate:"maxitems=1"`
+			}
+		}{}, `rule "maxitems=1" does not apply to int`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Check = %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+}